@@ -50,4 +50,5 @@ func (a *API) OverrideTimeNow(now func() time.Time) *API {
 func (a *API) AddTo(r *mux.Router) {
 	r.Methods("POST").Path("/v1/events/new").HandlerFunc(a.handlePostNewEvent)
 	r.Methods("POST").Path("/v1/events/synthetic").HandlerFunc(a.handlePostSyntheticEvent)
+	r.Methods("GET").Path("/v1/events/{id}/delivery-results").HandlerFunc(a.handleGetDeliveryResults)
 }
@@ -5,15 +5,20 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sapcc/go-bits/httpapi"
 	"github.com/sapcc/go-bits/respondwith"
 	"github.com/sapcc/go-bits/sqlext"
 
+	"github.com/sapcc/tenso/internal/cloudevents"
 	"github.com/sapcc/tenso/internal/synthetic"
 	"github.com/sapcc/tenso/internal/tenso"
 )
@@ -22,6 +27,18 @@ const (
 	maxIncomingPayloadBytes = 10 << 20 // 10 MiB
 )
 
+var validationWarningsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tenso_validation_warnings",
+		Help: "Counter for warnings that were reported while validating incoming events.",
+	},
+	[]string{"payload_type", "code"},
+)
+
+func init() {
+	prometheus.MustRegister(validationWarningsCounter)
+}
+
 var (
 	findOrCreateUserQuery = sqlext.SimplifyWhitespace(`
 		INSERT INTO users (uuid, name, domain_name) VALUES ($1, $2, $3)
@@ -30,20 +47,46 @@ var (
 	`)
 )
 
+// invalidPayloadError is returned by the getPayload callback of
+// handlePostNewEventCommon when the payload is malformed in a way that the
+// client needs to know about.
+type invalidPayloadError struct {
+	Inner error
+}
+
+// Error implements the builtin/error interface.
+func (e invalidPayloadError) Error() string {
+	return e.Inner.Error()
+}
+
 func (a *API) handlePostNewEvent(w http.ResponseWriter, r *http.Request) {
 	httpapi.IdentifyEndpoint(r, "/v1/events/new")
 	getEventPayload := func(payloadType string) ([]byte, error) {
-		return io.ReadAll(io.LimitReader(r.Body, maxIncomingPayloadBytes))
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIncomingPayloadBytes))
+		if err != nil || payloadType != cloudevents.PayloadType || !cloudevents.IsBinaryMode(r.Header) {
+			return body, err
+		}
+		// CloudEvents in binary content mode are converted into structured
+		// content mode, so that only one format needs to be handled downstream
+		event, err := cloudevents.FromBinaryMode(r.Header, body)
+		if err != nil {
+			return nil, invalidPayloadError{err}
+		}
+		return json.Marshal(event)
 	}
-	a.handlePostNewEventCommon(w, r, "event:create", getEventPayload)
+	a.handlePostNewEventCommon(w, r, "event:create", "event:create:payload", getEventPayload)
 }
 
 func (a *API) handlePostSyntheticEvent(w http.ResponseWriter, r *http.Request) {
 	httpapi.IdentifyEndpoint(r, "/v1/events/synthetic")
-	a.handlePostNewEventCommon(w, r, "event:create_synthetic", synthetic.Event)
+	a.handlePostNewEventCommon(w, r, "event:create_synthetic", "event:create_synthetic:payload", synthetic.Event)
 }
 
-func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, policyRule string, getPayload func(payloadType string) ([]byte, error)) {
+// Authorization is checked in two steps: The policy rule is checked with only
+// the payload type before the payload is read, so that unauthorized requests
+// are rejected early. The payloadPolicyRule is checked after validation, since
+// it may refer to attributes of the payload.
+func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, policyRule, payloadPolicyRule string, getPayload func(payloadType string) ([]byte, error)) {
 	ctx := r.Context()
 	requestTime := a.timeNow()
 
@@ -59,7 +102,7 @@ func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
-	// check authorization
+	// check authentication and authorization for this payload type
 	token := a.Validator.CheckToken(r)
 	token.Context.Request = map[string]string{"target.payload_type": payloadType}
 	if !token.Require(w, policyRule) {
@@ -87,12 +130,25 @@ func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, p
 
 	// validate incoming payload
 	payloadBytes, err := getPayload(payloadType)
+	if ipe, ok := errors.AsType[invalidPayloadError](err); ok {
+		respondWithInvalidPayload(w, r, ipe.Inner)
+		return
+	}
 	if respondwith.ObfuscatedErrorText(w, err) {
 		return
 	}
 	payloadInfo, err := validationHandler.ValidatePayload(payloadBytes, a.RegionRx)
 	if err != nil {
-		http.Error(w, "invalid event payload: "+err.Error(), http.StatusUnprocessableEntity)
+		respondWithInvalidPayload(w, r, err)
+		return
+	}
+
+	// check authorization for the attributes of this payload
+	token.Context.Request = map[string]string{"target.payload_type": payloadType}
+	for key, value := range payloadInfo.PolicyAttributes {
+		token.Context.Request["target."+key] = value
+	}
+	if !token.Require(w, payloadPolicyRule) {
 		return
 	}
 
@@ -106,6 +162,14 @@ func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, p
 	if respondwith.ObfuscatedErrorText(w, err) {
 		return
 	}
+	warnings := payloadInfo.Warnings
+	if warnings == nil {
+		warnings = tenso.ValidationErrors{} // serialize as [] instead of null
+	}
+	warningsJSON, err := json.Marshal(warnings)
+	if respondwith.ObfuscatedErrorText(w, err) {
+		return
+	}
 
 	// find or create user account
 	var userID int64
@@ -130,6 +194,7 @@ func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, p
 		Payload:         string(payloadBytes),
 		Description:     payloadInfo.Description,
 		RoutingInfoJSON: string(routingInfoJSON),
+		WarningsJSON:    string(warningsJSON),
 	}
 	err = tenso.EventStore.Insert(ctx, tx, &event)
 	if respondwith.ObfuscatedErrorText(w, err) {
@@ -155,7 +220,163 @@ func (a *API) handlePostNewEventCommon(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	for _, warning := range warnings {
+		validationWarningsCounter.With(prometheus.Labels{"payload_type": payloadType, "code": string(warning.Code)}).Inc()
+	}
+
+	response := map[string]any{
+		"event": map[string]any{"id": event.ID},
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	respondwith.JSON(w, http.StatusAccepted, response)
+}
+
+// ProblemDetails is the response body for a rejected event payload if the
+// client asks for "application/problem+json" (or "application/json") in the
+// Accept header. It follows RFC 9457, with the extension member "errors".
+type ProblemDetails struct {
+	Type   string                 `json:"type"`
+	Title  string                 `json:"title"`
+	Status int                    `json:"status"`
+	Detail string                 `json:"detail"`
+	Errors tenso.ValidationErrors `json:"errors"`
+}
+
+// Reports a validation error from tenso.ValidationHandler.ValidatePayload().
+// For backwards compatibility, the default response format is plain text.
+func respondWithInvalidPayload(w http.ResponseWriter, r *http.Request, err error) {
+	if !acceptsProblemDetails(r.Header.Get("Accept")) {
+		http.Error(w, "invalid event payload: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	buf, marshalErr := json.Marshal(ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusUnprocessableEntity),
+		Status: http.StatusUnprocessableEntity,
+		Detail: "invalid event payload",
+		Errors: tenso.ValidationErrorsOf(err),
+	})
+	if respondwith.ObfuscatedErrorText(w, marshalErr) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	w.Write(buf)
+}
+
+// Checks whether the given Accept header lists a media type for problem details.
+// Quality values are ignored: Any mention of a JSON type is taken as a request
+// for structured errors.
+func acceptsProblemDetails(acceptHeader string) bool {
+	for field := range strings.SplitSeq(acceptHeader, ",") {
+		mediaType, _, _ := strings.Cut(field, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/problem+json", "application/json":
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryResult is the API representation of a tenso.DeliveryResult.
+type DeliveryResult struct {
+	PayloadType string            `json:"payload_type"`
+	DeliveredAt int64             `json:"delivered_at"`
+	Message     string            `json:"message,omitempty"`
+	ObjectIDs   map[string]string `json:"object_ids,omitempty"`
+}
+
+// SkippedDelivery is the API representation of a tenso.SkippedDelivery.
+type SkippedDelivery struct {
+	PayloadType string `json:"payload_type"`
+	SkippedAt   int64  `json:"skipped_at"`
+	Reason      string `json:"reason"`
+	Message     string `json:"message,omitempty"`
+}
+
+// FailedDelivery is the API representation of a tenso.PendingDelivery that
+// failed permanently.
+type FailedDelivery struct {
+	PayloadType    string `json:"payload_type"`
+	FailedAt       int64  `json:"failed_at"`
+	FailureMessage string `json:"failure_message"`
+}
+
+func (a *API) handleGetDeliveryResults(w http.ResponseWriter, r *http.Request) {
+	httpapi.IdentifyEndpoint(r, "/v1/events/:id/delivery-results")
+	ctx := r.Context()
+
+	token := a.Validator.CheckToken(r)
+	if !token.Require(w, "event:show") {
+		return
+	}
+
+	eventID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+
+	// NOTE: Delivery results and skipped deliveries are kept when the event
+	// itself is garbage-collected, so we do not check whether the event still exists.
+	dbResults, err := tenso.DeliveryResultStore.SelectWhere(ctx, a.DB,
+		`event_id = $1 ORDER BY payload_type`, eventID).Collect()
+	if respondwith.ObfuscatedErrorText(w, err) {
+		return
+	}
+
+	results := make([]DeliveryResult, len(dbResults))
+	for idx, dbResult := range dbResults {
+		var objectIDs map[string]string
+		err := json.Unmarshal([]byte(dbResult.ObjectIDsJSON), &objectIDs)
+		if respondwith.ObfuscatedErrorText(w, err) {
+			return
+		}
+		results[idx] = DeliveryResult{
+			PayloadType: dbResult.PayloadType,
+			DeliveredAt: dbResult.DeliveredAt.Unix(),
+			Message:     dbResult.Message,
+			ObjectIDs:   objectIDs,
+		}
+	}
+
+	dbSkips, err := tenso.SkippedDeliveryStore.SelectWhere(ctx, a.DB,
+		`event_id = $1 ORDER BY payload_type`, eventID).Collect()
+	if respondwith.ObfuscatedErrorText(w, err) {
+		return
+	}
+	skips := make([]SkippedDelivery, len(dbSkips))
+	for idx, dbSkip := range dbSkips {
+		skips[idx] = SkippedDelivery{
+			PayloadType: dbSkip.PayloadType,
+			SkippedAt:   dbSkip.SkippedAt.Unix(),
+			Reason:      dbSkip.Reason,
+			Message:     dbSkip.Message,
+		}
+	}
+
+	dbFailures, err := tenso.PendingDeliveryStore.SelectWhere(ctx, a.DB,
+		`event_id = $1 AND failed_at IS NOT NULL ORDER BY payload_type`, eventID).Collect()
+	if respondwith.ObfuscatedErrorText(w, err) {
+		return
+	}
+	failures := make([]FailedDelivery, len(dbFailures))
+	for idx, dbFailure := range dbFailures {
+		failures[idx] = FailedDelivery{
+			PayloadType:    dbFailure.PayloadType,
+			FailedAt:       dbFailure.FailedAt.Unix(),
+			FailureMessage: dbFailure.FailureMessage,
+		}
+	}
+
+	respondwith.JSON(w, http.StatusOK, map[string]any{
+		"delivery_results":   results,
+		"skipped_deliveries": skips,
+		"failed_deliveries":  failures,
+	})
 }
 
 // Parses the value from a X-Tenso-Routing-Info header.
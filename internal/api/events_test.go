@@ -6,14 +6,19 @@ package api_test
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/sapcc/go-bits/easypg"
 	"github.com/sapcc/go-bits/httptest"
+	"github.com/sapcc/go-bits/must"
 	"go.xyrillian.de/gg/assert"
+	"go.xyrillian.de/gg/jsonmatch"
 	"go.xyrillian.de/gg/pgruntime"
 
+	_ "github.com/sapcc/tenso/internal/handlers"
+	"github.com/sapcc/tenso/internal/tenso"
 	"github.com/sapcc/tenso/internal/test"
 )
 
@@ -57,14 +62,67 @@ func TestPostNewEvent(t *testing.T) {
 		"invalid event payload: expected event = \"foo\", but got \"bar\"\n",
 	)
 
-	// test error cases: no permission
+	// test error cases: invalid payload, with structured errors requested by the client
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithJSONBody(map[string]any{"event": "bar", "value": 42}),
+		httptest.WithHeader("Accept", "application/problem+json, text/plain;q=0.5"),
+	).ExpectHeader(t, "Content-Type", "application/problem+json").ExpectJSON(t, http.StatusUnprocessableEntity, jsonmatch.Object{
+		"type":   "about:blank",
+		"title":  "Unprocessable Entity",
+		"status": 422,
+		"detail": "invalid event payload",
+		"errors": jsonmatch.Array{
+			jsonmatch.Object{
+				"pointer": "/event",
+				"code":    "invalid",
+				"message": `expected event = "foo", but got "bar"`,
+			},
+		},
+	})
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithBody(strings.NewReader(`{"event":"foo","value":"42"}`)),
+		httptest.WithHeader("Content-Type", "application/json"),
+		httptest.WithHeader("Accept", "application/json"),
+	).ExpectJSON(t, http.StatusUnprocessableEntity, jsonmatch.Object{
+		"type":   "about:blank",
+		"title":  "Unprocessable Entity",
+		"status": 422,
+		"detail": "invalid event payload",
+		"errors": jsonmatch.Array{
+			jsonmatch.Object{
+				"pointer": "",
+				"code":    "malformed",
+				"message": "json: cannot unmarshal string into Go struct field testPayload.value of type int",
+			},
+		},
+	})
+
+	// test error cases: no permission for the payload type (this is checked
+	// before validation, so even invalid payloads are rejected with 403)
 	s.Validator.Enforcer.Forbid("event:create")
 	resp := h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
 		httptest.WithJSONBody(body),
 	)
 	assert.Equal(t, resp.StatusCode(), http.StatusForbidden)
+	resp = h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithJSONBody(map[string]any{"event": "bar", "value": 42}),
+	)
+	assert.Equal(t, resp.StatusCode(), http.StatusForbidden)
 	s.Validator.Enforcer.Allow("event:create")
 
+	// test error cases: no permission for the payload attributes (this is
+	// checked after validation, so invalid payloads are reported as such)
+	s.Validator.Enforcer.Forbid("event:create:payload")
+	resp = h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithJSONBody(body),
+	)
+	assert.Equal(t, resp.StatusCode(), http.StatusForbidden)
+	resp = h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithJSONBody(map[string]any{"event": "bar", "value": 42}),
+	)
+	assert.Equal(t, resp.StatusCode(), http.StatusUnprocessableEntity)
+	s.Validator.Enforcer.Allow("event:create:payload")
+
 	// test error cases: malformed X-Tenso-Routing-Info header
 	for _, invalidKeyValuePair := range []string{"target-foobar", "target=", "=foobar"} {
 		t.Run(invalidKeyValuePair, func(t *testing.T) {
@@ -89,10 +147,11 @@ func TestPostNewEvent(t *testing.T) {
 
 	// test successful event ingestion
 	s.Clock.StepBy(1 * time.Minute)
-	resp = h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
 		httptest.WithJSONBody(body),
-	)
-	assert.Equal(t, resp.StatusCode(), http.StatusAccepted)
+	).ExpectJSON(t, http.StatusAccepted, jsonmatch.Object{
+		"event": jsonmatch.Object{"id": 1},
+	})
 
 	tr.DBChanges().AssertEqualf(`
 		INSERT INTO events (id, creator_id, created_at, payload_type, payload, description, routing_info_json) VALUES (1, 1, %[1]d, 'test-foo.v1', '{"event":"foo","value":42}', 'foo event with value 42', '{}');
@@ -104,15 +163,213 @@ func TestPostNewEvent(t *testing.T) {
 	// test that ingestion of a second event from the same user reuses the `users` entry we just made;
 	// also this event includes routing info
 	s.Clock.StepBy(1 * time.Minute)
-	resp = h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
 		httptest.WithJSONBody(map[string]any{"event": "foo", "value": 44}),
 		httptest.WithHeader("X-Tenso-Routing-Info", ",,, target=foobar, priority  = 42  "),
-	)
-	assert.Equal(t, resp.StatusCode(), http.StatusAccepted)
+	).ExpectJSON(t, http.StatusAccepted, jsonmatch.Object{
+		"event": jsonmatch.Object{"id": 2},
+	})
 
 	tr.DBChanges().AssertEqualf(`
 		INSERT INTO events (id, creator_id, created_at, payload_type, payload, description, routing_info_json) VALUES (2, 1, %[1]d, 'test-foo.v1', '{"event":"foo","value":44}', 'foo event with value 44', '{"priority":"42","target":"foobar"}');
 		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (2, 'test-bar.v1', %[1]d, %[1]d);
 		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (2, 'test-baz.v1', %[1]d, %[1]d);
 	`, s.Clock.Now().Unix())
+
+	// test that validation warnings are reported to the client and stored on the event
+	s.Clock.StepBy(1 * time.Minute)
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithJSONBody(map[string]any{"event": "foo", "value": 46, "routing_info": map[string]string{"target": "foobar"}}),
+	).ExpectJSON(t, http.StatusAccepted, jsonmatch.Object{
+		"event": jsonmatch.Object{"id": 3},
+		"warnings": jsonmatch.Array{
+			jsonmatch.Object{
+				"pointer": "/routing_info",
+				"code":    "not-allowed",
+				"message": "field routing_info is ignored in incoming events",
+			},
+		},
+	})
+
+	tr.DBChanges().AssertEqualf(`
+		INSERT INTO events (id, creator_id, created_at, payload_type, payload, description, routing_info_json, warnings_json) VALUES (3, 1, %[1]d, 'test-foo.v1', '{"event":"foo","routing_info":{"target":"foobar"},"value":46}', 'foo event with value 46', '{}', '[{"pointer":"/routing_info","code":"not-allowed","message":"field routing_info is ignored in incoming events"}]');
+		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (3, 'test-bar.v1', %[1]d, %[1]d);
+		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (3, 'test-baz.v1', %[1]d, %[1]d);
+	`, s.Clock.Now().Unix())
+
+	// test that CloudEvent headers are ignored for payload types other than "cloudevent.v1"
+	s.Clock.StepBy(1 * time.Minute)
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=test-foo.v1",
+		httptest.WithJSONBody(map[string]any{"event": "foo", "value": 48}),
+		httptest.WithHeader("Ce-Specversion", "1.0"),
+	).ExpectJSON(t, http.StatusAccepted, jsonmatch.Object{
+		"event": jsonmatch.Object{"id": 4},
+	})
+
+	tr.DBChanges().AssertEqualf(`
+		INSERT INTO events (id, creator_id, created_at, payload_type, payload, description, routing_info_json) VALUES (4, 1, %[1]d, 'test-foo.v1', '{"event":"foo","value":48}', 'foo event with value 48', '{}');
+		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (4, 'test-bar.v1', %[1]d, %[1]d);
+		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (4, 'test-baz.v1', %[1]d, %[1]d);
+	`, s.Clock.Now().Unix())
+}
+
+func TestPostNewCloudEventInBinaryMode(t *testing.T) {
+	t.Setenv("TENSO_REGION_REGEX", "[a-z]{2}-[a-z]{2}-[0-9]")
+	t.Setenv("TENSO_CLOUDEVENTS_TYPES", "com.example.foo=test-foo.v1")
+	s := test.NewSetup(t,
+		test.WithAPI,
+		test.WithRoute("cloudevent.v1 -> test-bar.v1"),
+	)
+	h := s.Handler
+	ctx := t.Context()
+
+	tr, tr0 := easypg.NewTracker(t, s.DB.DB)
+	tr0.AssertEmpty()
+
+	header := http.Header{
+		"Ce-Specversion": {"1.0"},
+		"Ce-Id":          {"42"},
+		"Ce-Source":      {"https://example.com/foo/42"},
+		"Ce-Type":        {"com.example.foo"},
+		"Content-Type":   {"application/json"},
+	}
+
+	// test error case: the event data is not JSON even though the content type says so
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=cloudevent.v1",
+		httptest.WithHeaders(header),
+		httptest.WithBody(strings.NewReader(`event=foo&value=42`)),
+	).ExpectText(t, http.StatusUnprocessableEntity,
+		"invalid event payload: event data is not valid JSON\n",
+	)
+	tr.DBChanges().AssertEmpty()
+
+	// test successful event ingestion: the event is stored in structured content mode
+	h.RespondTo(ctx, "POST /v1/events/new?payload_type=cloudevent.v1",
+		httptest.WithHeaders(header),
+		httptest.WithBody(strings.NewReader(`{"event":"foo","value":42}`)),
+	).ExpectJSON(t, http.StatusAccepted, jsonmatch.Object{
+		"event": jsonmatch.Object{"id": 1},
+	})
+
+	tr.DBChanges().AssertEqualf(`
+		INSERT INTO events (id, creator_id, created_at, payload_type, payload, description, routing_info_json) VALUES (1, 1, %[1]d, 'cloudevent.v1', '{"data":{"event":"foo","value":42},"datacontenttype":"application/json","id":"42","source":"https://example.com/foo/42","specversion":"1.0","type":"com.example.foo"}', 'foo event with value 42', '{}');
+		INSERT INTO pending_deliveries (event_id, payload_type, next_conversion_at, next_delivery_at) VALUES (1, 'test-bar.v1', %[1]d, %[1]d);
+		INSERT INTO users (id, uuid, name, domain_name) VALUES (1, 'testuserid', 'testusername', 'testdomainname');
+	`, s.Clock.Now().Unix())
+}
+
+func TestGetDeliveryResults(t *testing.T) {
+	t.Setenv("TENSO_REGION_REGEX", "[a-z]{2}-[a-z]{2}-[0-9]")
+	s := test.NewSetup(t,
+		test.WithAPI,
+		test.WithRoute("test-foo.v1 -> test-bar.v1"),
+		test.WithRoute("test-foo.v1 -> test-baz.v1"),
+		test.WithRoute("test-foo.v1 -> test-qux.v1"),
+		test.WithRoute("test-foo.v1 -> test-quux.v1"),
+	)
+	h := s.Handler
+	ctx := t.Context()
+
+	// delivery results and skipped deliveries can be queried even after the
+	// event itself was deleted, so we do not need to create an event for them
+	s.Clock.StepBy(1 * time.Hour)
+	must.SucceedT(t, tenso.DeliveryResultStore.Insert(ctx, s.DB,
+		&tenso.DeliveryResult{
+			EventID:       1,
+			PayloadType:   "test-baz.v1",
+			DeliveredAt:   s.Clock.Now(),
+			ObjectIDsJSON: "{}",
+		},
+		&tenso.DeliveryResult{
+			EventID:       1,
+			PayloadType:   "test-bar.v1",
+			DeliveredAt:   s.Clock.Now(),
+			Message:       "created bar 42",
+			ObjectIDsJSON: `{"value":"42"}`,
+		},
+	))
+	must.SucceedT(t, tenso.SkippedDeliveryStore.Insert(ctx, s.DB,
+		&tenso.SkippedDelivery{
+			EventID:     1,
+			PayloadType: "test-quux.v1",
+			SkippedAt:   s.Clock.Now(),
+			Reason:      "zero-value",
+			Message:     "nothing to deliver",
+		},
+	))
+
+	// failed deliveries are reported while the event still exists
+	user := tenso.User{UUID: "testuserid", Name: "testusername", DomainName: "testdomainname"}
+	must.SucceedT(t, tenso.UserStore.Insert(ctx, s.DB, &user))
+	event := tenso.Event{
+		CreatorID:       user.ID,
+		CreatedAt:       s.Clock.Now(),
+		PayloadType:     "test-foo.v1",
+		Payload:         `{"foo":42}`,
+		Description:     "foo event",
+		RoutingInfoJSON: "{}",
+		WarningsJSON:    "[]",
+	}
+	must.SucceedT(t, tenso.EventStore.Insert(ctx, s.DB, &event))
+	assert.Equal(t, event.ID, 1)
+	now := s.Clock.Now()
+	payload := `{"qux":42}`
+	must.SucceedT(t, tenso.PendingDeliveryStore.Insert(ctx, s.DB,
+		&tenso.PendingDelivery{
+			EventID:          event.ID,
+			PayloadType:      "test-qux.v1",
+			Payload:          &payload,
+			ConvertedAt:      &now,
+			NextConversionAt: s.Clock.Now(),
+			NextDeliveryAt:   s.Clock.Now(),
+			FailedAt:         &now,
+			FailureMessage:   "qux rejected the payload",
+		},
+	))
+
+	// test error cases
+	h.RespondTo(ctx, "GET /v1/events/foo/delivery-results").
+		ExpectText(t, http.StatusNotFound, "event not found\n")
+	s.Validator.Enforcer.Forbid("event:show")
+	resp := h.RespondTo(ctx, "GET /v1/events/1/delivery-results")
+	assert.Equal(t, resp.StatusCode(), http.StatusForbidden)
+	s.Validator.Enforcer.Allow("event:show")
+
+	// test successful queries
+	h.RespondTo(ctx, "GET /v1/events/1/delivery-results").
+		ExpectJSON(t, http.StatusOK, jsonmatch.Object{
+			"delivery_results": []jsonmatch.Object{
+				{
+					"payload_type": "test-bar.v1",
+					"delivered_at": s.Clock.Now().Unix(),
+					"message":      "created bar 42",
+					"object_ids":   jsonmatch.Object{"value": "42"},
+				},
+				{
+					"payload_type": "test-baz.v1",
+					"delivered_at": s.Clock.Now().Unix(),
+				},
+			},
+			"skipped_deliveries": []jsonmatch.Object{
+				{
+					"payload_type": "test-quux.v1",
+					"skipped_at":   s.Clock.Now().Unix(),
+					"reason":       "zero-value",
+					"message":      "nothing to deliver",
+				},
+			},
+			"failed_deliveries": []jsonmatch.Object{
+				{
+					"payload_type":    "test-qux.v1",
+					"failed_at":       s.Clock.Now().Unix(),
+					"failure_message": "qux rejected the payload",
+				},
+			},
+		})
+	h.RespondTo(ctx, "GET /v1/events/2/delivery-results").
+		ExpectJSON(t, http.StatusOK, jsonmatch.Object{
+			"delivery_results":   []jsonmatch.Object{},
+			"skipped_deliveries": []jsonmatch.Object{},
+			"failed_deliveries":  []jsonmatch.Object{},
+		})
 }
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	policy "github.com/databus23/goslo.policy"
+	"github.com/sapcc/go-bits/gopherpolicy"
+)
+
+// Policy rules that were introduced after the initial release. If a policy
+// file does not define them, they allow everything, so that existing policy
+// files keep working without changes.
+var defaultPolicyRules = map[string]string{
+	"event:create:payload":           "@",
+	"event:create_synthetic:payload": "@",
+}
+
+// LoadPolicyFile creates the policy enforcer for the API from the given
+// policy.json file.
+func LoadPolicyFile(path string) (gopherpolicy.Enforcer, error) {
+	if strings.HasSuffix(path, ".yaml") {
+		return nil, fmt.Errorf("cannot parse %s because YAML support is not available", path)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules map[string]string
+	err = json.Unmarshal(buf, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing structure of %s: %w", path, err)
+	}
+	if rules == nil {
+		rules = make(map[string]string, len(defaultPolicyRules))
+	}
+	for name, rule := range defaultPolicyRules {
+		if _, exists := rules[name]; !exists {
+			rules[name] = rule
+		}
+	}
+	enforcer, err := policy.NewEnforcer(rules)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing policy rules found in %s: %w", path, err)
+	}
+	return enforcer, nil
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	policy "github.com/databus23/goslo.policy"
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/api"
+)
+
+func TestLoadPolicyFile(t *testing.T) {
+	ctx := policy.Context{
+		Auth:    map[string]string{"user_name": "awx-qa-de-1"},
+		Request: map[string]string{"target.payload_type": "infra-workflow-from-awx.v1", "target.region": "qa-de-2"},
+	}
+
+	// a policy file from before the introduction of the "...:payload" rules
+	// does not restrict events based on their payload
+	path := filepath.Join(t.TempDir(), "policy.json")
+	must.SucceedT(t, os.WriteFile(path, []byte(`{"event:create":"user_name:awx-qa-de-1","event:create_synthetic":"!"}`), 0o666))
+	enforcer := must.ReturnT(api.LoadPolicyFile(path))(t)
+	assert.Equal(t, enforcer.Enforce("event:create", ctx), true)
+	assert.Equal(t, enforcer.Enforce("event:create:payload", ctx), true)
+	assert.Equal(t, enforcer.Enforce("event:create_synthetic", ctx), false)
+	assert.Equal(t, enforcer.Enforce("event:create_synthetic:payload", ctx), true)
+
+	// if the "...:payload" rules are given, they are used as written
+	must.SucceedT(t, os.WriteFile(path, []byte(`{"event:create":"user_name:awx-qa-de-1","event:create:payload":"'qa-de-1':%(target.region)s"}`), 0o666))
+	enforcer = must.ReturnT(api.LoadPolicyFile(path))(t)
+	assert.Equal(t, enforcer.Enforce("event:create", ctx), true)
+	assert.Equal(t, enforcer.Enforce("event:create:payload", ctx), false)
+	ctx.Request["target.region"] = "qa-de-1"
+	assert.Equal(t, enforcer.Enforce("event:create:payload", ctx), true)
+}
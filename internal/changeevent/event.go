@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package changeevent defines the canonical change event format. Events of all
+// ingress payload types can be translated into this format, and target
+// handlers consume it, so that each new ingress format only needs a
+// translation into this format, and each new target only needs to consume it.
+//
+// The format is versioned through its payload type. Fields may be added in
+// backwards-compatible ways within a version. Any other change requires a new
+// version of the payload type.
+package changeevent
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/sapcc/go-api-declarations/deployevent"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+// PayloadType is the payload type of events in this format.
+const PayloadType = "change-event.v1"
+
+// Family identifies which kind of change an Event describes. Targets can use
+// this to apply family-specific configuration, e.g. mapping rules for
+// ServiceNow.
+type Family string
+
+const (
+	// FamilyActiveDirectoryDeployment is used for deployments of Active Directory.
+	FamilyActiveDirectoryDeployment Family = "active-directory-deployment"
+	// FamilyAWXWorkflow is used for AWX workflow runs.
+	FamilyAWXWorkflow Family = "awx-workflow"
+	// FamilyHelmDeployment is used for deployments of Helm releases.
+	FamilyHelmDeployment Family = "helm-deployment"
+	// FamilyTerraformDeployment is used for Terraform runs.
+	FamilyTerraformDeployment Family = "terraform-deployment"
+)
+
+// IsValid returns whether this is one of the Family values declared above.
+func (f Family) IsValid() bool {
+	switch f {
+	case FamilyActiveDirectoryDeployment, FamilyAWXWorkflow, FamilyHelmDeployment, FamilyTerraformDeployment:
+		return true
+	default:
+		return false
+	}
+}
+
+// ObjectKind is an enumeration of the types of objects that can be affected by
+// a change. The values are identical to those of servicenow.ConfigurationItemKind.
+type ObjectKind string
+
+const (
+	// ObjectKindHelmRelease refers to a Helm release.
+	// The name is formatted as "<cluster>/<namespace>/<release>".
+	ObjectKindHelmRelease ObjectKind = "helm-release"
+	// ObjectKindCluster refers to a Kubernetes cluster by name.
+	ObjectKindCluster ObjectKind = "cluster"
+	// ObjectKindHost refers to a single server by its FQDN.
+	ObjectKindHost ObjectKind = "host"
+	// ObjectKindBuildingBlock refers to a building block by its ID, e.g. "bb091".
+	ObjectKindBuildingBlock ObjectKind = "building-block"
+)
+
+// IsValid returns whether this is one of the ObjectKind values declared above.
+func (k ObjectKind) IsValid() bool {
+	switch k {
+	case ObjectKindHelmRelease, ObjectKindCluster, ObjectKindHost, ObjectKindBuildingBlock:
+		return true
+	default:
+		return false
+	}
+}
+
+// Object appears in type Event.
+type Object struct {
+	Kind ObjectKind `json:"kind"`
+	Name string     `json:"name"`
+}
+
+// Event is the payload of payload type "change-event.v1".
+type Event struct {
+	Family Family `json:"family"`
+	// SourcePayloadType is the payload type that this event was translated from.
+	// It is empty for events that were submitted in this format directly.
+	SourcePayloadType string `json:"source_payload_type,omitempty"`
+
+	// Summary is a single line, Description can have multiple lines.
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+
+	// StartedAt must always be set. FinishedAt and Outcome are not set for
+	// changes that are still in progress. Outcome can also be empty for
+	// finished changes if the source format does not report an outcome that
+	// corresponds to one of the deployevent.Outcome values.
+	StartedAt  *time.Time          `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	Outcome    deployevent.Outcome `json:"outcome,omitempty"`
+	// ErrorMessages explain why the change did not succeed.
+	ErrorMessages []string `json:"error_messages,omitempty"`
+
+	// Exactly one of Region and AvailabilityZone is set.
+	Region           string `json:"region,omitempty"`
+	AvailabilityZone string `json:"availability_zone,omitempty"`
+
+	// Initiator is the user ID of the user who triggered this change, if known.
+	Initiator string `json:"initiator,omitempty"`
+	// CorrelationID identifies the change across the events for its start and
+	// its finish. It must be set for changes that are still in progress.
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// AffectedObjects lists the objects affected by this change, most specific
+	// first. ConfigurationItem is set if the source format identifies a
+	// configuration item in ServiceNow directly.
+	AffectedObjects   []Object `json:"affected_objects,omitempty"`
+	ConfigurationItem string   `json:"configuration_item,omitempty"`
+
+	// Family-specific details (all optional).
+	Pipeline        *deployevent.Pipeline          `json:"pipeline,omitempty"`
+	GitRepos        map[string]deployevent.GitRepo `json:"git,omitempty"`
+	HelmReleases    []*deployevent.HelmRelease     `json:"helm_releases,omitempty"`
+	TerraformRuns   []*deployevent.TerraformRun    `json:"terraform_runs,omitempty"`
+	AWXWorkflowName string                         `json:"awx_workflow_name,omitempty"`
+}
+
+// IsFinished returns whether this event describes a finished change.
+func (e Event) IsFinished() bool {
+	return e.FinishedAt != nil
+}
+
+// Validate checks the event for consistency. Validation errors are appended
+// to `errs`.
+func (e Event) Validate(regionRx *regexp.Regexp, errs *tenso.ValidationErrors) {
+	if !e.Family.IsValid() {
+		errs.Add("/family", tenso.ValidationErrorInvalid, "value for field family is invalid: %q", e.Family)
+	}
+	if e.Summary == "" {
+		errs.Add("/summary", tenso.ValidationErrorMissing, "value for field summary is missing")
+	}
+	if e.StartedAt == nil {
+		errs.Add("/started_at", tenso.ValidationErrorMissing, "value for field started_at is missing")
+	}
+
+	switch {
+	case e.IsFinished() && e.Outcome != "" && !e.Outcome.IsKnownInputValue() && e.Outcome != deployevent.OutcomePartiallyDeployed:
+		errs.Add("/outcome", tenso.ValidationErrorInvalid, "value for field outcome is invalid: %q", e.Outcome)
+	case !e.IsFinished() && e.Outcome != "":
+		errs.Add("/outcome", tenso.ValidationErrorNotAllowed, "field outcome may not be set if finished_at is not set")
+	case !e.IsFinished() && e.CorrelationID == "":
+		errs.Add("/correlation_id", tenso.ValidationErrorMissing, "field correlation_id must be set if finished_at is not set")
+	}
+
+	switch {
+	case e.Region != "" && e.AvailabilityZone != "":
+		errs.Add("/availability_zone", tenso.ValidationErrorNotAllowed, "fields region and availability_zone may not be set at the same time")
+	case e.Region != "":
+		if !regionRx.MatchString(e.Region) {
+			errs.Add("/region", tenso.ValidationErrorInvalid, "value for field region is invalid: %q", e.Region)
+		}
+	case e.AvailabilityZone == "":
+		errs.Add("/region", tenso.ValidationErrorMissing, "one of the fields region and availability_zone must be set")
+	}
+
+	for idx, obj := range e.AffectedObjects {
+		if !obj.Kind.IsValid() {
+			errs.Add(fmt.Sprintf("/affected_objects/%d/kind", idx), tenso.ValidationErrorInvalid,
+				"value for field affected_objects[%d].kind is invalid: %q", idx, obj.Kind)
+		}
+		if obj.Name == "" {
+			errs.Add(fmt.Sprintf("/affected_objects/%d/name", idx), tenso.ValidationErrorMissing,
+				"value for field affected_objects[%d].name is missing", idx)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cloudevents implements the parts of the CNCF CloudEvents
+// specification (version 1.0) that Tenso needs to accept and produce events in
+// the JSON event format, either in structured or in binary content mode.
+//
+// Reference: <https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/spec.md>
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// SpecVersion is the only version of the CloudEvents specification that we support.
+	SpecVersion = "1.0"
+	// ContentType is the media type of a CloudEvent in structured content mode.
+	ContentType = "application/cloudevents+json"
+	// PayloadType is the Tenso payload type for CloudEvents on ingress.
+	PayloadType = "cloudevent.v1"
+)
+
+var (
+	// extension attribute names must consist of lower-case letters and digits only
+	extensionNameRx = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+	// these attributes are handled explicitly by type Event
+	knownAttributes = map[string]bool{
+		"specversion":     true,
+		"id":              true,
+		"source":          true,
+		"type":            true,
+		"subject":         true,
+		"time":            true,
+		"datacontenttype": true,
+		"dataschema":      true,
+		"data":            true,
+		"data_base64":     true,
+	}
+)
+
+// Event is a CloudEvent in the JSON event format.
+type Event struct {
+	SpecVersion     string
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            *time.Time
+	DataContentType string
+	DataSchema      string
+	// Data contains the event data. When serialized as JSON, this is put in the
+	// "data" attribute if DataContentType is JSON, or otherwise base64-encoded
+	// in the "data_base64" attribute.
+	Data []byte
+	// Extensions contains all extension context attributes.
+	Extensions map[string]string
+}
+
+// HasJSONData returns whether the event data is a JSON document.
+func (e Event) HasJSONData() bool {
+	return isJSONContentType(e.DataContentType)
+}
+
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		// per spec, absence of datacontenttype implies application/json in the JSON event format
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Validate checks that all required context attributes are present and well-formed.
+func (e Event) Validate() error {
+	switch {
+	case e.SpecVersion != SpecVersion:
+		return fmt.Errorf("unsupported value for attribute specversion: %q", e.SpecVersion)
+	case e.ID == "":
+		return errors.New("missing value for attribute id")
+	case e.Source == "":
+		return errors.New("missing value for attribute source")
+	case e.Type == "":
+		return errors.New("missing value for attribute type")
+	}
+	for name := range e.Extensions {
+		if knownAttributes[name] || !extensionNameRx.MatchString(name) {
+			return fmt.Errorf("invalid name for extension attribute: %q", name)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (e Event) MarshalJSON() ([]byte, error) {
+	data := make(map[string]any, len(e.Extensions)+9)
+	for name, value := range e.Extensions {
+		data[name] = value
+	}
+	data["specversion"] = e.SpecVersion
+	data["id"] = e.ID
+	data["source"] = e.Source
+	data["type"] = e.Type
+	if e.Subject != "" {
+		data["subject"] = e.Subject
+	}
+	if e.Time != nil {
+		data["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if e.DataContentType != "" {
+		data["datacontenttype"] = e.DataContentType
+	}
+	if e.DataSchema != "" {
+		data["dataschema"] = e.DataSchema
+	}
+	if e.Data != nil {
+		if e.HasJSONData() {
+			if !json.Valid(e.Data) {
+				return nil, errors.New("event data is not valid JSON")
+			}
+			data["data"] = json.RawMessage(e.Data)
+		} else {
+			data["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (e *Event) UnmarshalJSON(buf []byte) error {
+	var attrs map[string]json.RawMessage
+	err := json.Unmarshal(buf, &attrs)
+	if err != nil {
+		return err
+	}
+
+	getString := func(name string) (string, error) {
+		raw, exists := attrs[name]
+		if !exists {
+			return "", nil
+		}
+		var s string
+		err := json.Unmarshal(raw, &s)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for attribute %s: %w", name, err)
+		}
+		return s, nil
+	}
+
+	var result Event
+	for _, field := range []struct {
+		Name   string
+		Target *string
+	}{
+		{"specversion", &result.SpecVersion},
+		{"id", &result.ID},
+		{"source", &result.Source},
+		{"type", &result.Type},
+		{"subject", &result.Subject},
+		{"datacontenttype", &result.DataContentType},
+		{"dataschema", &result.DataSchema},
+	} {
+		*field.Target, err = getString(field.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	timeStr, err := getString("time")
+	if err != nil {
+		return err
+	}
+	if timeStr != "" {
+		t, err := time.Parse(time.RFC3339Nano, timeStr)
+		if err != nil {
+			return fmt.Errorf("invalid value for attribute time: %w", err)
+		}
+		result.Time = &t
+	}
+
+	_, hasData := attrs["data"]
+	_, hasDataBase64 := attrs["data_base64"]
+	switch {
+	case hasData && hasDataBase64:
+		return errors.New("attributes data and data_base64 may not be set at the same time")
+	case hasData:
+		result.Data = []byte(attrs["data"])
+	case hasDataBase64:
+		encoded, err := getString("data_base64")
+		if err != nil {
+			return err
+		}
+		result.Data, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid value for attribute data_base64: %w", err)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(attrs)) {
+		if knownAttributes[name] {
+			continue
+		}
+		if result.Extensions == nil {
+			result.Extensions = make(map[string]string)
+		}
+		// extension attributes may have any scalar type, but we only care about their string representation
+		var value any
+		err := json.Unmarshal(attrs[name], &value)
+		if err != nil {
+			return fmt.Errorf("invalid value for attribute %s: %w", name, err)
+		}
+		switch value := value.(type) {
+		case string:
+			result.Extensions[name] = value
+		case bool, float64:
+			result.Extensions[name] = string(attrs[name])
+		default:
+			return fmt.Errorf("invalid value for attribute %s: expected a scalar value", name)
+		}
+	}
+
+	*e = result
+	return nil
+}
+
+// IsBinaryMode returns whether the given HTTP request headers describe a
+// CloudEvent in binary content mode.
+func IsBinaryMode(header http.Header) bool {
+	return header.Get("Ce-Specversion") != ""
+}
+
+// FromBinaryMode reconstructs a CloudEvent from an HTTP message in binary
+// content mode, where context attributes are transported in "ce-" headers and
+// the request body contains the event data.
+func FromBinaryMode(header http.Header, body []byte) (Event, error) {
+	result := Event{
+		DataContentType: header.Get("Content-Type"),
+		Data:            body,
+	}
+	for key, values := range header {
+		name, ok := strings.CutPrefix(strings.ToLower(key), "ce-")
+		if !ok {
+			continue
+		}
+		if len(values) != 1 {
+			return Event{}, fmt.Errorf("expected exactly one value for header %s", key)
+		}
+		value := values[0]
+
+		switch name {
+		case "specversion":
+			result.SpecVersion = value
+		case "id":
+			result.ID = value
+		case "source":
+			result.Source = value
+		case "type":
+			result.Type = value
+		case "subject":
+			result.Subject = value
+		case "dataschema":
+			result.DataSchema = value
+		case "time":
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return Event{}, fmt.Errorf("invalid value for header %s: %w", key, err)
+			}
+			result.Time = &t
+		default:
+			if result.Extensions == nil {
+				result.Extensions = make(map[string]string)
+			}
+			result.Extensions[name] = value
+		}
+	}
+	if len(result.Data) == 0 {
+		result.Data = nil
+	}
+	if result.Data != nil && result.HasJSONData() && !json.Valid(result.Data) {
+		return Event{}, errors.New("event data is not valid JSON")
+	}
+	return result, result.Validate()
+}
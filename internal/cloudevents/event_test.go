@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudevents_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/cloudevents"
+)
+
+func TestStructuredModeRoundtrip(t *testing.T) {
+	input := `{"specversion":"1.0","id":"A234-1234-1234","source":"https://example.com/builds/42","type":"com.example.deployment","time":"2026-04-05T17:31:00Z","comexampleextension":"value","comexampleflag":true,"data":{"foo":42}}`
+
+	var event cloudevents.Event
+	must.SucceedT(t, json.Unmarshal([]byte(input), &event))
+	must.SucceedT(t, event.Validate())
+	assert.Equal(t, event.ID, "A234-1234-1234")
+	assert.Equal(t, event.Type, "com.example.deployment")
+	assert.Equal(t, event.Time.Equal(time.Date(2026, 4, 5, 17, 31, 0, 0, time.UTC)), true)
+	assert.Equal(t, event.HasJSONData(), true)
+	assert.Equal(t, string(event.Data), `{"foo":42}`)
+	assert.Equal(t, event.Extensions["comexampleextension"], "value")
+	assert.Equal(t, event.Extensions["comexampleflag"], "true")
+
+	// the JSON encoder sorts object keys, so the output is deterministic
+	output := must.ReturnT(json.Marshal(event))(t)
+	assert.Equal(t, string(output), `{"comexampleextension":"value","comexampleflag":"true","data":{"foo":42},"id":"A234-1234-1234","source":"https://example.com/builds/42","specversion":"1.0","time":"2026-04-05T17:31:00Z","type":"com.example.deployment"}`)
+}
+
+func TestNonJSONData(t *testing.T) {
+	event := cloudevents.Event{
+		SpecVersion:     cloudevents.SpecVersion,
+		ID:              "1",
+		Source:          "/test",
+		Type:            "com.example.blob",
+		DataContentType: "application/octet-stream",
+		Data:            []byte("hello"),
+	}
+	output := must.ReturnT(json.Marshal(event))(t)
+	assert.Equal(t, string(output), `{"data_base64":"aGVsbG8=","datacontenttype":"application/octet-stream","id":"1","source":"/test","specversion":"1.0","type":"com.example.blob"}`)
+
+	var parsed cloudevents.Event
+	must.SucceedT(t, json.Unmarshal(output, &parsed))
+	assert.Equal(t, parsed.HasJSONData(), false)
+	assert.Equal(t, string(parsed.Data), "hello")
+}
+
+func TestBinaryMode(t *testing.T) {
+	header := http.Header{}
+	assert.Equal(t, cloudevents.IsBinaryMode(header), false)
+
+	header.Set("Content-Type", "application/json")
+	header.Set("Ce-Specversion", "1.0")
+	header.Set("Ce-Id", "42")
+	header.Set("Ce-Source", "https://example.com/builds/42")
+	header.Set("Ce-Type", "com.example.deployment")
+	header.Set("Ce-Comexampleextension", "value")
+	assert.Equal(t, cloudevents.IsBinaryMode(header), true)
+
+	event := must.ReturnT(cloudevents.FromBinaryMode(header, []byte(`{"foo":42}`)))(t)
+	assert.Equal(t, event.ID, "42")
+	assert.Equal(t, event.Source, "https://example.com/builds/42")
+	assert.Equal(t, event.Type, "com.example.deployment")
+	assert.Equal(t, event.DataContentType, "application/json")
+	assert.Equal(t, string(event.Data), `{"foo":42}`)
+	assert.Equal(t, event.Extensions["comexampleextension"], "value")
+
+	// required attributes are checked
+	header.Del("Ce-Source")
+	_, err := cloudevents.FromBinaryMode(header, []byte(`{"foo":42}`))
+	assert.ErrEqual(t, err, "missing value for attribute source")
+
+	// the body must be valid JSON if the content type says so (or if there is no content type)
+	header.Set("Ce-Source", "https://example.com/builds/42")
+	_, err = cloudevents.FromBinaryMode(header, []byte(`foo=42`))
+	assert.ErrEqual(t, err, "event data is not valid JSON")
+	header.Del("Content-Type")
+	_, err = cloudevents.FromBinaryMode(header, []byte(`foo=42`))
+	assert.ErrEqual(t, err, "event data is not valid JSON")
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	event = must.ReturnT(cloudevents.FromBinaryMode(header, []byte(`foo=42`)))(t)
+	assert.Equal(t, string(event.Data), `foo=42`)
+}
+
+func TestValidationErrors(t *testing.T) {
+	testCases := map[string]string{
+		`{"specversion":"0.3","id":"1","source":"/test","type":"foo"}`:                               `unsupported value for attribute specversion: "0.3"`,
+		`{"specversion":"1.0","source":"/test","type":"foo"}`:                                        `missing value for attribute id`,
+		`{"specversion":"1.0","id":"1","source":"/test"}`:                                            `missing value for attribute type`,
+		`{"specversion":"1.0","id":"1","source":"/test","type":"foo","Bad-Name":"x"}`:                `invalid name for extension attribute: "Bad-Name"`,
+		`{"specversion":"1.0","id":"1","source":"/test","type":"foo","data":1,"data_base64":"AA=="}`: `attributes data and data_base64 may not be set at the same time`,
+	}
+	for input, expectedError := range testCases {
+		var event cloudevents.Event
+		err := json.Unmarshal([]byte(input), &event)
+		if err == nil {
+			err = event.Validate()
+		}
+		assert.ErrEqual(t, err, expectedError)
+	}
+}
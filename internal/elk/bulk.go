@@ -0,0 +1,221 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package elk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+// IndexTemplateData is the data that is available when rendering the "index" attribute of an Endpoint.
+type IndexTemplateData struct {
+	// The payload type of the delivered payload, e.g. "helm-deployment-to-elk.v1".
+	PayloadType string
+	// The team owning the event, or "unknown" if not known.
+	Team string
+	// The time when the event happened, or the current time if not known.
+	Time time.Time
+}
+
+// bulkClient delivers documents through the Elasticsearch/OpenSearch bulk API.
+type bulkClient struct {
+	url           string
+	indexTemplate *template.Template
+	authHeader    string
+	httpClient    *http.Client
+}
+
+func newBulkClient(e *Endpoint) (*bulkClient, error) {
+	if e.URL == "" {
+		return nil, errors.New(`missing "url" attribute`)
+	}
+	if !strings.HasPrefix(e.URL, "http://") && !strings.HasPrefix(e.URL, "https://") {
+		return nil, fmt.Errorf(`invalid value for "url" attribute: %q`, e.URL)
+	}
+	if e.IndexTemplate == "" {
+		return nil, errors.New(`missing "index" attribute`)
+	}
+	indexTemplate, err := template.New("index").Option("missingkey=error").Parse(e.IndexTemplate)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid value for "index" attribute: %w`, err)
+	}
+	// check that the template only refers to fields that actually exist
+	err = indexTemplate.Execute(io.Discard, IndexTemplateData{PayloadType: "test", Team: "test", Time: time.Now()})
+	if err != nil {
+		return nil, fmt.Errorf(`invalid value for "index" attribute: %w`, err)
+	}
+
+	var authHeader string
+	switch {
+	case e.Username != "" && e.APIKeyPath != "":
+		return nil, errors.New(`"username" and "api_key_path" may not be given together`)
+	case e.Username != "":
+		if e.PasswordPath == "" {
+			return nil, errors.New(`"password_path" must be given together with "username"`)
+		}
+		password, err := tenso.ReadSecretFile(e.PasswordPath)
+		if err != nil {
+			return nil, err
+		}
+		authHeader = "Basic " + basicAuth(e.Username, password)
+	case e.PasswordPath != "":
+		return nil, errors.New(`"password_path" must be given together with "username"`)
+	case e.APIKeyPath != "":
+		apiKey, err := tenso.ReadSecretFile(e.APIKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		authHeader = "ApiKey " + apiKey
+	}
+
+	return &bulkClient{
+		url:           strings.TrimSuffix(e.URL, "/") + "/_bulk",
+		indexTemplate: indexTemplate,
+		authHeader:    authHeader,
+		httpClient: &http.Client{
+			Timeout: e.timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: e.tlsConfig,
+				Proxy:           http.ProxyFromEnvironment,
+			},
+		},
+	}, nil
+}
+
+// Reference: <https://www.rfc-editor.org/rfc/rfc7617#section-2>
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+// Deliver indexes a single document.
+func (c *bulkClient) Deliver(ctx context.Context, payloadType string, doc Document) (*tenso.DeliveryLog, error) {
+	// render index name
+	data := IndexTemplateData{
+		PayloadType: payloadType,
+		Team:        doc.Team,
+		Time:        time.Now().UTC(),
+	}
+	if data.Team == "" {
+		data.Team = "unknown"
+	}
+	if doc.Time != nil {
+		data.Time = doc.Time.UTC()
+	}
+	var indexName strings.Builder
+	err := c.indexTemplate.Execute(&indexName, data)
+	if err != nil {
+		return nil, tenso.Permanent(fmt.Errorf("cannot render index name: %w", err))
+	}
+
+	// if the caller cannot identify the delivery, the document ID is derived
+	// from the payload, so that retried deliveries do not create duplicate documents
+	docID := doc.ID
+	if docID == "" {
+		checksum := sha256.Sum256(doc.Payload)
+		docID = hex.EncodeToString(checksum[:])
+	}
+	action := map[string]any{
+		"index": map[string]string{
+			"_index": indexName.String(),
+			"_id":    docID,
+		},
+	}
+	actionBytes, err := json.Marshal(action)
+	if err != nil {
+		return nil, err
+	}
+
+	// the bulk API wants every document on one line
+	var body bytes.Buffer
+	body.Write(actionBytes)
+	body.WriteByte('\n')
+	err = json.Compact(&body, doc.Payload)
+	if err != nil {
+		return nil, tenso.Permanent(err)
+	}
+	body.WriteByte('\n')
+
+	// send request
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("while preparing request for POST %s: %w", c.url, err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if c.authHeader != "" {
+		req.Header.Set("Authorization", c.authHeader)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("during POST %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("while reading response body for POST %s: %w", c.url, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("POST %s failed with status %d and response: %q", c.url, resp.StatusCode, string(respBytes))
+		if tenso.IsPermanentFailureStatus(resp.StatusCode) {
+			return nil, tenso.Permanent(err)
+		}
+		return nil, err
+	}
+
+	// even if the request as a whole succeeded, the indexing of individual
+	// documents may have failed
+	var respData bulkResponse
+	err = json.Unmarshal(respBytes, &respData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse response body for POST %s: %w", c.url, err)
+	}
+	if len(respData.Items) != 1 {
+		return nil, fmt.Errorf("expected POST %s to report on 1 item, but got %d items", c.url, len(respData.Items))
+	}
+	for _, result := range respData.Items[0] {
+		if result.Status < 300 {
+			return &tenso.DeliveryLog{
+				Message: fmt.Sprintf("indexed as %s/%s (%s)", result.Index, result.ID, result.Result),
+				ObjectIDs: map[string]string{
+					"index": result.Index,
+					"id":    result.ID,
+				},
+			}, nil
+		}
+		err := fmt.Errorf("indexing into %s failed with status %d: %s", indexName.String(), result.Status, string(result.Error))
+		if tenso.IsPermanentFailureStatus(result.Status) {
+			return nil, tenso.Permanent(err)
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("expected POST %s to report on 1 item, but got an empty item", c.url)
+}
+
+// bulkResponse is the response body of the bulk API.
+// Reference: <https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html#bulk-api-response-body>
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"` // key = action, e.g. "index"
+}
+
+type bulkResponseItem struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Result string          `json:"result"`
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package elk implements delivery of documents into ELK stacks, either through
+// the Elasticsearch/OpenSearch bulk API or through a Logstash TCP input.
+package elk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sapcc/go-bits/osext"
+)
+
+// Configuration is the structure of the config file at $TENSO_ELK_CONFIG_PATH.
+type Configuration struct {
+	Endpoints EndpointSet `json:"endpoints"`
+}
+
+var configAtPath = map[string]Configuration{}
+
+// LoadConfiguration loads the ELK configuration from the file specified in the given environment variable.
+func LoadConfiguration(envVarName string) (Configuration, error) {
+	filePath, err := osext.NeedGetenv(envVarName)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	// reuse cached result if possible (this also ensures that connections to
+	// Logstash are shared between all DeliveryHandlers using the same config)
+	if _, ok := configAtPath[filePath]; ok {
+		return configAtPath[filePath], nil
+	}
+
+	buf, err := os.ReadFile(filePath)
+	if err != nil {
+		return Configuration{}, err
+	}
+	result, err := ParseConfiguration(buf)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("while parsing %s: %w", filePath, err)
+	}
+
+	configAtPath[filePath] = result
+	return result, nil
+}
+
+// ParseConfiguration parses and initializes an ELK configuration from its
+// serialized form. This is usually called through LoadConfiguration().
+func ParseConfiguration(buf []byte) (Configuration, error) {
+	var result Configuration
+	err := json.Unmarshal(buf, &result)
+	if err != nil {
+		return Configuration{}, err
+	}
+	if len(result.Endpoints) == 0 {
+		return Configuration{}, errors.New("no endpoints declared")
+	}
+	err = result.Endpoints.Init()
+	if err != nil {
+		return Configuration{}, err
+	}
+	return result, nil
+}
+
+var configForLogstashHost = map[string]Configuration{}
+
+// NewLogstashConfiguration builds a Configuration with a single plain-TCP
+// Logstash endpoint. This is used to support configurations that only
+// provide a "host:port" pair instead of a full config file.
+func NewLogstashConfiguration(host string) (Configuration, error) {
+	// reuse cached result if possible (for the same reason as in LoadConfiguration)
+	if _, ok := configForLogstashHost[host]; ok {
+		return configForLogstashHost[host], nil
+	}
+
+	result := Configuration{
+		Endpoints: EndpointSet{
+			"default": {Type: EndpointTypeLogstash, Host: host},
+		},
+	}
+	err := result.Endpoints.Init()
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	configForLogstashHost[host] = result
+	return result, nil
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package elk
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// EndpointType is an enumeration of the types of ELK ingestion that we support.
+type EndpointType string
+
+const (
+	// EndpointTypeBulk delivers documents through the Elasticsearch/OpenSearch bulk API.
+	EndpointTypeBulk EndpointType = "bulk"
+	// EndpointTypeLogstash delivers documents to a Logstash TCP input with the json_lines codec.
+	EndpointTypeLogstash EndpointType = "logstash"
+)
+
+// Document is a document that shall be delivered into ELK.
+type Document struct {
+	// The JSON document to deliver.
+	Payload []byte
+	// The team owning the event that this document describes (may be empty).
+	// This is only used for rendering index names.
+	Team string
+	// The time when the event described by this document happened (may be nil).
+	// This is only used for rendering index names.
+	Time *time.Time
+	// The ID of the document in the index (may be empty). This should identify
+	// the delivery, so that a retried delivery overwrites the document instead
+	// of creating a duplicate. If empty, the ID is derived from the payload.
+	// This is only used for bulk delivery.
+	ID string
+}
+
+// EndpointSet is a set of Endpoint objects.
+//
+// This type appears in type Configuration.
+type EndpointSet map[string]*Endpoint
+
+// Init validates the provided endpoint set and recurses into Endpoint.Init().
+func (es EndpointSet) Init() error {
+	if _, exists := es["default"]; !exists {
+		return errors.New(`no "default" endpoint declared`)
+	}
+
+	for name, endpoint := range es {
+		err := endpoint.Init()
+		if err != nil {
+			return fmt.Errorf("in initialization of endpoint %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Deliver delivers a document to the endpoint selected by the "elk-target"
+// routing info, or to the "default" endpoint if no target is selected. The
+// return values are the same as for DeliverPayload() in the
+// tenso.DeliveryHandler interface.
+func (es EndpointSet) Deliver(ctx context.Context, payloadType string, doc Document, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	name, exists := routingInfo["elk-target"]
+	if !exists {
+		name = "default"
+	}
+	endpoint, exists := es[name]
+	if !exists {
+		return nil, tenso.Permanent(fmt.Errorf("unknown routing info: elk-target=%q", name))
+	}
+	return endpoint.Deliver(ctx, payloadType, doc)
+}
+
+// Endpoint is an Elasticsearch/OpenSearch cluster or a Logstash instance
+// that receives documents.
+//
+// This type appears in type Configuration through type EndpointSet.
+type Endpoint struct {
+	Type EndpointType `json:"type"`
+
+	// only for type "bulk"
+	URL           string `json:"url"`
+	IndexTemplate string `json:"index"`
+	Username      string `json:"username"`
+	PasswordPath  string `json:"password_path"`
+	APIKeyPath    string `json:"api_key_path"`
+
+	// only for type "logstash"
+	Host   string `json:"host"`
+	UseTLS bool   `json:"tls"`
+
+	// for all types
+	Timeout        string `json:"timeout"`
+	CACertPath     string `json:"ca_cert"`
+	ClientCertPath string `json:"client_cert"`
+	PrivateKeyPath string `json:"private_key"`
+
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	bulk      *bulkClient
+	logstash  *logstashClient
+}
+
+// Init validates the provided Endpoint config and initializes the internal clients.
+func (e *Endpoint) Init() error {
+	if (e.ClientCertPath == "") != (e.PrivateKeyPath == "") {
+		return errors.New(`"client_cert" and "private_key" must be given together`)
+	}
+
+	e.timeout = defaultTimeout
+	if e.Timeout != "" {
+		var err error
+		e.timeout, err = time.ParseDuration(e.Timeout)
+		if err != nil {
+			return fmt.Errorf(`invalid value for "timeout" attribute: %w`, err)
+		}
+	}
+
+	e.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if e.CACertPath != "" {
+		buf, err := os.ReadFile(e.CACertPath)
+		if err != nil {
+			return fmt.Errorf("cannot load CA certificate: %w", err)
+		}
+		e.tlsConfig.RootCAs = x509.NewCertPool()
+		if !e.tlsConfig.RootCAs.AppendCertsFromPEM(buf) {
+			return fmt.Errorf("no valid certificates found in %s", e.CACertPath)
+		}
+	}
+	if e.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(e.ClientCertPath, e.PrivateKeyPath)
+		if err != nil {
+			return fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		e.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch e.Type {
+	case EndpointTypeBulk:
+		var err error
+		e.bulk, err = newBulkClient(e)
+		return err
+	case EndpointTypeLogstash:
+		var err error
+		e.logstash, err = newLogstashClient(e)
+		return err
+	default:
+		return fmt.Errorf(`invalid value for "type" attribute: %q`, e.Type)
+	}
+}
+
+// Deliver delivers a document to this endpoint.
+// It is usually called through EndpointSet.Deliver().
+func (e *Endpoint) Deliver(ctx context.Context, payloadType string, doc Document) (*tenso.DeliveryLog, error) {
+	switch e.Type {
+	case EndpointTypeBulk:
+		return e.bulk.Deliver(ctx, payloadType, doc)
+	case EndpointTypeLogstash:
+		return nil, e.logstash.Deliver(ctx, doc)
+	default:
+		// unreachable because of validation in Init()
+		return nil, fmt.Errorf("unknown endpoint type: %q", e.Type)
+	}
+}
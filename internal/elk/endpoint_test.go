@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package elk_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/elk"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+func writeFile(t *testing.T, name string, contents []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	must.SucceedT(t, os.WriteFile(path, contents, 0o600))
+	return path
+}
+
+func TestBulkDelivery(t *testing.T) {
+	var (
+		requestHeaders []http.Header
+		requestBodies  []string
+		itemStatus     = http.StatusCreated
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/_bulk")
+		body := must.ReturnT(io.ReadAll(r.Body))(t)
+		requestHeaders = append(requestHeaders, r.Header.Clone())
+		requestBodies = append(requestBodies, string(body))
+
+		w.Header().Set("Content-Type", "application/json")
+		if itemStatus < 300 {
+			fmt.Fprintf(w, `{"errors":false,"items":[{"index":{"_index":"idx","_id":"123","result":"created","status":%d}}]}`, itemStatus)
+		} else {
+			fmt.Fprintf(w, `{"errors":true,"items":[{"index":{"_index":"idx","_id":"123","status":%d,"error":{"type":"some_exception","reason":"oops"}}}]}`, itemStatus)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	apiKeyPath := writeFile(t, "apikey", []byte("c2VjcmV0\n"))
+	cfg := must.ReturnT(elk.ParseConfiguration(fmt.Appendf(nil, `{"endpoints":{"default":{
+		"type": "bulk",
+		"url": %q,
+		"index": "deployments-{{.Team}}-{{.Time.Format \"2006.01\"}}",
+		"api_key_path": %q
+	}}}`, srv.URL, apiKeyPath)))(t)
+
+	eventTime := time.Date(2026, 4, 5, 17, 31, 0, 0, time.UTC)
+	doc := elk.Document{
+		Payload: []byte("{\n  \"foo\": 42\n}"),
+		Team:    "services",
+		Time:    &eventTime,
+	}
+	dl := must.ReturnT(cfg.Endpoints.Deliver(t.Context(), "helm-deployment-to-elk.v1", doc, nil))(t)
+	assert.Equal(t, dl.Message, "indexed as idx/123 (created)")
+	assert.Equal(t, len(requestBodies), 1)
+	assert.Equal(t, requestHeaders[0].Get("Content-Type"), "application/x-ndjson")
+	assert.Equal(t, requestHeaders[0].Get("Authorization"), "ApiKey c2VjcmV0")
+	assert.Equal(t, requestBodies[0],
+		`{"index":{"_id":"3236da92c7882bcaacd0f415dd0b7761a4ed19fead0e7c40d60cb22fe8438845","_index":"deployments-services-2026.04"}}`+"\n"+
+			`{"foo":42}`+"\n",
+	)
+
+	// if the delivery can be identified, its identity is used as the document ID
+	doc.ID = "tenso:qa-de-1:42:helm-deployment-to-elk.v1"
+	_ = must.ReturnT(cfg.Endpoints.Deliver(t.Context(), "helm-deployment-to-elk.v1", doc, nil))(t)
+	assert.Equal(t, requestBodies[1],
+		`{"index":{"_id":"tenso:qa-de-1:42:helm-deployment-to-elk.v1","_index":"deployments-services-2026.04"}}`+"\n"+
+			`{"foo":42}`+"\n",
+	)
+
+	// rejected documents are permanent failures...
+	itemStatus = http.StatusBadRequest
+	_, err := cfg.Endpoints.Deliver(t.Context(), "helm-deployment-to-elk.v1", doc, nil)
+	assert.ErrEqual(t, err, `indexing into deployments-services-2026.04 failed with status 400: {"type":"some_exception","reason":"oops"}`)
+	assert.Equal(t, tenso.IsPermanent(err), true)
+
+	// ...unless the cluster is just overloaded
+	itemStatus = http.StatusTooManyRequests
+	_, err = cfg.Endpoints.Deliver(t.Context(), "helm-deployment-to-elk.v1", doc, nil)
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, tenso.IsPermanent(err), false)
+
+	// unknown targets are permanent failures
+	_, err = cfg.Endpoints.Deliver(t.Context(), "helm-deployment-to-elk.v1", doc, map[string]string{"elk-target": "other"})
+	assert.ErrEqual(t, err, `unknown routing info: elk-target="other"`)
+	assert.Equal(t, tenso.IsPermanent(err), true)
+}
+
+func TestLogstashDelivery(t *testing.T) {
+	listener := must.ReturnT(net.Listen("tcp", "127.0.0.1:0"))(t)
+	t.Cleanup(func() { listener.Close() })
+
+	type receivedLine struct {
+		ConnectionIndex int
+		Line            string
+	}
+	received := make(chan receivedLine)
+	accepted := make(chan net.Conn)
+	go func() {
+		for idx := 0; ; idx++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- receivedLine{idx, scanner.Text()}
+				}
+			}()
+		}
+	}()
+
+	cfg := must.ReturnT(elk.NewLogstashConfiguration(listener.Addr().String()))(t)
+	deliver := func(payload string) {
+		t.Helper()
+		doc := elk.Document{Payload: []byte(payload)}
+		_ = must.ReturnT(cfg.Endpoints.Deliver(t.Context(), "helm-deployment-to-elk.v1", doc, nil))(t)
+	}
+
+	// multiple deliveries reuse the same connection
+	deliver("{\n  \"foo\": 1\n}")
+	conn := <-accepted
+	assert.Equal(t, <-received, receivedLine{0, `{"foo":1}`})
+	deliver(`{"foo":2}`)
+	assert.Equal(t, <-received, receivedLine{0, `{"foo":2}`})
+
+	// when the connection gets closed by the other side, we reconnect
+	must.SucceedT(t, conn.Close())
+	time.Sleep(10 * time.Millisecond) // give the FIN some time to arrive
+	deliver(`{"foo":3}`)
+	<-accepted
+	assert.Equal(t, <-received, receivedLine{1, `{"foo":3}`})
+}
+
+func TestConfigurationErrors(t *testing.T) {
+	testCases := map[string]string{
+		`{"endpoints":{}}`: `no endpoints declared`,
+		`{"endpoints":{"other":{"type":"logstash","host":"localhost:1"}}}`:                                       `no "default" endpoint declared`,
+		`{"endpoints":{"default":{"type":"splunk"}}}`:                                                            `in initialization of endpoint "default": invalid value for "type" attribute: "splunk"`,
+		`{"endpoints":{"default":{"type":"logstash","host":"localhost"}}}`:                                       `in initialization of endpoint "default": expected "host" attribute to look like "host:port", but got "localhost"`,
+		`{"endpoints":{"default":{"type":"bulk","url":"localhost:9200","index":"foo"}}}`:                         `in initialization of endpoint "default": invalid value for "url" attribute: "localhost:9200"`,
+		`{"endpoints":{"default":{"type":"bulk","url":"http://localhost:9200"}}}`:                                `in initialization of endpoint "default": missing "index" attribute`,
+		`{"endpoints":{"default":{"type":"bulk","url":"http://localhost:9200","index":"{{.Foo}}"}}}`:             `in initialization of endpoint "default": invalid value for "index" attribute: template: index:1:2: executing "index" at <.Foo>: can't evaluate field Foo in type elk.IndexTemplateData`,
+		`{"endpoints":{"default":{"type":"bulk","url":"http://localhost:9200","index":"foo","username":"bob"}}}`: `in initialization of endpoint "default": "password_path" must be given together with "username"`,
+	}
+	for input, expectedError := range testCases {
+		_, err := elk.ParseConfiguration([]byte(input))
+		assert.ErrEqual(t, err, expectedError)
+	}
+}
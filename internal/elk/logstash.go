@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package elk
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sapcc/go-bits/logg"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+// logstashClient delivers documents to a Logstash TCP input with the
+// json_lines codec. The connection is kept open between deliveries.
+type logstashClient struct {
+	host      string
+	timeout   time.Duration
+	tlsConfig *tls.Config // nil if TLS is not used
+
+	mutex sync.Mutex
+	conn  net.Conn // nil if not connected
+}
+
+func newLogstashClient(e *Endpoint) (*logstashClient, error) {
+	if e.Host == "" {
+		return nil, errors.New(`missing "host" attribute`)
+	}
+	host, _, err := net.SplitHostPort(e.Host)
+	if err != nil {
+		return nil, fmt.Errorf(`expected "host" attribute to look like "host:port", but got %q`, e.Host)
+	}
+
+	c := &logstashClient{
+		host:    e.Host,
+		timeout: e.timeout,
+	}
+	if e.UseTLS {
+		c.tlsConfig = e.tlsConfig.Clone()
+		c.tlsConfig.ServerName = host
+	} else if e.CACertPath != "" || e.ClientCertPath != "" {
+		return nil, errors.New(`"ca_cert" and "client_cert" can only be given if "tls" is true`)
+	}
+	return c, nil
+}
+
+// Deliver writes a single document into the connection, reconnecting if necessary.
+func (c *logstashClient) Deliver(ctx context.Context, doc Document) error {
+	// Logstash wants everything on one line, so ensure we don't have unnecessary whitespace in the payload
+	var buf bytes.Buffer
+	err := json.Compact(&buf, doc.Payload)
+	if err != nil {
+		return tenso.Permanent(err)
+	}
+	buf.WriteByte('\n')
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	conn, err := c.getConnection(ctx)
+	if err != nil {
+		return err
+	}
+	err = conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err == nil {
+		_, err = conn.Write(buf.Bytes())
+	}
+	if err != nil {
+		// do not reuse a connection in an unknown state
+		c.closeConnection()
+		return fmt.Errorf("while writing to %s: %w", c.host, err)
+	}
+	return nil
+}
+
+// Returns the existing connection if it is still usable, or opens a new one.
+// The caller must hold c.mutex.
+func (c *logstashClient) getConnection(ctx context.Context) (net.Conn, error) {
+	if c.conn != nil {
+		if isConnectionAlive(c.conn) {
+			return c.conn, nil
+		}
+		c.closeConnection()
+	}
+
+	dialer := &net.Dialer{Timeout: c.timeout, KeepAlive: 30 * time.Second}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if c.tlsConfig == nil {
+		conn, err = dialer.DialContext(ctx, "tcp", c.host)
+	} else {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: c.tlsConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", c.host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %w", c.host, err)
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// The caller must hold c.mutex.
+func (c *logstashClient) closeConnection() {
+	err := c.conn.Close()
+	if err != nil {
+		logg.Error("while closing connection to %s: %s", c.host, err.Error())
+	}
+	c.conn = nil
+}
+
+// Logstash never sends anything on its TCP input, so if a read from the
+// connection does not time out immediately, the other side has closed the
+// connection (or sent something unexpected). Either way, we should reconnect
+// instead of writing into the void.
+func isConnectionAlive(conn net.Conn) bool {
+	err := conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	if err != nil {
+		return false
+	}
+	var buf [1]byte
+	_, err = conn.Read(buf[:])
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -6,16 +6,19 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/sapcc/go-api-declarations/deployevent"
 
+	"github.com/sapcc/tenso/internal/changeevent"
 	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/teams"
 	"github.com/sapcc/tenso/internal/tenso"
 )
 
@@ -23,8 +26,13 @@ import (
 func init() {
 	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &activeDirectoryDeploymentV1Validator{} })
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &activeDirectoryDeploymentV1ToSNowTranslator{} })
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &activeDirectoryDeploymentV1ToV2Translator{} })
+	tenso.IntermediatePayloadTypes["active-directory-deployment-from-concourse.v2"] = true
 
 	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &activeDirectoryDeploymentV2Validator{} })
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &changeEventTranslator{"active-directory-deployment-from-concourse.v2", activeDirectoryDeploymentV2ChangeEventOf}
+	})
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &activeDirectoryDeploymentV2ToSNowTranslator{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &activeDirectoryDeploymentV1ToSNowDeliverer{} })
 }
@@ -106,48 +114,59 @@ func (v *activeDirectoryDeploymentV1Validator) ValidatePayload(payload []byte, r
 		return nil, err
 	}
 
+	var errs tenso.ValidationErrors
 	if !regionRx.MatchString(event.Region) {
-		return nil, fmt.Errorf(`value for field region is invalid: %q`, event.Region)
+		errs.Add("/region", tenso.ValidationErrorInvalid, `value for field region is invalid: %q`, event.Region)
 	}
 	if event.RecordedAt.Value == nil {
-		return nil, errors.New("value for field recorded_at is missing")
+		errs.Add("/recorded_at", tenso.ValidationErrorMissing, "value for field recorded_at is missing")
 	}
 	if event.Landscape == "" {
 		//TODO: validate landscape value (we don't know the full range of possible values yet; at least "dev")
-		return nil, errors.New("value for field landscape is missing")
+		errs.Add("/landscape", tenso.ValidationErrorMissing, "value for field landscape is missing")
 	}
 	if !strings.HasSuffix(event.Hostname, ".sap") {
-		return nil, fmt.Errorf(`value for field host is invalid: %q`, event.Hostname)
+		errs.Add("/host", tenso.ValidationErrorInvalid, `value for field host is invalid: %q`, event.Hostname)
 	}
 
-	for repoName, repoInfo := range event.GitRepos {
+	for _, repoName := range slices.Sorted(maps.Keys(event.GitRepos)) {
+		repoInfo := event.GitRepos[repoName]
 		if !gitCommitRx.MatchString(repoInfo.CommitID) {
-			return nil, fmt.Errorf(`value for field git[%q].commit-id is invalid: %q`, repoName, repoInfo.CommitID)
+			errs.Add(jsonPointer("git", repoName, "commit-id"), tenso.ValidationErrorInvalid,
+				`value for field git[%q].commit-id is invalid: %q`, repoName, repoInfo.CommitID)
 		}
 		if repoInfo.RemoteURL == "" {
-			return nil, fmt.Errorf(`value for field git[%q].commit-id is invalid: %q`, repoName, repoInfo.CommitID)
+			errs.Add(jsonPointer("git", repoName, "remote-url"), tenso.ValidationErrorMissing,
+				`value for field git[%q].remote-url is missing`, repoName)
 		}
 	}
 
 	d := event.ADDeployment
 	if d.StartedAt.Value == nil {
-		return nil, errors.New("value for field ad_deployment.started_at is missing")
+		errs.Add("/ad_deployment/started_at", tenso.ValidationErrorMissing, "value for field ad_deployment.started_at is missing")
 	}
 	switch d.Outcome {
 	case "succeeded":
 		if d.FinishedAt.Value == nil {
-			return nil, fmt.Errorf("field ad_deployment.finished_at must be set for outcome %q", d.Outcome)
+			errs.Add("/ad_deployment/finished_at", tenso.ValidationErrorMissing, "field ad_deployment.finished_at must be set for outcome %q", d.Outcome)
 		}
 	case "failed":
 		if d.FinishedAt.Value != nil {
-			return nil, fmt.Errorf("field ad_deployment.finished_at may not be set for outcome %q", d.Outcome)
+			errs.Add("/ad_deployment/finished_at", tenso.ValidationErrorNotAllowed, "field ad_deployment.finished_at may not be set for outcome %q", d.Outcome)
 		}
 	default:
-		return nil, fmt.Errorf(`value for field ad_deployment.outcome is invalid: %q`, d.Outcome)
+		errs.Add("/ad_deployment/outcome", tenso.ValidationErrorInvalid, `value for field ad_deployment.outcome is invalid: %q`, d.Outcome)
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return &tenso.PayloadInfo{
 		Description: "core/active-directory: deploy AD to " + event.Hostname,
+		PolicyAttributes: map[string]string{
+			"region":    event.Region,
+			"landscape": event.Landscape,
+		},
 	}, nil
 }
 
@@ -189,7 +208,7 @@ func (t *activeDirectoryDeploymentV1ToSNowTranslator) TranslatePayload(payload [
 	case "failed":
 		outcome = deployevent.OutcomeADDeploymentFailed
 	default:
-		err = fmt.Errorf(`value for field ad_deployment.outcome is invalid: %q`, event.ADDeployment.Outcome)
+		err = tenso.Permanent(fmt.Errorf(`value for field ad_deployment.outcome is invalid: %q`, event.ADDeployment.Outcome))
 	}
 	if err != nil {
 		return nil, err
@@ -218,11 +237,83 @@ func (t *activeDirectoryDeploymentV1ToSNowTranslator) TranslatePayload(payload [
 		Description: fmt.Sprintf("Deployed active-directory in landscape %s with versions: %s\n\nOutcome: %s", event.Landscape, inputDesc, event.ADDeployment.Outcome),
 		Executee:    "",
 		Region:      event.Region,
+		GitRepos:    mock.GitRepos,
+
+		ConfigurationItemRefs: []servicenow.ConfigurationItemRef{{
+			Kind: servicenow.ConfigurationItemKindHost,
+			Name: event.Hostname,
+		}},
 	}
 
 	return chg.Serialize(t.Mapping, t.Mapping.ActiveDirectoryDeployment, routingInfo)
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for v2 (upconversion for all targets without a direct v1 translation)
+
+type activeDirectoryDeploymentV1ToV2Translator struct{}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *activeDirectoryDeploymentV1ToV2Translator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *activeDirectoryDeploymentV1ToV2Translator) PluginTypeID() string {
+	return "active-directory-deployment-from-concourse.v1->active-directory-deployment-from-concourse.v2"
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *activeDirectoryDeploymentV1ToV2Translator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := jsonUnmarshalStrict[activeDirectoryDeploymentV1Event](payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var outcome deployevent.Outcome
+	switch event.ADDeployment.Outcome {
+	case "succeeded":
+		outcome = deployevent.OutcomeSucceeded
+	case "failed":
+		outcome = deployevent.OutcomeADDeploymentFailed
+	default:
+		return nil, tenso.Permanent(fmt.Errorf(`value for field ad_deployment.outcome is invalid: %q`, event.ADDeployment.Outcome))
+	}
+
+	//NOTE: v1 events are only sent by the core/active-directory pipeline (see
+	// description in ValidatePayload), but do not carry any further pipeline info.
+	result := deployevent.Event{
+		Region:     event.Region,
+		RecordedAt: event.RecordedAt.Value,
+		GitRepos:   make(map[string]deployevent.GitRepo, len(event.GitRepos)),
+		Pipeline: deployevent.Pipeline{
+			TeamName:     "core",
+			PipelineName: "active-directory",
+		},
+		ADDeployment: &deployevent.ActiveDirectoryDeployment{
+			Landscape:  event.Landscape,
+			Hostname:   event.Hostname,
+			Outcome:    outcome,
+			StartedAt:  event.ADDeployment.StartedAt.Value,
+			FinishedAt: event.ADDeployment.FinishedAt.Value,
+		},
+	}
+	if duration, ok := event.ADDeployment.DurationSeconds.(float64); ok && duration >= 0 {
+		durationSeconds := uint64(duration)
+		result.ADDeployment.DurationSeconds = &durationSeconds
+	}
+	for checkoutName, repoInfo := range event.GitRepos {
+		//NOTE: AuthoredAt is not taken over since v1 uses a different time format (see above)
+		result.GitRepos[checkoutName] = deployevent.GitRepo{
+			Branch:    repoInfo.Branch,
+			CommitID:  repoInfo.CommitID,
+			RemoteURL: repoInfo.RemoteURL,
+		}
+	}
+
+	return json.Marshal(result)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // END v1 implementation (when removing v1 support, remove until here)
 ////////////////////////////////////////////////////////////////////////////////
@@ -231,11 +322,13 @@ func (t *activeDirectoryDeploymentV1ToSNowTranslator) TranslatePayload(payload [
 // ValidationHandler
 
 type activeDirectoryDeploymentV2Validator struct {
+	teams *teams.Registry
 }
 
 // Init implements the tenso.ValidationHandler interface.
-func (v *activeDirectoryDeploymentV2Validator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
-	return nil
+func (v *activeDirectoryDeploymentV2Validator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	v.teams, err = teams.Load("TENSO_TEAMS_CONFIG_PATH")
+	return err
 }
 
 // PluginTypeID implements the pluggable.Plugin interface.
@@ -245,52 +338,77 @@ func (v *activeDirectoryDeploymentV2Validator) PluginTypeID() string {
 
 // ValidatePayload implements the tenso.ValidationHandler interface.
 func (v *activeDirectoryDeploymentV2Validator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
-	event, err := parseAndValidateDeployEvent(payload, regionRx)
+	var errs, warnings tenso.ValidationErrors
+	event, err := parseAndValidateDeployEvent(payload, regionRx, v.teams.Current(), &errs, &warnings)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(event.HelmReleases) != 0 {
-		return nil, errors.New("helm-release[] may not be set for Active Directory deployment events")
+		errs.Add("/helm-release", tenso.ValidationErrorNotAllowed, "helm-release[] may not be set for Active Directory deployment events")
 	}
 	if len(event.TerraformRuns) != 0 {
-		return nil, errors.New("terraform-runs[] may not be set for Active Directory deployment events")
+		errs.Add("/terraform-runs", tenso.ValidationErrorNotAllowed, "terraform-runs[] may not be set for Active Directory deployment events")
 	}
 	if event.ADDeployment == nil {
-		return nil, errors.New("active-directory-deployment may not be empty")
+		errs.Add("/active-directory-deployment", tenso.ValidationErrorMissing, "active-directory-deployment may not be empty")
+		return nil, errs
 	}
 
 	ad := *event.ADDeployment
-	missingField := func(field string) error {
-		return fmt.Errorf("active-directory-deployment.%s may not be empty", field)
+	missingField := func(pointerToken, field string) {
+		errs.Add("/active-directory-deployment/"+pointerToken, tenso.ValidationErrorMissing, "active-directory-deployment.%s may not be empty", field)
 	}
 	if ad.Landscape == "" {
-		return nil, missingField("landscape")
+		missingField("landscape", "landscape")
 	}
 	if ad.Hostname == "" {
-		return nil, missingField("hostname")
-	}
-	if !strings.HasSuffix(ad.Hostname, ".sap") {
-		return nil, fmt.Errorf(`value for field active-directory-deployment.host is invalid: %q`, ad.Hostname)
+		missingField("host", "hostname")
+	} else if !strings.HasSuffix(ad.Hostname, ".sap") {
+		errs.Add("/active-directory-deployment/host", tenso.ValidationErrorInvalid, `value for field active-directory-deployment.host is invalid: %q`, ad.Hostname)
 	}
 	if !ad.Outcome.IsKnownInputValue() {
-		return nil, fmt.Errorf(`invalid value for field active-directory-deployment.outcome: %q`, ad.Outcome)
+		errs.Add("/active-directory-deployment/outcome", tenso.ValidationErrorInvalid, `invalid value for field active-directory-deployment.outcome: %q`, ad.Outcome)
 	}
 	if ad.StartedAt == nil {
-		return nil, missingField("started-at")
+		missingField("started-at", "started-at")
 	}
 	if ad.FinishedAt == nil && (ad.Outcome != deployevent.OutcomeNotDeployed && ad.Outcome != deployevent.OutcomeADDeploymentFailed) {
-		return nil, fmt.Errorf(`field active-directory-deployment.finished-at must be set for outcome %q`, ad.Outcome)
+		errs.Add("/active-directory-deployment/finished-at", tenso.ValidationErrorMissing, `field active-directory-deployment.finished-at must be set for outcome %q`, ad.Outcome)
 	}
 	if ad.FinishedAt != nil && (ad.Outcome == deployevent.OutcomeNotDeployed || ad.Outcome == deployevent.OutcomeADDeploymentFailed) {
-		return nil, fmt.Errorf(`field active-directory-deployment.finished-at may not be set for outcome %q`, ad.Outcome)
+		errs.Add("/active-directory-deployment/finished-at", tenso.ValidationErrorNotAllowed, `field active-directory-deployment.finished-at may not be set for outcome %q`, ad.Outcome)
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return &tenso.PayloadInfo{
-		Description: "core/active-directory: deploy AD to " + ad.Hostname,
+		Description:      "core/active-directory: deploy AD to " + ad.Hostname,
+		Warnings:         warnings,
+		PolicyAttributes: policyAttributesOfDeployEvent(event),
 	}, nil
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for change events
+
+func activeDirectoryDeploymentV2ChangeEventOf(payload []byte) (changeevent.Event, error) {
+	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	if err != nil {
+		return changeevent.Event{}, err
+	}
+
+	result := changeEventOfDeployEvent(changeevent.FamilyActiveDirectoryDeployment, event)
+	result.Summary = "Deploy AD to " + event.ADDeployment.Hostname
+	result.Description = fmt.Sprintf("Deployed active-directory in landscape %s with versions: %s\n\nOutcome: %s",
+		event.ADDeployment.Landscape,
+		strings.Join(inputDescriptorsOf(event), ", "),
+		event.ADDeployment.Outcome,
+	)
+	return result, nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // TranslationHandler for SNow
 
@@ -311,26 +429,11 @@ func (t *activeDirectoryDeploymentV2ToSNowTranslator) PluginTypeID() string {
 
 // TranslatePayload implements the tenso.TranslationHandler interface.
 func (t *activeDirectoryDeploymentV2ToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
-	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	event, err := activeDirectoryDeploymentV2ChangeEventOf(payload)
 	if err != nil {
 		return nil, err
 	}
-
-	chg := servicenow.Change{
-		StartedAt: event.CombinedStartDate(),
-		EndedAt:   event.RecordedAt,
-		Outcome:   event.CombinedOutcome(),
-		Summary:   "Deploy AD to " + event.ADDeployment.Hostname,
-		Description: fmt.Sprintf("Deployed active-directory in landscape %s with versions: %s\n\nOutcome: %s",
-			event.ADDeployment.Landscape,
-			strings.Join(inputDescriptorsOf(event), ", "),
-			event.ADDeployment.Outcome,
-		),
-		Executee: event.Pipeline.CreatedBy, //NOTE: can be empty
-		Region:   event.Region,
-	}
-
-	return chg.Serialize(t.Mapping, t.Mapping.ActiveDirectoryDeployment, routingInfo)
+	return serviceNowChangeOf(event).Serialize(t.Mapping, t.Mapping.ActiveDirectoryDeployment, routingInfo)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -353,5 +456,10 @@ func (d *activeDirectoryDeploymentV1ToSNowDeliverer) PluginTypeID() string {
 
 // DeliverPayload implements the tenso.DeliveryHandler interface.
 func (d *activeDirectoryDeploymentV1ToSNowDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
-	return d.Mapping.Endpoints.DeliverChangePayload(ctx, payload, routingInfo)
+	return d.Mapping.Endpoints.DeliverChangePayload(ctx, tenso.DeliveryState{}, payload, routingInfo)
+}
+
+// DeliverPayloadWithState implements the tenso.StatefulDeliveryHandler interface.
+func (d *activeDirectoryDeploymentV1ToSNowDeliverer) DeliverPayloadWithState(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return d.Mapping.Endpoints.DeliverChangePayload(ctx, state, payload, routingInfo)
 }
@@ -57,10 +57,57 @@ func TestActiveDirectoryDeploymentConversionToSNow(t *testing.T) {
 				eventFormat,
 			)),
 		)
-		th := s.Config.EnabledRoutes[0].TranslationHandler
+		chain := s.Config.EnabledRoutes[0].TranslationChain
 
 		sourcePayloadBytes := must.ReturnT(os.ReadFile(fmt.Sprintf("fixtures/active-directory-deployment-from-concourse.%s.dev.json", eventFormat)))(t)
-		targetPayloadBytes := must.ReturnT(th.TranslatePayload(sourcePayloadBytes, nil))(t)
-		expectTranslatedPayload(t, targetPayloadBytes, "fixtures/active-directory-deployment-to-servicenow.v1.dev.json")
+		targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+		if eventFormat == "v1" {
+			// v1 events do not have pipeline info, so they cannot be correlated with "deployment started" events
+			expectTranslatedPayload(t, targetPayloadBytes, "fixtures/active-directory-deployment-to-servicenow.v1.dev-from-v1.json")
+		} else {
+			expectTranslatedPayload(t, targetPayloadBytes, "fixtures/active-directory-deployment-to-servicenow.v1.dev.json")
+		}
 	}
 }
+
+func TestActiveDirectoryDeploymentConversionToSNowForFailedDeployment(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+
+	s := test.NewSetup(t,
+		test.WithRoute("active-directory-deployment-from-concourse.v2 -> active-directory-deployment-to-servicenow.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	// the mapping config has `outcome_actions` for this outcome, so an incident is created alongside the change
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/active-directory-deployment-from-concourse.v2.failed.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/active-directory-deployment-to-servicenow.v1.failed.json")
+}
+
+func TestActiveDirectoryDeploymentConversionToELK(t *testing.T) {
+	t.Setenv("TENSO_ELK_CONFIG_PATH", "fixtures/elk-config.json")
+
+	s := test.NewSetup(t,
+		test.WithRoute("active-directory-deployment-from-concourse.v2 -> active-directory-deployment-to-elk.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/active-directory-deployment-from-concourse.v2.dev.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/active-directory-deployment-to-elk.v1.dev.json")
+}
+
+func TestActiveDirectoryDeploymentUpconversion(t *testing.T) {
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+
+	// there is no direct translation from v1 into this target, so v1 events get upconverted into v2 first
+	s := test.NewSetup(t,
+		test.WithRoute("active-directory-deployment-from-concourse.v1 -> active-directory-deployment-to-webhook.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+	assert.Equal(t, chain.String(), "active-directory-deployment-from-concourse.v1 -> active-directory-deployment-from-concourse.v2 -> active-directory-deployment-to-webhook.v1")
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/active-directory-deployment-from-concourse.v1.dev.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/active-directory-deployment-from-concourse.v2.dev-from-v1.json")
+}
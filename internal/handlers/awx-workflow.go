@@ -4,10 +4,8 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -21,13 +19,18 @@ import (
 	"go.xyrillian.de/schwift/v2"
 	"go.xyrillian.de/schwift/v2/gopherschwift"
 
+	"github.com/sapcc/tenso/internal/changeevent"
 	"github.com/sapcc/tenso/internal/servicenow"
 	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/topology"
 )
 
 func init() {
 	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &awxWorkflowValidator{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &awxWorkflowToSwiftDeliverer{} })
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &changeEventTranslator{"infra-workflow-from-awx.v1", awxWorkflowChangeEventOf}
+	})
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &awxWorkflowToSNowTranslator{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &awxWorkflowToSNowDeliverer{} })
 }
@@ -98,7 +101,8 @@ func (t *awxWorkflowTime) UnmarshalJSON(buf []byte) error {
 // ValidationHandler
 
 type awxWorkflowValidator struct {
-	azRx *regexp.Regexp
+	azRx     *regexp.Regexp
+	topology *topology.Topology
 }
 
 // Init implements the tenso.ValidationHandler interface.
@@ -112,7 +116,8 @@ func (a *awxWorkflowValidator) Init(context.Context, *gophercloud.ProviderClient
 	if err != nil {
 		return fmt.Errorf("while compiling %s: %w", azRxEnvVar, err)
 	}
-	return nil
+	a.topology, err = topology.Load("TENSO_TOPOLOGY_CONFIG_PATH")
+	return err
 }
 
 // PluginTypeID implements the pluggable.Plugin interface.
@@ -127,26 +132,37 @@ func (a *awxWorkflowValidator) ValidatePayload(payload []byte, _ *regexp.Regexp)
 		return nil, err
 	}
 
+	var errs tenso.ValidationErrors
 	if event.ID == 0 {
-		return nil, errors.New(`missing value for field "id"`)
+		errs.Add("/id", tenso.ValidationErrorMissing, `missing value for field "id"`)
 	}
 	if event.Name == "" {
-		return nil, errors.New(`missing value for field "name"`)
+		errs.Add("/name", tenso.ValidationErrorMissing, `missing value for field "name"`)
 	}
 	if event.StartedAt == nil {
-		return nil, errors.New(`missing value for field "started"`)
+		errs.Add("/started", tenso.ValidationErrorMissing, `missing value for field "started"`)
 	}
 	if event.FinishedAt == nil {
-		return nil, errors.New(`missing value for field "finished"`)
+		errs.Add("/finished", tenso.ValidationErrorMissing, `missing value for field "finished"`)
 	}
 	if _, ok := awxOutcomes[event.Status]; !ok {
-		return nil, fmt.Errorf(`invalid value for field "status": %q`, event.Status)
+		errs.Add("/status", tenso.ValidationErrorInvalid, `invalid value for field "status": %q`, event.Status)
 	}
 	if !a.azRx.MatchString(event.AvailabilityZone) {
-		return nil, fmt.Errorf(`invalid value for field "inventory": %q is not an AZ name`, event.AvailabilityZone)
+		errs.Add("/inventory", tenso.ValidationErrorInvalid, `invalid value for field "inventory": %q is not an AZ name`, event.AvailabilityZone)
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
-	return &tenso.PayloadInfo{Description: event.GetSummary()}, nil
+	policyAttrs := map[string]string{"availability_zone": event.AvailabilityZone}
+	if region, ok := a.topology.Current().RegionOfAvailabilityZone(event.AvailabilityZone); ok {
+		policyAttrs["region"] = region
+	}
+	return &tenso.PayloadInfo{
+		Description:      event.GetSummary(),
+		PolicyAttributes: policyAttrs,
+	}, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -192,7 +208,7 @@ func (a *awxWorkflowToSwiftDeliverer) DeliverPayload(ctx context.Context, payloa
 	objectName := fmt.Sprintf("%s/%d/%s.json",
 		event.Name, event.ID, event.FinishedAt.Format(time.RFC3339),
 	)
-	return nil, a.Container.Object(objectName).Upload(ctx, bytes.NewReader(payload), nil, nil)
+	return tenso.DeliverToSwift(ctx, a.Container, objectName, payload)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -222,35 +238,67 @@ var (
 	// in the "configuration_item" field. (We have to be strict here since
 	// "configuration_item" is a reference to objects that exist in the SNow DB,
 	// so we need to be reasonably sure that SNow knows about the object in
-	// question.)
+	// question.) If the ServiceNow endpoint has a configuration item resolver,
+	// the node name will be replaced by the resolved configuration item.
 	configurationItemRx = regexp.MustCompile(`^node\d{3}-bb\d{3}\.cc\.[a-z]{2}-[a-z]{2}-[0-9]\.cloud\.sap$`) // e.g. "node002-bb091.cc.qa-de-1.cloud.sap"
+	// If `event.SearchQuery` contains a building block ID (either as part of a
+	// node name or on its own, e.g. "bb091"), the building block is another
+	// candidate for the configuration item.
+	buildingBlockRx = regexp.MustCompile(`(?:^|[^a-z0-9])(bb\d{3})(?:[^0-9]|$)`)
 )
 
-// TranslatePayload implements the tenso.TranslationHandler interface.
-func (a *awxWorkflowToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+// Returns the objects targeted by this workflow, most specific first.
+func (e awxWorkflowEvent) affectedObjects() (result []changeevent.Object) {
+	if configurationItemRx.MatchString(e.SearchQuery) {
+		result = append(result, changeevent.Object{
+			Kind: changeevent.ObjectKindHost,
+			Name: e.SearchQuery,
+		})
+	}
+	match := buildingBlockRx.FindStringSubmatch(e.SearchQuery)
+	if match != nil {
+		result = append(result, changeevent.Object{
+			Kind: changeevent.ObjectKindBuildingBlock,
+			Name: match[1],
+		})
+	}
+	return result
+}
+
+func awxWorkflowChangeEventOf(payload []byte) (changeevent.Event, error) {
 	event, err := jsonUnmarshalStrict[awxWorkflowEvent](payload)
 	if err != nil {
-		return nil, err
+		return changeevent.Event{}, err
 	}
 
-	//TODO: fill "configuration_item" from event.SearchQuery, ONLY if the value is a valid node name or if we can extract a building block ID from it
-	chg := servicenow.Change{
-		StartedAt:        &event.StartedAt.Time,
-		EndedAt:          &event.FinishedAt.Time,
-		Outcome:          awxOutcomes[event.Status],
+	result := changeevent.Event{
+		Family:           changeevent.FamilyAWXWorkflow,
 		Summary:          event.GetSummary(),
 		Description:      event.GetDescription(),
+		StartedAt:        &event.StartedAt.Time,
+		FinishedAt:       &event.FinishedAt.Time,
+		Outcome:          awxOutcomes[event.Status],
 		AvailabilityZone: event.AvailabilityZone,
-		Executee:         strings.ToUpper(event.CreatedBy),
+		Initiator:        strings.ToUpper(event.CreatedBy),
+		AffectedObjects:  event.affectedObjects(),
+		AWXWorkflowName:  event.Name,
 	}
 	if configurationItemRx.MatchString(event.SearchQuery) {
-		chg.ConfigurationItem = event.SearchQuery
+		result.ConfigurationItem = event.SearchQuery
 	}
-	if !sapUserIDRx.MatchString(chg.Executee) {
-		chg.Executee = ""
+	if !sapUserIDRx.MatchString(result.Initiator) {
+		result.Initiator = ""
 	}
+	return result, nil
+}
 
-	return chg.Serialize(a.Mapping, a.Mapping.AWXWorkflow, routingInfo)
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (a *awxWorkflowToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := awxWorkflowChangeEventOf(payload)
+	if err != nil {
+		return nil, err
+	}
+	return serviceNowChangeOf(event).Serialize(a.Mapping, a.Mapping.AWXWorkflow, routingInfo)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -273,5 +321,10 @@ func (a *awxWorkflowToSNowDeliverer) PluginTypeID() string {
 
 // DeliverPayload implements the tenso.DeliveryHandler interface.
 func (a *awxWorkflowToSNowDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
-	return a.Mapping.Endpoints.DeliverChangePayload(ctx, payload, routingInfo)
+	return a.Mapping.Endpoints.DeliverChangePayload(ctx, tenso.DeliveryState{}, payload, routingInfo)
+}
+
+// DeliverPayloadWithState implements the tenso.StatefulDeliveryHandler interface.
+func (a *awxWorkflowToSNowDeliverer) DeliverPayloadWithState(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return a.Mapping.Endpoints.DeliverChangePayload(ctx, state, payload, routingInfo)
 }
@@ -17,18 +17,37 @@ import (
 func TestAWXWorkflowValidationAndConversionToSNow(t *testing.T) {
 	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
 	t.Setenv("TENSO_AWX_WORKFLOW_AZ_REGEX", "[a-z]{2}-[a-z]{2}-[0-9][a-z]")
+	t.Setenv("TENSO_TOPOLOGY_CONFIG_PATH", "fixtures/topology-config.json")
 	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
 
 	s := test.NewSetup(t,
 		test.WithRoute("infra-workflow-from-awx.v1 -> infra-workflow-to-servicenow.v1"),
 	)
 	vh := s.Config.EnabledRoutes[0].ValidationHandler
-	th := s.Config.EnabledRoutes[0].TranslationHandler
+	chain := s.Config.EnabledRoutes[0].TranslationChain
 
 	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/infra-workflow-from-awx.v1.good.json"))(t)
 	payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
 	assert.Equal(t, payloadInfo.Description, "ESX upgrade, qa-de-1a, node002-bb091.cc.qa-de-1.cloud.sap")
+	assert.Equal(t, payloadInfo.PolicyAttributes, map[string]string{
+		"region":            "qa-de-1",
+		"availability_zone": "qa-de-1a",
+	})
 
-	targetPayloadBytes := must.ReturnT(th.TranslatePayload(sourcePayloadBytes, nil))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
 	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/infra-workflow-to-servicenow.v1.good.json")
 }
+
+func TestAWXWorkflowConversionToELK(t *testing.T) {
+	t.Setenv("TENSO_ELK_CONFIG_PATH", "fixtures/elk-config.json")
+	t.Setenv("TENSO_AWX_WORKFLOW_AZ_REGEX", "[a-z]{2}-[a-z]{2}-[0-9][a-z]")
+
+	s := test.NewSetup(t,
+		test.WithRoute("infra-workflow-from-awx.v1 -> infra-workflow-to-elk.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/infra-workflow-from-awx.v1.good.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/infra-workflow-to-elk.v1.good.json")
+}
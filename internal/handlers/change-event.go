@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"go.xyrillian.de/schwift/v2"
+
+	"github.com/sapcc/tenso/internal/changeevent"
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+// Events of all ingress payload types can be translated into the canonical
+// "change-event.v1" payload type (see package changeevent). Since it is an
+// intermediate payload type, routes from any of those ingress payload types
+// into any of the "change-event-to-*" payload types are possible without
+// translations specific to that pair of payload types.
+//
+// The translations into "change-event.v1" are registered next to the
+// respective ValidationHandlers. The translations from "change-event.v1" into
+// the targets that consume it verbatim are in dummy.go.
+
+func init() {
+	tenso.IntermediatePayloadTypes[changeevent.PayloadType] = true
+
+	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &changeEventValidator{} })
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &changeEventToSwiftDeliverer{} })
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &changeEventToSNowTranslator{} })
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &changeEventToSNowDeliverer{} })
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// ValidationHandler
+
+type changeEventValidator struct{}
+
+// Init implements the tenso.ValidationHandler interface.
+func (v *changeEventValidator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (v *changeEventValidator) PluginTypeID() string {
+	return changeevent.PayloadType
+}
+
+// ValidatePayload implements the tenso.ValidationHandler interface.
+func (v *changeEventValidator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
+	event, err := jsonUnmarshalStrict[changeevent.Event](payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs tenso.ValidationErrors
+	event.Validate(regionRx, &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	policyAttrs := make(map[string]string)
+	if event.Region != "" {
+		policyAttrs["region"] = event.Region
+	}
+	if event.AvailabilityZone != "" {
+		policyAttrs["availability_zone"] = event.AvailabilityZone
+	}
+	if event.Pipeline != nil {
+		policyAttrs["team"] = event.Pipeline.TeamName
+		policyAttrs["pipeline"] = event.Pipeline.PipelineName
+	}
+	return &tenso.PayloadInfo{
+		Description:      fmt.Sprintf("%s: %s", event.Family, event.Summary),
+		PolicyAttributes: policyAttrs,
+	}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler from other payload types
+
+// changeEventTranslator is a tenso.TranslationHandler that translates payloads
+// of some ingress payload type into "change-event.v1".
+type changeEventTranslator struct {
+	sourcePayloadType string
+	// Translate builds the change event for the given payload.
+	Translate func(payload []byte) (changeevent.Event, error)
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *changeEventTranslator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *changeEventTranslator) PluginTypeID() string {
+	return t.sourcePayloadType + "->" + changeevent.PayloadType
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *changeEventTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := t.Translate(payload)
+	if err != nil {
+		return nil, err
+	}
+	event.SourcePayloadType = t.sourcePayloadType
+	return json.Marshal(event)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// DeliveryHandler for Swift
+
+type changeEventToSwiftDeliverer struct {
+	Container *schwift.Container
+}
+
+// Init implements the tenso.DeliveryHandler interface.
+func (h *changeEventToSwiftDeliverer) Init(ctx context.Context, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (err error) {
+	h.Container, err = tenso.InitializeSwiftDelivery(ctx, pc, eo, "TENSO_CHANGE_EVENT_SWIFT_CONTAINER")
+	return err
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (h *changeEventToSwiftDeliverer) PluginTypeID() string {
+	return "change-event-to-swift.v1"
+}
+
+// DeliverPayload implements the tenso.DeliveryHandler interface.
+func (h *changeEventToSwiftDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	event, err := jsonUnmarshalStrict[changeevent.Event](payload)
+	if err != nil {
+		return nil, err
+	}
+
+	identifier := event.CorrelationID
+	if identifier == "" {
+		identifier = event.Summary
+	}
+	outcome := string(event.Outcome)
+	timestamp := event.StartedAt
+	if !event.IsFinished() {
+		outcome = "in-progress"
+	} else {
+		timestamp = event.FinishedAt
+	}
+	objectName := fmt.Sprintf("%s/%s/%s/%s.json",
+		event.Family, identifier, outcome, timestamp.Format(time.RFC3339),
+	)
+	return tenso.DeliverToSwift(ctx, h.Container, objectName, payload)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for SNow
+
+// Builds the servicenow.Change for a change event.
+func serviceNowChangeOf(event changeevent.Event) servicenow.Change {
+	chg := servicenow.Change{
+		StartedAt:         event.StartedAt,
+		EndedAt:           event.FinishedAt,
+		Outcome:           event.Outcome,
+		Summary:           event.Summary,
+		Description:       event.Description,
+		ConfigurationItem: event.ConfigurationItem,
+		ErrorMessages:     event.ErrorMessages,
+		Executee:          event.Initiator,
+		Region:            event.Region,
+		AvailabilityZone:  event.AvailabilityZone,
+		CorrelationID:     event.CorrelationID,
+		Pipeline:          event.Pipeline,
+		HelmReleases:      event.HelmReleases,
+		GitRepos:          event.GitRepos,
+		AWXWorkflowName:   event.AWXWorkflowName,
+	}
+	for _, obj := range event.AffectedObjects {
+		chg.ConfigurationItemRefs = append(chg.ConfigurationItemRefs, servicenow.ConfigurationItemRef{
+			Kind: servicenow.ConfigurationItemKind(obj.Kind),
+			Name: obj.Name,
+		})
+	}
+	return chg
+}
+
+// Returns the ServiceNow mapping ruleset for the given family of change events.
+func serviceNowRulesetOf(cfg servicenow.MappingConfiguration, family changeevent.Family) (servicenow.MappingRuleset, error) {
+	switch family {
+	case changeevent.FamilyActiveDirectoryDeployment:
+		return cfg.ActiveDirectoryDeployment, nil
+	case changeevent.FamilyAWXWorkflow:
+		return cfg.AWXWorkflow, nil
+	case changeevent.FamilyHelmDeployment:
+		return cfg.HelmDeployment, nil
+	case changeevent.FamilyTerraformDeployment:
+		return cfg.TerraformDeployment, nil
+	default:
+		return nil, tenso.Permanent(fmt.Errorf("no ServiceNow mapping rules for change events of family %q", family))
+	}
+}
+
+type changeEventToSNowTranslator struct {
+	Mapping servicenow.MappingConfiguration
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *changeEventToSNowTranslator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	t.Mapping, err = servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
+	return err
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *changeEventToSNowTranslator) PluginTypeID() string {
+	return changeevent.PayloadType + "->change-event-to-servicenow.v1"
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *changeEventToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := jsonUnmarshalStrict[changeevent.Event](payload)
+	if err != nil {
+		return nil, err
+	}
+	ruleset, err := serviceNowRulesetOf(t.Mapping, event.Family)
+	if err != nil {
+		return nil, err
+	}
+	return serviceNowChangeOf(event).Serialize(t.Mapping, ruleset, routingInfo)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// DeliveryHandler for SNow
+
+type changeEventToSNowDeliverer struct {
+	Mapping servicenow.MappingConfiguration
+}
+
+// Init implements the tenso.DeliveryHandler interface.
+func (d *changeEventToSNowDeliverer) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	d.Mapping, err = servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
+	return err
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (d *changeEventToSNowDeliverer) PluginTypeID() string {
+	return "change-event-to-servicenow.v1"
+}
+
+// DeliverPayload implements the tenso.DeliveryHandler interface.
+func (d *changeEventToSNowDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return d.Mapping.Endpoints.DeliverChangePayload(ctx, tenso.DeliveryState{}, payload, routingInfo)
+}
+
+// DeliverPayloadWithState implements the tenso.StatefulDeliveryHandler interface.
+func (d *changeEventToSNowDeliverer) DeliverPayloadWithState(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return d.Mapping.Endpoints.DeliverChangePayload(ctx, state, payload, routingInfo)
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers_test
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/test"
+)
+
+func TestChangeEventConversionFromIngressPayloadTypes(t *testing.T) {
+	t.Setenv("TENSO_HELM_DEPLOYMENT_CLUSTER_REGEX", "[a-z]{2}-[a-z]{2}-[0-9]{1}")
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+
+	s := test.NewSetup(t,
+		test.WithRoute("helm-deployment-from-concourse.v1 -> change-event-to-webhook.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+	assert.Equal(t, chain.String(), "helm-deployment-from-concourse.v1 -> change-event.v1 -> change-event-to-webhook.v1")
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/helm-deployment-from-concourse.v1.swift.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/change-event.v1.swift.json")
+}
+
+func TestChangeEventConversionToSNow(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+	t.Setenv("TENSO_HELM_DEPLOYMENT_CLUSTER_REGEX", "[a-z]{2}-[a-z]{2}-[0-9]{1}")
+	t.Setenv("TENSO_AWX_WORKFLOW_AZ_REGEX", "[a-z]{2}-[a-z]{2}-[0-9][a-z]")
+
+	// going through the change event must yield the same result as the
+	// family-specific translations into ServiceNow
+	testCases := []struct {
+		SourcePayloadType string
+		SourceFixturePath string
+		TargetFixturePath string
+	}{
+		{
+			SourcePayloadType: "active-directory-deployment-from-concourse.v2",
+			SourceFixturePath: "fixtures/active-directory-deployment-from-concourse.v2.dev.json",
+			TargetFixturePath: "fixtures/active-directory-deployment-to-servicenow.v1.dev.json",
+		},
+		{
+			SourcePayloadType: "helm-deployment-from-concourse.v1",
+			SourceFixturePath: "fixtures/helm-deployment-from-concourse.v1.swift.json",
+			TargetFixturePath: "fixtures/helm-deployment-to-servicenow.v1.swift.json",
+		},
+		{
+			SourcePayloadType: "helm-deployment-started-from-concourse.v1",
+			SourceFixturePath: "fixtures/helm-deployment-started-from-concourse.v1.swift.json",
+			TargetFixturePath: "fixtures/helm-deployment-to-servicenow.v1.started-swift.json",
+		},
+		{
+			SourcePayloadType: "infra-workflow-from-awx.v1",
+			SourceFixturePath: "fixtures/infra-workflow-from-awx.v1.good.json",
+			TargetFixturePath: "fixtures/infra-workflow-to-servicenow.v1.good.json",
+		},
+		{
+			SourcePayloadType: "terraform-deployment-from-concourse.v1",
+			SourceFixturePath: "fixtures/terraform-deployment-from-concourse.v1.failed.json",
+			TargetFixturePath: "fixtures/terraform-deployment-to-servicenow.v1.failed.json",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Logf("-- testing source payload type %s", tc.SourcePayloadType)
+		s := test.NewSetup(t,
+			test.WithRoute(tc.SourcePayloadType+" -> change-event-to-servicenow.v1"),
+		)
+		chain := s.Config.EnabledRoutes[0].TranslationChain
+		assert.Equal(t, chain.String(), tc.SourcePayloadType+" -> change-event.v1 -> change-event-to-servicenow.v1")
+
+		sourcePayloadBytes := must.ReturnT(os.ReadFile(tc.SourceFixturePath))(t)
+		targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+		expectTranslatedPayload(t, targetPayloadBytes, tc.TargetFixturePath)
+	}
+}
+
+func TestChangeEventValidation(t *testing.T) {
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
+
+	s := test.NewSetup(t,
+		test.WithRoute("change-event.v1 -> change-event-to-webhook.v1"),
+	)
+	vh := s.Config.EnabledRoutes[0].ValidationHandler
+
+	// change events can be submitted directly
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/change-event.v1.swift.json"))(t)
+	payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
+	assert.Equal(t, payloadInfo.Description, "helm-deployment: Deploy swift to qa-de-1, swift-utils to qa-de-1")
+	assert.Equal(t, payloadInfo.PolicyAttributes, map[string]string{
+		"region":   "qa-de-1",
+		"team":     "services",
+		"pipeline": "swift",
+	})
+
+	// test validation errors
+	var event map[string]any
+	must.SucceedT(t, json.Unmarshal(sourcePayloadBytes, &event))
+	event["family"] = "backup-run"
+	delete(event, "finished_at")
+	delete(event, "correlation_id")
+	event["availability_zone"] = "qa-de-1a"
+	event["affected_objects"] = []map[string]string{{"kind": "helm-chart", "name": ""}}
+	sourcePayloadBytes = must.ReturnT(json.Marshal(event))(t)
+
+	_, err := vh.ValidatePayload(sourcePayloadBytes, regionRx)
+	assert.Equal(t, tenso.ValidationErrorsOf(err), tenso.ValidationErrors{
+		{
+			Pointer: "/family",
+			Code:    tenso.ValidationErrorInvalid,
+			Message: `value for field family is invalid: "backup-run"`,
+		},
+		{
+			Pointer: "/outcome",
+			Code:    tenso.ValidationErrorNotAllowed,
+			Message: "field outcome may not be set if finished_at is not set",
+		},
+		{
+			Pointer: "/availability_zone",
+			Code:    tenso.ValidationErrorNotAllowed,
+			Message: "fields region and availability_zone may not be set at the same time",
+		},
+		{
+			Pointer: "/affected_objects/0/kind",
+			Code:    tenso.ValidationErrorInvalid,
+			Message: `value for field affected_objects[0].kind is invalid: "helm-chart"`,
+		},
+		{
+			Pointer: "/affected_objects/0/name",
+			Code:    tenso.ValidationErrorMissing,
+			Message: "value for field affected_objects[0].name is missing",
+		},
+	})
+}
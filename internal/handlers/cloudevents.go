@@ -0,0 +1,323 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/sapcc/go-api-declarations/deployevent"
+	"github.com/sapcc/go-bits/osext"
+
+	"github.com/sapcc/tenso/internal/cloudevents"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+func init() {
+	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &cloudEventValidator{} })
+	// CloudEvents can be routed to every target payload type that their data
+	// can be translated into, so the unwrapping translations are instantiated
+	// for whichever targets appear in the route configuration
+	tenso.ConfiguredTranslationHandlers = append(tenso.ConfiguredTranslationHandlers, func(typeID string) (tenso.TranslationHandler, error) {
+		targetPayloadType, ok := strings.CutPrefix(typeID, cloudevents.PayloadType+"->")
+		if !ok || tenso.IntermediatePayloadTypes[targetPayloadType] {
+			return nil, nil
+		}
+		return &cloudEventUnwrappingTranslator{targetPayloadType: targetPayloadType}, nil
+	})
+
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &cloudEventWrappingTranslator{sourcePayloadType: "active-directory-deployment-from-concourse.v2", Describe: describeDeployEventForCloudEvent}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &cloudEventWrappingTranslator{sourcePayloadType: "helm-deployment-from-concourse.v1", Describe: describeDeployEventForCloudEvent}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &cloudEventWrappingTranslator{sourcePayloadType: "infra-workflow-from-awx.v1", Describe: describeAWXWorkflowEventForCloudEvent}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &cloudEventWrappingTranslator{sourcePayloadType: "terraform-deployment-from-concourse.v1", Describe: describeDeployEventForCloudEvent}
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// shared functions
+
+// Parses the value of $TENSO_CLOUDEVENTS_TYPES, e.g.
+//
+//	"com.example.deployment=helm-deployment-from-concourse.v1, infra-workflow-from-awx.v1"
+//
+// into a mapping from CloudEvent type to Tenso payload type. Entries without
+// "=" declare a CloudEvent type that is identical to the payload type.
+func getCloudEventTypeMapping() (map[string]string, error) {
+	envVarName := "TENSO_CLOUDEVENTS_TYPES"
+	input, err := osext.NeedGetenv(envVarName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for field := range strings.SplitSeq(input, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		eventType, payloadType, ok := strings.Cut(field, "=")
+		if !ok {
+			payloadType = eventType
+		}
+		eventType = strings.TrimSpace(eventType)
+		payloadType = strings.TrimSpace(payloadType)
+		if eventType == "" || !tenso.IsWellFormedPayloadType(payloadType) {
+			return nil, fmt.Errorf("invalid entry in %s: %q", envVarName, field)
+		}
+		if result[eventType] != "" {
+			return nil, fmt.Errorf("invalid entry in %s: multiple values for %q", envVarName, eventType)
+		}
+		result[eventType] = payloadType
+	}
+	return result, nil
+}
+
+// Returns the CloudEvent in the given payload, as well as the payload type of its data.
+func parseCloudEvent(payload []byte, typeMapping map[string]string) (cloudevents.Event, string, error) {
+	var event cloudevents.Event
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return event, "", tenso.Permanent(err)
+	}
+	err = event.Validate()
+	if err != nil {
+		return event, "", tenso.Permanent(err)
+	}
+	if !event.HasJSONData() || event.Data == nil {
+		return event, "", tenso.Permanent(fmt.Errorf("expected event data of type application/json, but got %q", event.DataContentType))
+	}
+	payloadType, exists := typeMapping[event.Type]
+	if !exists {
+		return event, "", tenso.Permanent(fmt.Errorf("cannot accept events with type %q", event.Type))
+	}
+	return event, payloadType, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// ValidationHandler
+
+type cloudEventValidator struct {
+	TypeMapping        map[string]string
+	ValidationHandlers map[string]tenso.ValidationHandler // key = payload type
+}
+
+// Init implements the tenso.ValidationHandler interface.
+func (v *cloudEventValidator) Init(ctx context.Context, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (err error) {
+	v.TypeMapping, err = getCloudEventTypeMapping()
+	if err != nil {
+		return err
+	}
+
+	v.ValidationHandlers = make(map[string]tenso.ValidationHandler)
+	for _, payloadType := range v.TypeMapping {
+		if v.ValidationHandlers[payloadType] != nil {
+			continue
+		}
+		vh, err := tenso.InstantiateValidationHandler(payloadType)
+		if err != nil {
+			return fmt.Errorf("cannot instantiate validation for %s: %w", payloadType, err)
+		}
+		if vh == nil {
+			return fmt.Errorf("cannot validate %s", payloadType)
+		}
+		err = vh.Init(ctx, pc, eo)
+		if err != nil {
+			return fmt.Errorf("cannot initialize validation for %s: %w", payloadType, err)
+		}
+		v.ValidationHandlers[payloadType] = vh
+	}
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (v *cloudEventValidator) PluginTypeID() string {
+	return cloudevents.PayloadType
+}
+
+// ValidatePayload implements the tenso.ValidationHandler interface.
+func (v *cloudEventValidator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
+	event, payloadType, err := parseCloudEvent(payload, v.TypeMapping)
+	if err != nil {
+		return nil, err
+	}
+	pi, err := v.ValidationHandlers[payloadType].ValidatePayload(event.Data, regionRx)
+	if errs, ok := errors.AsType[tenso.ValidationErrors](err); ok {
+		return nil, inEventData(errs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("in event data: %w", err)
+	}
+	pi.Warnings = inEventData(pi.Warnings)
+	return pi, nil
+}
+
+// Rewrites validation errors for the data of a CloudEvent to point into the
+// "data" attribute of the CloudEvent itself.
+func inEventData(errs tenso.ValidationErrors) tenso.ValidationErrors {
+	if len(errs) == 0 {
+		return nil
+	}
+	result := make(tenso.ValidationErrors, len(errs))
+	for idx, e := range errs {
+		result[idx] = tenso.ValidationError{
+			Pointer: "/data" + e.Pointer,
+			Code:    e.Code,
+			Message: "in event data: " + e.Message,
+		}
+	}
+	return result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for CloudEvent ingress
+
+// cloudEventUnwrappingTranslator is a tenso.TranslationHandler that takes the
+// data out of a CloudEvent and translates it using the TranslationChain for
+// the data's payload type.
+type cloudEventUnwrappingTranslator struct {
+	targetPayloadType string
+	TypeMapping       map[string]string
+	TranslationChains map[string]tenso.TranslationChain // key = source payload type
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *cloudEventUnwrappingTranslator) Init(ctx context.Context, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (err error) {
+	t.TypeMapping, err = getCloudEventTypeMapping()
+	if err != nil {
+		return err
+	}
+
+	planner := tenso.NewTranslationPlanner(ctx, pc, eo)
+	t.TranslationChains = make(map[string]tenso.TranslationChain)
+	for _, payloadType := range t.TypeMapping {
+		if t.TranslationChains[payloadType] != nil {
+			continue
+		}
+		chain, err := planner.FindChain(payloadType, t.targetPayloadType)
+		if err != nil {
+			return err
+		}
+		if chain == nil {
+			// not an error: events of this type will be skipped for this target
+			continue
+		}
+		t.TranslationChains[payloadType] = chain
+	}
+	if len(t.TranslationChains) == 0 {
+		return fmt.Errorf("none of the payload types in TENSO_CLOUDEVENTS_TYPES can be translated into %s", t.targetPayloadType)
+	}
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *cloudEventUnwrappingTranslator) PluginTypeID() string {
+	return cloudevents.PayloadType + "->" + t.targetPayloadType
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *cloudEventUnwrappingTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, payloadType, err := parseCloudEvent(payload, t.TypeMapping)
+	if err != nil {
+		return nil, err
+	}
+	chain := t.TranslationChains[payloadType]
+	if chain == nil {
+		return nil, tenso.SkipDeliveryError{
+			Reason:  "no-translation",
+			Message: fmt.Sprintf("cannot translate %s into %s", payloadType, t.targetPayloadType),
+		}
+	}
+	return chain.TranslatePayload(event.Data, routingInfo)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for CloudEvent egress
+
+// cloudEventWrappingTranslator is a tenso.TranslationHandler that wraps the
+// source payload into a CloudEvent. The CloudEvent type is equal to the source
+// payload type.
+type cloudEventWrappingTranslator struct {
+	sourcePayloadType string
+	// Describe fills the "source", "subject" and "time" attributes.
+	// If it leaves the source empty, a fallback source is used.
+	Describe func(payload []byte, event *cloudevents.Event) error
+	// Region is used in the fallback source.
+	Region string
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *cloudEventWrappingTranslator) Init(_ context.Context, _ *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) error {
+	t.Region = eo.Region
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *cloudEventWrappingTranslator) PluginTypeID() string {
+	return t.sourcePayloadType + "->cloudevent-to-webhook.v1"
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *cloudEventWrappingTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	// the ID is derived from the payload, so that repeated translations of the same event yield the same ID
+	checksum := sha256.Sum256(payload)
+	event := cloudevents.Event{
+		SpecVersion:     cloudevents.SpecVersion,
+		ID:              hex.EncodeToString(checksum[:]),
+		Type:            t.sourcePayloadType,
+		DataContentType: "application/json",
+		Data:            payload,
+	}
+	err := t.Describe(payload, &event)
+	if err != nil {
+		return nil, err
+	}
+	if event.Source == "" {
+		event.Source = fmt.Sprintf("tenso://%s/%s", t.Region, t.sourcePayloadType)
+	}
+	err = event.Validate()
+	if err != nil {
+		return nil, tenso.Permanent(fmt.Errorf("cannot wrap payload into a valid CloudEvent: %w", err))
+	}
+	return json.Marshal(event)
+}
+
+func describeDeployEventForCloudEvent(payload []byte, ce *cloudevents.Event) error {
+	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	if err != nil {
+		return err
+	}
+	ce.Source = event.Pipeline.BuildURL
+	ce.Subject = fmt.Sprintf("%s/%s/%s", event.Pipeline.TeamName, event.Pipeline.PipelineName, event.Pipeline.JobName)
+	ce.Time = event.RecordedAt
+	return nil
+}
+
+func describeAWXWorkflowEventForCloudEvent(payload []byte, ce *cloudevents.Event) error {
+	event, err := jsonUnmarshalStrict[awxWorkflowEvent](payload)
+	if err != nil {
+		return err
+	}
+	ce.Source = event.URL
+	ce.Subject = event.Name
+	if event.FinishedAt != nil {
+		finishedAt := event.FinishedAt.UTC().Truncate(time.Microsecond)
+		ce.Time = &finishedAt
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/cloudevents"
+	"github.com/sapcc/tenso/internal/test"
+)
+
+func TestCloudEventIngress(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+	t.Setenv("TENSO_AWX_WORKFLOW_AZ_REGEX", "[a-z]{2}-[a-z]{2}-[0-9][a-z]")
+	t.Setenv("TENSO_CLOUDEVENTS_TYPES", "com.example.awx-workflow=infra-workflow-from-awx.v1")
+	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
+
+	s := test.NewSetup(t,
+		test.WithRoute("cloudevent.v1 -> infra-workflow-to-servicenow.v1"),
+	)
+	vh := s.Config.EnabledRoutes[0].ValidationHandler
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	event := cloudevents.Event{
+		SpecVersion: cloudevents.SpecVersion,
+		ID:          "174195",
+		Source:      "https://awx.scaleout.qa-de-1.cloud.sap/",
+		Type:        "com.example.awx-workflow",
+		Data:        must.ReturnT(os.ReadFile("fixtures/infra-workflow-from-awx.v1.good.json"))(t),
+	}
+	sourcePayloadBytes := must.ReturnT(json.Marshal(event))(t)
+
+	// validation and translation look at the event data
+	payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
+	assert.Equal(t, payloadInfo.Description, "ESX upgrade, qa-de-1a, node002-bb091.cc.qa-de-1.cloud.sap")
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/infra-workflow-to-servicenow.v1.good.json")
+
+	// events with unknown types are rejected
+	event.Type = "com.example.something-else"
+	sourcePayloadBytes = must.ReturnT(json.Marshal(event))(t)
+	_, err := vh.ValidatePayload(sourcePayloadBytes, regionRx)
+	assert.ErrEqual(t, err, `cannot accept events with type "com.example.something-else"`)
+}
+
+func TestCloudEventEgress(t *testing.T) {
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+	t.Setenv("TENSO_AWX_WORKFLOW_AZ_REGEX", "[a-z]{2}-[a-z]{2}-[0-9][a-z]")
+
+	s := test.NewSetup(t,
+		test.WithRoute("infra-workflow-from-awx.v1 -> cloudevent-to-webhook.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/infra-workflow-from-awx.v1.good.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+
+	var event cloudevents.Event
+	must.SucceedT(t, json.Unmarshal(targetPayloadBytes, &event))
+	must.SucceedT(t, event.Validate())
+	assert.Equal(t, event.Type, "infra-workflow-from-awx.v1")
+	assert.Equal(t, event.Source, "https://awx.scaleout.qa-de-1.cloud.sap/#/jobs/workflow/174195")
+	assert.Equal(t, event.Subject, "ESX upgrade")
+	assert.Equal(t, event.Time.Format("2006-01-02T15:04:05.999999Z07:00"), "2022-11-21T16:51:29.3283Z")
+	assert.Equal(t, event.HasJSONData(), true)
+
+	// translating the same payload again yields the same event ID
+	targetPayloadBytes2 := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	assert.Equal(t, string(targetPayloadBytes2), string(targetPayloadBytes))
+
+	// payloads without a URL get a fallback source, since the source attribute is required
+	sourcePayloadBytes = bytes.Replace(sourcePayloadBytes,
+		[]byte(`"url": "https://awx.scaleout.qa-de-1.cloud.sap/#/jobs/workflow/174195"`), []byte(`"url": ""`), 1)
+	targetPayloadBytes = must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	must.SucceedT(t, json.Unmarshal(targetPayloadBytes, &event))
+	must.SucceedT(t, event.Validate())
+	assert.Equal(t, event.Source, "tenso://qa-de-1/infra-workflow-from-awx.v1")
+}
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/sapcc/go-api-declarations/deployevent"
+
+	"github.com/sapcc/tenso/internal/changeevent"
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/teams"
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/topology"
+)
+
+// The "*-started-from-concourse" payload types are sent by Concourse when a
+// deployment starts. They have the same format as the respective events for
+// the finished deployment, but without outcomes and finish timestamps.
+//
+// Their main purpose is to open a change in ServiceNow that will then be
+// closed when the event for the finished deployment arrives. They can also be
+// translated into change events (see package changeevent) that are still in
+// progress.
+
+func init() {
+	for _, kind := range deploymentStartedKinds {
+		tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &deploymentStartedValidator{Kind: kind} })
+		tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &deploymentStartedToSNowTranslator{Kind: kind} })
+		tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+			return &changeEventTranslator{kind.SourcePayloadType, kind.ChangeEventOf}
+		})
+	}
+}
+
+// deploymentStartedKind contains everything that differs between the
+// different "*-started-from-concourse" payload types.
+type deploymentStartedKind struct {
+	SourcePayloadType string
+	TargetPayloadType string
+	Family            changeevent.Family
+	// Validates the parts of the event that are specific to this kind.
+	// The Event has already been validated by parseAndValidateDeployEvent().
+	Validate func(event deployevent.Event, topo topology.Config, errs *tenso.ValidationErrors)
+	// Returns a short description like "deploy swift to qa-de-1".
+	Describe func(event deployevent.Event) string
+	// Returns the summary for the change in ServiceNow.
+	Summarize func(event deployevent.Event) string
+}
+
+var deploymentStartedKinds = []deploymentStartedKind{
+	{
+		SourcePayloadType: "active-directory-deployment-started-from-concourse.v2",
+		TargetPayloadType: "active-directory-deployment-to-servicenow.v1",
+		Family:            changeevent.FamilyActiveDirectoryDeployment,
+		Validate: func(event deployevent.Event, _ topology.Config, errs *tenso.ValidationErrors) {
+			if len(event.HelmReleases) != 0 {
+				errs.Add("/helm-release", tenso.ValidationErrorNotAllowed, "helm-release[] may not be set for Active Directory deployment events")
+			}
+			if len(event.TerraformRuns) != 0 {
+				errs.Add("/terraform-runs", tenso.ValidationErrorNotAllowed, "terraform-runs[] may not be set for Active Directory deployment events")
+			}
+			if event.ADDeployment == nil {
+				errs.Add("/active-directory-deployment", tenso.ValidationErrorMissing, "active-directory-deployment may not be empty")
+				return
+			}
+			ad := *event.ADDeployment
+			if ad.Landscape == "" {
+				errs.Add("/active-directory-deployment/landscape", tenso.ValidationErrorMissing, "active-directory-deployment.landscape may not be empty")
+			}
+			if !strings.HasSuffix(ad.Hostname, ".sap") {
+				errs.Add("/active-directory-deployment/host", tenso.ValidationErrorInvalid, `value for field active-directory-deployment.host is invalid: %q`, ad.Hostname)
+			}
+			validateDeploymentStartedItem("/active-directory-deployment", "active-directory-deployment", ad.Outcome, ad.FinishedAt != nil, errs)
+		},
+		Describe: func(event deployevent.Event) string {
+			return "deploy AD to " + event.ADDeployment.Hostname
+		},
+		Summarize: func(event deployevent.Event) string {
+			return "Deploy AD to " + event.ADDeployment.Hostname
+		},
+	},
+	{
+		SourcePayloadType: "helm-deployment-started-from-concourse.v1",
+		TargetPayloadType: "helm-deployment-to-servicenow.v1",
+		Family:            changeevent.FamilyHelmDeployment,
+		Validate: func(event deployevent.Event, topo topology.Config, errs *tenso.ValidationErrors) {
+			if event.ADDeployment != nil {
+				errs.Add("/active-directory-deployment", tenso.ValidationErrorNotAllowed, "active-directory-deployment may not be set for Helm deployment events")
+			}
+			if len(event.TerraformRuns) != 0 {
+				errs.Add("/terraform-runs", tenso.ValidationErrorNotAllowed, "terraform-runs[] may not be set for Helm deployment events")
+			}
+			if len(event.HelmReleases) == 0 {
+				errs.Add("/helm-release", tenso.ValidationErrorMissing, "helm-release[] may not be empty")
+			}
+			for idx, relInfo := range event.HelmReleases {
+				if relInfo == nil {
+					errs.Add(jsonPointer("helm-release", idx), tenso.ValidationErrorMissing, `helm-release[%d] may not be nil`, idx)
+					continue
+				}
+				if relInfo.Name == "" {
+					errs.Add(jsonPointer("helm-release", idx, "name"), tenso.ValidationErrorMissing, `invalid value for field helm-release[].name: %q`, relInfo.Name)
+				}
+				if !topo.IsClusterLocatedInRegion(relInfo.Cluster, event.Region) {
+					errs.Add(jsonPointer("helm-release", idx, "cluster"), tenso.ValidationErrorInvalid, `in helm-release %q: cluster %q is not in region %q`, relInfo.Name, relInfo.Cluster, event.Region)
+				}
+				validateDeploymentStartedItem(jsonPointer("helm-release", idx), fmt.Sprintf("helm-release %q", relInfo.Name), relInfo.Outcome, relInfo.FinishedAt != nil, errs)
+			}
+		},
+		Describe: func(event deployevent.Event) string {
+			return "deploy " + strings.Join(releaseDescriptorsOf(event, " to "), " and ")
+		},
+		Summarize: func(event deployevent.Event) string {
+			return "Deploy " + strings.Join(releaseDescriptorsOf(event, " to "), ", ")
+		},
+	},
+	{
+		SourcePayloadType: "terraform-deployment-started-from-concourse.v1",
+		TargetPayloadType: "terraform-deployment-to-servicenow.v1",
+		Family:            changeevent.FamilyTerraformDeployment,
+		Validate: func(event deployevent.Event, _ topology.Config, errs *tenso.ValidationErrors) {
+			if event.ADDeployment != nil {
+				errs.Add("/active-directory-deployment", tenso.ValidationErrorNotAllowed, "active-directory-deployment may not be set for Terraform run events")
+			}
+			if len(event.HelmReleases) != 0 {
+				errs.Add("/helm-release", tenso.ValidationErrorNotAllowed, "helm-release[] may not be set for Terraform run events")
+			}
+			if len(event.TerraformRuns) == 0 {
+				errs.Add("/terraform-runs", tenso.ValidationErrorMissing, "terraform-runs[] may not be empty")
+			}
+			for idx, runInfo := range event.TerraformRuns {
+				if runInfo == nil {
+					errs.Add(jsonPointer("terraform-runs", idx), tenso.ValidationErrorMissing, `terraform-runs[%d] may not be nil`, idx)
+					continue
+				}
+				if runInfo.ChangeSummary != nil {
+					errs.Add(jsonPointer("terraform-runs", idx, "change-summary"), tenso.ValidationErrorNotAllowed, `in terraform-runs[%d]: field change-summary may not be set before the run has finished`, idx)
+				}
+				validateDeploymentStartedItem(jsonPointer("terraform-runs", idx), fmt.Sprintf("terraform-runs[%d]", idx), runInfo.Outcome, runInfo.FinishedAt != nil, errs)
+			}
+		},
+		Describe: func(event deployevent.Event) string {
+			return "run Terraform for " + event.Pipeline.JobName
+		},
+		Summarize: func(event deployevent.Event) string {
+			return fmt.Sprintf("Deploy %s for %s", event.Pipeline.PipelineName, event.Pipeline.JobName)
+		},
+	},
+}
+
+// NOTE: started-at is optional for each item because items that are deployed
+// later in the same job (e.g. multiple Helm releases) have not started yet.
+func validateDeploymentStartedItem(pointer, itemDesc string, outcome deployevent.Outcome, hasFinishedAt bool, errs *tenso.ValidationErrors) {
+	if outcome != "" {
+		errs.Add(pointer+"/outcome", tenso.ValidationErrorNotAllowed, `in %s: field outcome may not be set before the deployment has finished`, itemDesc)
+	}
+	if hasFinishedAt {
+		errs.Add(pointer+"/finished-at", tenso.ValidationErrorNotAllowed, `in %s: field finished-at may not be set before the deployment has finished`, itemDesc)
+	}
+}
+
+// ChangeEventOf builds the change event for a payload of this kind.
+func (k deploymentStartedKind) ChangeEventOf(payload []byte) (changeevent.Event, error) {
+	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	if err != nil {
+		return changeevent.Event{}, err
+	}
+
+	summary := k.Summarize(event)
+	result := changeEventOfDeployEvent(k.Family, event)
+	result.Summary = summary
+	result.Description = fmt.Sprintf("%s with versions: %s\nDeployment log: %s",
+		summary, strings.Join(inputDescriptorsOf(event), ", "), event.Pipeline.BuildURL)
+	result.FinishedAt = nil // this marks the change as in progress
+	result.Outcome = ""
+	return result, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// ValidationHandler
+
+type deploymentStartedValidator struct {
+	Kind     deploymentStartedKind
+	Topology *topology.Topology
+	Teams    *teams.Registry
+}
+
+// Init implements the tenso.ValidationHandler interface.
+func (v *deploymentStartedValidator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	v.Topology, err = topology.Load("TENSO_TOPOLOGY_CONFIG_PATH")
+	if err != nil {
+		return err
+	}
+	v.Teams, err = teams.Load("TENSO_TEAMS_CONFIG_PATH")
+	return err
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (v *deploymentStartedValidator) PluginTypeID() string {
+	return v.Kind.SourcePayloadType
+}
+
+// ValidatePayload implements the tenso.ValidationHandler interface.
+func (v *deploymentStartedValidator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
+	var errs, warnings tenso.ValidationErrors
+	event, err := parseAndValidateDeployEvent(payload, regionRx, v.Teams.Current(), &errs, &warnings)
+	if err != nil {
+		return nil, err
+	}
+	v.Kind.Validate(event, v.Topology.Current(), &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &tenso.PayloadInfo{
+		Description: fmt.Sprintf("%s/%s: started to %s",
+			event.Pipeline.TeamName, event.Pipeline.PipelineName, v.Kind.Describe(event)),
+		Warnings:         warnings,
+		PolicyAttributes: policyAttributesOfDeployEvent(event),
+	}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for SNow
+
+type deploymentStartedToSNowTranslator struct {
+	Kind    deploymentStartedKind
+	Mapping servicenow.MappingConfiguration
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *deploymentStartedToSNowTranslator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	t.Mapping, err = servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
+	return err
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *deploymentStartedToSNowTranslator) PluginTypeID() string {
+	return t.Kind.SourcePayloadType + "->" + t.Kind.TargetPayloadType
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *deploymentStartedToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := t.Kind.ChangeEventOf(payload)
+	if err != nil {
+		return nil, err
+	}
+	ruleset, err := serviceNowRulesetOf(t.Mapping, t.Kind.Family)
+	if err != nil {
+		return nil, err
+	}
+	return serviceNowChangeOf(event).Serialize(t.Mapping, ruleset, routingInfo)
+}
@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/test"
+)
+
+var deploymentStartedTestCases = []struct {
+	Route               string
+	SourceFixturePath   string
+	TargetFixturePath   string
+	ExpectedDescription string
+}{
+	{
+		Route:               "active-directory-deployment-started-from-concourse.v2 -> active-directory-deployment-to-servicenow.v1",
+		SourceFixturePath:   "fixtures/active-directory-deployment-started-from-concourse.v2.dev.json",
+		TargetFixturePath:   "fixtures/active-directory-deployment-to-servicenow.v1.started-dev.json",
+		ExpectedDescription: "core/active-directory: started to deploy AD to ad-dev.example.sap",
+	},
+	{
+		Route:               "helm-deployment-started-from-concourse.v1 -> helm-deployment-to-servicenow.v1",
+		SourceFixturePath:   "fixtures/helm-deployment-started-from-concourse.v1.swift.json",
+		TargetFixturePath:   "fixtures/helm-deployment-to-servicenow.v1.started-swift.json",
+		ExpectedDescription: "services/swift: started to deploy swift to qa-de-1 and swift-utils to qa-de-1",
+	},
+	{
+		Route:               "terraform-deployment-started-from-concourse.v1 -> terraform-deployment-to-servicenow.v1",
+		SourceFixturePath:   "fixtures/terraform-deployment-started-from-concourse.v1.terragrunt-virtual-apod.json",
+		TargetFixturePath:   "fixtures/terraform-deployment-to-servicenow.v1.started-terragrunt-virtual-apod.json",
+		ExpectedDescription: "services/terragrunt-virtual-apod: started to run Terraform for vnode4-v-qa-de-1",
+	},
+}
+
+func TestDeploymentStartedValidationSuccess(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
+
+	for _, tc := range deploymentStartedTestCases {
+		t.Logf("-- testing route %s", tc.Route)
+		s := test.NewSetup(t, test.WithRoute(tc.Route))
+		vh := s.Config.EnabledRoutes[0].ValidationHandler
+
+		sourcePayloadBytes := must.ReturnT(os.ReadFile(tc.SourceFixturePath))(t)
+		payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
+		assert.Equal(t, payloadInfo.Description, tc.ExpectedDescription)
+	}
+}
+
+func TestDeploymentStartedValidationErrors(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
+
+	s := test.NewSetup(t,
+		test.WithRoute("helm-deployment-started-from-concourse.v1 -> helm-deployment-to-servicenow.v1"),
+	)
+	vh := s.Config.EnabledRoutes[0].ValidationHandler
+
+	// events for finished deployments are not accepted under this payload type
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/helm-deployment-from-concourse.v1.swift.json"))(t)
+	_, err := vh.ValidatePayload(sourcePayloadBytes, regionRx)
+	assert.Equal(t, tenso.ValidationErrorsOf(err), tenso.ValidationErrors{
+		{
+			Pointer: "/helm-release/0/outcome",
+			Code:    tenso.ValidationErrorNotAllowed,
+			Message: `in helm-release "swift": field outcome may not be set before the deployment has finished`,
+		},
+		{
+			Pointer: "/helm-release/0/finished-at",
+			Code:    tenso.ValidationErrorNotAllowed,
+			Message: `in helm-release "swift": field finished-at may not be set before the deployment has finished`,
+		},
+		{
+			Pointer: "/helm-release/1/outcome",
+			Code:    tenso.ValidationErrorNotAllowed,
+			Message: `in helm-release "swift-utils": field outcome may not be set before the deployment has finished`,
+		},
+		{
+			Pointer: "/helm-release/1/finished-at",
+			Code:    tenso.ValidationErrorNotAllowed,
+			Message: `in helm-release "swift-utils": field finished-at may not be set before the deployment has finished`,
+		},
+	})
+}
+
+func TestDeploymentStartedConversionToSNow(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+
+	for _, tc := range deploymentStartedTestCases {
+		t.Logf("-- testing route %s", tc.Route)
+		s := test.NewSetup(t, test.WithRoute(tc.Route))
+		chain := s.Config.EnabledRoutes[0].TranslationChain
+
+		sourcePayloadBytes := must.ReturnT(os.ReadFile(tc.SourceFixturePath))(t)
+		targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+		expectTranslatedPayload(t, targetPayloadBytes, tc.TargetFixturePath)
+	}
+}
+
+// An http.RoundTripper that drops the response body of successful record
+// creations, to simulate ServiceNow instances that do not return the created record.
+type withoutResponseBodyOnCreate struct {
+	inner http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt withoutResponseBodyOnCreate) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.inner.RoundTrip(req)
+	if err == nil && req.Method == http.MethodPost && resp.StatusCode == http.StatusCreated {
+		resp.Body.Close()
+		resp.Body = io.NopCloser(strings.NewReader("{}"))
+	}
+	return resp, err
+}
+
+func TestDeploymentLifecycleInServiceNow(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+	t.Setenv("TENSO_HELM_DEPLOYMENT_CLUSTER_REGEX", "[a-z]{2}-[a-z]{2}-[0-9]{1}")
+
+	s := test.NewSetup(t,
+		test.WithRoute("helm-deployment-started-from-concourse.v1 -> helm-deployment-to-servicenow.v1"),
+		test.WithRoute("helm-deployment-from-concourse.v1 -> helm-deployment-to-servicenow.v1"),
+		test.WithRoute("terraform-deployment-started-from-concourse.v1 -> terraform-deployment-to-servicenow.v1"),
+		test.WithRoute("terraform-deployment-from-concourse.v1 -> terraform-deployment-to-servicenow.v1"),
+		test.WithServiceNowMock,
+	)
+
+	// translates and delivers an event in the same way as the worker would
+	deliver := func(eventID int64, sourcePayloadType string, sourcePayloadBytes []byte) (*tenso.DeliveryLog, error) {
+		t.Helper()
+		idx := slices.IndexFunc(s.Config.EnabledRoutes, func(r tenso.Route) bool { return r.SourcePayloadType == sourcePayloadType })
+		route := s.Config.EnabledRoutes[idx]
+		targetPayloadBytes := must.ReturnT(route.TranslationChain.TranslatePayload(sourcePayloadBytes, nil))(t)
+
+		tx := must.ReturnT(s.DB.Begin())(t)
+		state := tenso.DeliveryState{Tx: tx, Now: s.Clock.Now(), EventID: eventID, PayloadType: route.TargetPayloadType, Region: s.Config.Region}
+		dh := route.DeliveryHandler.(tenso.StatefulDeliveryHandler)
+		dlog, err := dh.DeliverPayloadWithState(s.Ctx, state, targetPayloadBytes, nil)
+		must.SucceedT(t, tx.Commit())
+		return dlog, err
+	}
+	startedPayload := must.ReturnT(os.ReadFile("fixtures/helm-deployment-started-from-concourse.v1.swift.json"))(t)
+	finishedPayload := must.ReturnT(os.ReadFile("fixtures/helm-deployment-from-concourse.v1.swift.json"))(t)
+
+	// when the deployment starts, an in-progress change is opened
+	dlog := must.ReturnT(deliver(1, "helm-deployment-started-from-concourse.v1", startedPayload))(t)
+	assert.Equal(t, dlog.Message, "opened CHG0000001 in ServiceNow")
+	changes := s.ServiceNow.Records("change_request")
+	assert.Equal(t, len(changes), 1)
+	assert.Equal(t, changes[0]["state"], "implement")
+	assert.Equal(t, changes[0]["correlation_id"], "tenso:qa-de-1:1:helm-deployment-to-servicenow.v1:default:change")
+
+	// when the deployment finishes, the same change is closed
+	s.Clock.StepBy(5 * time.Minute)
+	dlog = must.ReturnT(deliver(2, "helm-deployment-from-concourse.v1", finishedPayload))(t)
+	assert.Equal(t, dlog.Message, "closed CHG0000001 in ServiceNow")
+	changes = s.ServiceNow.Records("change_request")
+	assert.Equal(t, len(changes), 1)
+	assert.Equal(t, changes[0]["state"], "closed")
+	assert.Equal(t, changes[0]["close_code"], "Implemented - Successfully")
+
+	// a start event that is delivered after the deployment has finished does not open another change
+	_, err := deliver(3, "helm-deployment-started-from-concourse.v1", startedPayload)
+	skip, ok := errors.AsType[tenso.SkipDeliveryError](err)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, skip.Reason, "already-closed")
+	assert.Equal(t, len(s.ServiceNow.Records("change_request")), 1)
+
+	// this also holds when no change was created for the finished deployment
+	// (by default, failed deployments are not reported to ServiceNow)
+	inOtherBuild := func(payload []byte) []byte {
+		return bytes.ReplaceAll(payload, []byte(`"build-number": "82"`), []byte(`"build-number": "83"`))
+	}
+	failedPayload := bytes.ReplaceAll(inOtherBuild(finishedPayload), []byte(`"outcome": "succeeded"`), []byte(`"outcome": "helm-upgrade-failed"`))
+	_, err = deliver(4, "helm-deployment-from-concourse.v1", failedPayload)
+	skip, ok = errors.AsType[tenso.SkipDeliveryError](err)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, skip.Reason, "helm-upgrade-failed")
+	_, err = deliver(5, "helm-deployment-started-from-concourse.v1", inOtherBuild(startedPayload))
+	skip, ok = errors.AsType[tenso.SkipDeliveryError](err)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, skip.Reason, "already-closed")
+	assert.Equal(t, len(s.ServiceNow.Records("change_request")), 1)
+
+	// a single build can perform multiple deployments, e.g. of a Terraform
+	// configuration before a Helm chart, and each of them gets its own change
+	inSwiftBuild := strings.NewReplacer(
+		`"build-number": "49"`, `"build-number": "82"`,
+		"/pipelines/terragrunt-virtual-apod/jobs/vnode4-v-qa-de-1/builds/49", "/pipelines/swift/jobs/qa-de-1/builds/82",
+		`"job": "vnode4-v-qa-de-1"`, `"job": "qa-de-1"`,
+		`"name": "terragrunt-virtual-apod"`, `"name": "swift"`,
+	)
+	terraformStartedPayload := inSwiftBuild.Replace(string(must.ReturnT(os.ReadFile("fixtures/terraform-deployment-started-from-concourse.v1.terragrunt-virtual-apod.json"))(t)))
+	terraformFinishedPayload := inSwiftBuild.Replace(string(must.ReturnT(os.ReadFile("fixtures/terraform-deployment-from-concourse.v1.terragrunt-virtual-apod.json"))(t)))
+	dlog = must.ReturnT(deliver(6, "terraform-deployment-started-from-concourse.v1", []byte(terraformStartedPayload)))(t)
+	assert.Equal(t, dlog.Message, "opened CHG0000002 in ServiceNow")
+	dlog = must.ReturnT(deliver(7, "terraform-deployment-from-concourse.v1", []byte(terraformFinishedPayload)))(t)
+	assert.Equal(t, dlog.Message, "closed CHG0000002 in ServiceNow")
+
+	// the same goes for multiple Helm deployments of different releases in the same build
+	inOtherNamespace := func(payload []byte) []byte {
+		return bytes.ReplaceAll(payload, []byte(`"kubernetes-namespace": "swift"`), []byte(`"kubernetes-namespace": "swift-canary"`))
+	}
+	dlog = must.ReturnT(deliver(8, "helm-deployment-started-from-concourse.v1", inOtherNamespace(startedPayload)))(t)
+	assert.Equal(t, dlog.Message, "opened CHG0000003 in ServiceNow")
+	dlog = must.ReturnT(deliver(9, "helm-deployment-from-concourse.v1", inOtherNamespace(finishedPayload)))(t)
+	assert.Equal(t, dlog.Message, "closed CHG0000003 in ServiceNow")
+	assert.Equal(t, len(s.ServiceNow.Records("change_request")), 3)
+
+	// if the response does not contain the sys_id of an opened change, the
+	// change cannot be closed later, but no second change is created either
+	mappingConfig := must.ReturnT(servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH"))(t)
+	for _, client := range mappingConfig.Endpoints {
+		client.OverrideHTTPClient(&http.Client{Transport: withoutResponseBodyOnCreate{s.ServiceNow.HTTPClient().Transport}})
+	}
+	inThirdBuild := func(payload []byte) []byte {
+		return bytes.ReplaceAll(payload, []byte(`"build-number": "82"`), []byte(`"build-number": "84"`))
+	}
+	_ = must.ReturnT(deliver(10, "helm-deployment-started-from-concourse.v1", inThirdBuild(startedPayload)))(t)
+	_, err = deliver(11, "helm-deployment-from-concourse.v1", inThirdBuild(finishedPayload))
+	skip, ok = errors.AsType[tenso.SkipDeliveryError](err)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, skip.Reason, "unknown-sys-id")
+	assert.Equal(t, len(s.ServiceNow.Records("change_request")), 4)
+}
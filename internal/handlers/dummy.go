@@ -12,9 +12,15 @@ import (
 )
 
 func init() {
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"change-event.v1->change-event-to-elk.v1"}
+	})
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
 		return &dummyTranslator{"helm-deployment-from-concourse.v1->helm-deployment-to-elk.v1"}
 	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"change-event.v1->change-event-to-swift.v1"}
+	})
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
 		return &dummyTranslator{"helm-deployment-from-concourse.v1->helm-deployment-to-swift.v1"}
 	})
@@ -24,6 +30,23 @@ func init() {
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
 		return &dummyTranslator{"terraform-deployment-from-concourse.v1->terraform-deployment-to-swift.v1"}
 	})
+
+	// webhooks receive the ingress payload verbatim
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"active-directory-deployment-from-concourse.v2->active-directory-deployment-to-webhook.v1"}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"change-event.v1->change-event-to-webhook.v1"}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"helm-deployment-from-concourse.v1->helm-deployment-to-webhook.v1"}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"infra-workflow-from-awx.v1->infra-workflow-to-webhook.v1"}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &dummyTranslator{"terraform-deployment-from-concourse.v1->terraform-deployment-to-webhook.v1"}
+	})
 }
 
 // dummyTranslator is a tenso.TranslationHandler for no-op translations.
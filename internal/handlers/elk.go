@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/sapcc/go-api-declarations/deployevent"
+
+	"github.com/sapcc/tenso/internal/changeevent"
+	"github.com/sapcc/tenso/internal/elk"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+func init() {
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &elkFlatteningTranslator{"active-directory-deployment-from-concourse.v2", "active-directory-deployment-to-elk.v1", activeDirectoryDeploymentV2ChangeEventOf}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &elkFlatteningTranslator{"infra-workflow-from-awx.v1", "infra-workflow-to-elk.v1", awxWorkflowChangeEventOf}
+	})
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &elkFlatteningTranslator{"terraform-deployment-from-concourse.v1", "terraform-deployment-to-elk.v1", terraformDeploymentChangeEventOf}
+	})
+
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+		return &elkDeliverer{pluginTypeID: "active-directory-deployment-to-elk.v1", Describe: describeFlatDocumentForELK}
+	})
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+		return &elkDeliverer{pluginTypeID: "change-event-to-elk.v1", Describe: describeChangeEventForELK}
+	})
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+		return &elkDeliverer{pluginTypeID: "helm-deployment-to-elk.v1", Describe: describeDeployEventForELK}
+	})
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+		return &elkDeliverer{pluginTypeID: "infra-workflow-to-elk.v1", Describe: describeFlatDocumentForELK}
+	})
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+		return &elkDeliverer{pluginTypeID: "terraform-deployment-to-elk.v1", Describe: describeFlatDocumentForELK}
+	})
+}
+
+// elkDeliverer is a tenso.DeliveryHandler that indexes payloads in ELK.
+type elkDeliverer struct {
+	pluginTypeID string
+	// Describe builds the elk.Document for the given payload.
+	Describe func(payload []byte) (elk.Document, error)
+	Config   elk.Configuration
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (h *elkDeliverer) PluginTypeID() string {
+	return h.pluginTypeID
+}
+
+// Init implements the tenso.DeliveryHandler interface.
+func (h *elkDeliverer) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	// for backwards compatibility, Helm deployments can also be delivered to a plain Logstash host
+	if h.pluginTypeID == "helm-deployment-to-elk.v1" && os.Getenv("TENSO_ELK_CONFIG_PATH") == "" {
+		if host := os.Getenv("TENSO_HELM_DEPLOYMENT_LOGSTASH_HOST"); host != "" {
+			h.Config, err = elk.NewLogstashConfiguration(host)
+			return err
+		}
+	}
+
+	h.Config, err = elk.LoadConfiguration("TENSO_ELK_CONFIG_PATH")
+	return err
+}
+
+// DeliverPayload implements the tenso.DeliveryHandler interface.
+func (h *elkDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return h.DeliverPayloadWithState(ctx, tenso.DeliveryState{}, payload, routingInfo)
+}
+
+// DeliverPayloadWithState implements the tenso.StatefulDeliveryHandler interface.
+func (h *elkDeliverer) DeliverPayloadWithState(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	doc, err := h.Describe(payload)
+	if err != nil {
+		return nil, err
+	}
+	// The document ID identifies the delivery, so that a retried delivery
+	// overwrites the document even if the payload was translated differently.
+	// The region is included because event IDs are only unique within one
+	// Tenso instance, but several Tenso instances may deliver into the same index.
+	if state.EventID != 0 {
+		doc.ID = fmt.Sprintf("tenso:%s:%d:%s", state.Region, state.EventID, state.PayloadType)
+	}
+	return h.Config.Endpoints.Deliver(ctx, h.pluginTypeID, doc, routingInfo)
+}
+
+func describeDeployEventForELK(payload []byte) (elk.Document, error) {
+	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	if err != nil {
+		return elk.Document{}, err
+	}
+	return elk.Document{
+		Payload: payload,
+		Team:    event.Pipeline.TeamName,
+		Time:    event.RecordedAt,
+	}, nil
+}
+
+func describeChangeEventForELK(payload []byte) (elk.Document, error) {
+	event, err := jsonUnmarshalStrict[changeevent.Event](payload)
+	if err != nil {
+		return elk.Document{}, err
+	}
+	doc := elk.Document{Payload: payload, Time: event.FinishedAt}
+	if doc.Time == nil {
+		doc.Time = event.StartedAt
+	}
+	if event.Pipeline != nil {
+		doc.Team = event.Pipeline.TeamName
+	}
+	return doc, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// flattened documents
+
+// elkFlatDocument is the document shape for the "*-to-elk.v1" payload types
+// of Active Directory deployments, AWX workflows and Terraform runs. All fields
+// are on the top level and have simple types, so that they can be indexed and
+// aggregated on without nested mappings.
+type elkFlatDocument struct {
+	Family            changeevent.Family `json:"family"`
+	SourcePayloadType string             `json:"source_payload_type"`
+	Summary           string             `json:"summary"`
+	Description       string             `json:"description"`
+
+	StartedAt       *time.Time          `json:"started_at,omitempty"`
+	FinishedAt      *time.Time          `json:"finished_at,omitempty"`
+	DurationSeconds *float64            `json:"duration_seconds,omitempty"`
+	Outcome         deployevent.Outcome `json:"outcome,omitempty"`
+	ErrorMessages   []string            `json:"error_messages,omitempty"`
+
+	Region            string   `json:"region,omitempty"`
+	AvailabilityZone  string   `json:"availability_zone,omitempty"`
+	Initiator         string   `json:"initiator,omitempty"`
+	CorrelationID     string   `json:"correlation_id,omitempty"`
+	AffectedObjects   []string `json:"affected_objects,omitempty"` // each formatted as "<kind>:<name>"
+	ConfigurationItem string   `json:"configuration_item,omitempty"`
+
+	Team        string `json:"team,omitempty"`
+	Pipeline    string `json:"pipeline,omitempty"`
+	Job         string `json:"job,omitempty"`
+	BuildNumber string `json:"build_number,omitempty"`
+	BuildURL    string `json:"build_url,omitempty"`
+
+	AWXWorkflowName string `json:"awx_workflow_name,omitempty"`
+
+	// The resource counts are totals across all Terraform runs with a change
+	// summary, and not set if no run has one.
+	TerraformRuns               int  `json:"terraform_runs,omitempty"`
+	TerraformResourcesAdded     *int `json:"terraform_resources_added,omitempty"`
+	TerraformResourcesChanged   *int `json:"terraform_resources_changed,omitempty"`
+	TerraformResourcesDestroyed *int `json:"terraform_resources_destroyed,omitempty"`
+}
+
+func flattenChangeEventForELK(event changeevent.Event) elkFlatDocument {
+	doc := elkFlatDocument{
+		Family:            event.Family,
+		SourcePayloadType: event.SourcePayloadType,
+		Summary:           event.Summary,
+		Description:       event.Description,
+		StartedAt:         event.StartedAt,
+		FinishedAt:        event.FinishedAt,
+		Outcome:           event.Outcome,
+		ErrorMessages:     event.ErrorMessages,
+		Region:            event.Region,
+		AvailabilityZone:  event.AvailabilityZone,
+		Initiator:         event.Initiator,
+		CorrelationID:     event.CorrelationID,
+		ConfigurationItem: event.ConfigurationItem,
+		AWXWorkflowName:   event.AWXWorkflowName,
+		TerraformRuns:     len(event.TerraformRuns),
+	}
+	if event.StartedAt != nil && event.FinishedAt != nil {
+		duration := event.FinishedAt.Sub(*event.StartedAt).Seconds()
+		doc.DurationSeconds = &duration
+	}
+	for _, obj := range event.AffectedObjects {
+		doc.AffectedObjects = append(doc.AffectedObjects, fmt.Sprintf("%s:%s", obj.Kind, obj.Name))
+	}
+	if p := event.Pipeline; p != nil {
+		doc.Team = p.TeamName
+		doc.Pipeline = p.PipelineName
+		doc.Job = p.JobName
+		doc.BuildNumber = p.BuildNumber
+		doc.BuildURL = p.BuildURL
+	}
+
+	for _, run := range event.TerraformRuns {
+		if run.ChangeSummary == nil {
+			continue
+		}
+		if doc.TerraformResourcesAdded == nil {
+			doc.TerraformResourcesAdded = new(int)
+			doc.TerraformResourcesChanged = new(int)
+			doc.TerraformResourcesDestroyed = new(int)
+		}
+		*doc.TerraformResourcesAdded += run.ChangeSummary.Added
+		*doc.TerraformResourcesChanged += run.ChangeSummary.Changed
+		*doc.TerraformResourcesDestroyed += run.ChangeSummary.Removed
+	}
+	return doc
+}
+
+// elkFlatteningTranslator is a tenso.TranslationHandler that translates
+// payloads into an elkFlatDocument by way of their change event.
+type elkFlatteningTranslator struct {
+	sourcePayloadType string
+	targetPayloadType string
+	// ChangeEventOf builds the change event for the given payload.
+	ChangeEventOf func(payload []byte) (changeevent.Event, error)
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *elkFlatteningTranslator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
+	return nil
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *elkFlatteningTranslator) PluginTypeID() string {
+	return t.sourcePayloadType + "->" + t.targetPayloadType
+}
+
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *elkFlatteningTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := t.ChangeEventOf(payload)
+	if err != nil {
+		return nil, err
+	}
+	event.SourcePayloadType = t.sourcePayloadType
+	return json.Marshal(flattenChangeEventForELK(event))
+}
+
+func describeFlatDocumentForELK(payload []byte) (elk.Document, error) {
+	doc, err := jsonUnmarshalStrict[elkFlatDocument](payload)
+	if err != nil {
+		return elk.Document{}, err
+	}
+	result := elk.Document{Payload: payload, Team: doc.Team, Time: doc.FinishedAt}
+	if result.Time == nil {
+		result.Time = doc.StartedAt
+	}
+	return result, nil
+}
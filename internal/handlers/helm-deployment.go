@@ -4,12 +4,8 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"net"
 	"regexp"
 	"strings"
 	"time"
@@ -20,15 +16,20 @@ import (
 	"github.com/sapcc/go-bits/regexpext"
 	"go.xyrillian.de/schwift/v2"
 
+	"github.com/sapcc/tenso/internal/changeevent"
 	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/teams"
 	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/topology"
 )
 
 //nolint:dupl
 func init() {
 	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &helmDeploymentValidator{} })
-	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &helmDeploymentToElkDeliverer{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &helmDeploymentToSwiftDeliverer{} })
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &changeEventTranslator{"helm-deployment-from-concourse.v1", helmDeploymentChangeEventOf}
+	})
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &helmDeploymentToSNowTranslator{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &helmDeploymentToSNowDeliverer{} })
 }
@@ -45,6 +46,8 @@ func releaseDescriptorsOf(event deployevent.Event, sep string) (result []string)
 
 type helmDeploymentValidator struct {
 	clusterRx *regexp.Regexp
+	topology  *topology.Topology
+	teams     *teams.Registry
 }
 
 // Init implements the tenso.ValidationHandler interface.
@@ -58,7 +61,12 @@ func (h *helmDeploymentValidator) Init(context.Context, *gophercloud.ProviderCli
 	if err != nil {
 		return fmt.Errorf("while compiling %s: %w", clusterRxEnvVar, err)
 	}
-	return nil
+	h.topology, err = topology.Load("TENSO_TOPOLOGY_CONFIG_PATH")
+	if err != nil {
+		return err
+	}
+	h.teams, err = teams.Load("TENSO_TEAMS_CONFIG_PATH")
+	return err
 }
 
 // PluginTypeID implements the pluggable.Plugin interface.
@@ -68,122 +76,76 @@ func (h *helmDeploymentValidator) PluginTypeID() string {
 
 // ValidatePayload implements the tenso.ValidationHandler interface.
 func (h *helmDeploymentValidator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
-	event, err := parseAndValidateDeployEvent(payload, regionRx)
+	var errs, warnings tenso.ValidationErrors
+	event, err := parseAndValidateDeployEvent(payload, regionRx, h.teams.Current(), &errs, &warnings)
 	if err != nil {
 		return nil, err
 	}
 
 	if event.ADDeployment != nil {
-		return nil, errors.New("active-directory-deployment may not be set for Helm deployment events")
+		errs.Add("/active-directory-deployment", tenso.ValidationErrorNotAllowed, "active-directory-deployment may not be set for Helm deployment events")
 	}
 	if len(event.TerraformRuns) != 0 {
-		return nil, errors.New("terraform-runs[] may not be set for Helm deployment events")
+		errs.Add("/terraform-runs", tenso.ValidationErrorNotAllowed, "terraform-runs[] may not be set for Helm deployment events")
 	}
 	if len(event.HelmReleases) == 0 {
-		return nil, errors.New("helm-release[] may not be empty")
+		errs.Add("/helm-release", tenso.ValidationErrorMissing, "helm-release[] may not be empty")
 	}
+	topo := h.topology.Current()
 	for idx, relInfo := range event.HelmReleases {
+		field := func(name string) string { return jsonPointer("helm-release", idx, name) }
 		if relInfo == nil {
-			return nil, fmt.Errorf(`helm-release[%d] may not be nil`, idx)
+			errs.Add(jsonPointer("helm-release", idx), tenso.ValidationErrorMissing, `helm-release[%d] may not be nil`, idx)
+			continue
 		}
 		// TODO: Can we do regex matches to validate the contents of Name, Namespace, ChartID, ChartPath?
 		if relInfo.Name == "" {
-			return nil, fmt.Errorf(`invalid value for field helm-release[].name: %q`, relInfo.Name)
+			errs.Add(field("name"), tenso.ValidationErrorMissing, `invalid value for field helm-release[].name: %q`, relInfo.Name)
 		}
 		if !relInfo.Outcome.IsKnownInputValue() {
-			return nil, fmt.Errorf(`in helm-release %q: invalid value for field outcome: %q`, relInfo.Name, relInfo.Outcome)
+			errs.Add(field("outcome"), tenso.ValidationErrorInvalid, `in helm-release %q: invalid value for field outcome: %q`, relInfo.Name, relInfo.Outcome)
 		}
 		if relInfo.ChartID == "" && relInfo.ChartPath == "" {
-			return nil, fmt.Errorf(`in helm-release %q: chart-id and chart-path can not both be empty`, relInfo.Name)
+			errs.Add(field("chart-id"), tenso.ValidationErrorMissing, `in helm-release %q: chart-id and chart-path can not both be empty`, relInfo.Name)
 		}
 		if relInfo.ChartID != "" && relInfo.ChartPath != "" {
-			return nil, fmt.Errorf(`in helm-release %q: chart-id and chart-path can not both be set`, relInfo.Name)
+			errs.Add(field("chart-path"), tenso.ValidationErrorNotAllowed, `in helm-release %q: chart-id and chart-path can not both be set`, relInfo.Name)
 		}
 		if !h.clusterRx.MatchString(relInfo.Cluster) {
-			return nil, fmt.Errorf(`in helm-release %q: invalid value for field cluster: %q`, relInfo.Name, relInfo.Cluster)
-		}
-		if !isClusterLocatedInRegion(relInfo.Cluster, event.Region) {
-			return nil, fmt.Errorf(`in helm-release %q: cluster %q is not in region %q`, relInfo.Name, relInfo.Cluster, event.Region)
+			errs.Add(field("cluster"), tenso.ValidationErrorInvalid, `in helm-release %q: invalid value for field cluster: %q`, relInfo.Name, relInfo.Cluster)
+		} else if !topo.IsClusterLocatedInRegion(relInfo.Cluster, event.Region) {
+			errs.Add(field("cluster"), tenso.ValidationErrorInvalid, `in helm-release %q: cluster %q is not in region %q`, relInfo.Name, relInfo.Cluster, event.Region)
 		}
 		if relInfo.Namespace == "" {
-			return nil, fmt.Errorf(`in helm-release %q: invalid value for field namespace: %q`, relInfo.Name, relInfo.Namespace)
+			errs.Add(field("kubernetes-namespace"), tenso.ValidationErrorMissing, `in helm-release %q: invalid value for field namespace: %q`, relInfo.Name, relInfo.Namespace)
 		}
 		if relInfo.StartedAt == nil && relInfo.Outcome != deployevent.OutcomeNotDeployed {
-			return nil, fmt.Errorf(`in helm-release %q: field started-at must be set for outcome %q`, relInfo.Name, relInfo.Outcome)
+			errs.Add(field("started-at"), tenso.ValidationErrorMissing, `in helm-release %q: field started-at must be set for outcome %q`, relInfo.Name, relInfo.Outcome)
 		}
 		if relInfo.StartedAt != nil && relInfo.Outcome == deployevent.OutcomeNotDeployed {
-			return nil, fmt.Errorf(`in helm-release %q: field started-at may not be set for outcome %q`, relInfo.Name, relInfo.Outcome)
+			errs.Add(field("started-at"), tenso.ValidationErrorNotAllowed, `in helm-release %q: field started-at may not be set for outcome %q`, relInfo.Name, relInfo.Outcome)
 		}
 		if relInfo.FinishedAt == nil && (relInfo.Outcome != deployevent.OutcomeNotDeployed && relInfo.Outcome != deployevent.OutcomeHelmUpgradeFailed) {
-			return nil, fmt.Errorf(`in helm-release %q: field finished-at must be set for outcome %q`, relInfo.Name, relInfo.Outcome)
+			errs.Add(field("finished-at"), tenso.ValidationErrorMissing, `in helm-release %q: field finished-at must be set for outcome %q`, relInfo.Name, relInfo.Outcome)
 		}
 		if relInfo.FinishedAt != nil && (relInfo.Outcome == deployevent.OutcomeNotDeployed || relInfo.Outcome == deployevent.OutcomeHelmUpgradeFailed) {
-			return nil, fmt.Errorf(`in helm-release %q: field finished-at may not be set for outcome %q`, relInfo.Name, relInfo.Outcome)
+			errs.Add(field("finished-at"), tenso.ValidationErrorNotAllowed, `in helm-release %q: field finished-at may not be set for outcome %q`, relInfo.Name, relInfo.Outcome)
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
 	return &tenso.PayloadInfo{
 		Description: fmt.Sprintf("%s/%s: deploy %s",
 			event.Pipeline.TeamName, event.Pipeline.PipelineName,
 			strings.Join(releaseDescriptorsOf(event, " to "), " and "),
 		),
+		Warnings:         warnings,
+		PolicyAttributes: policyAttributesOfDeployEvent(event),
 	}, nil
 }
 
-////////////////////////////////////////////////////////////////////////////////
-// DeliveryHandler for ELK
-
-// helmDeploymentToElkDeliverer is a tenso.DeliveryHandler.
-type helmDeploymentToElkDeliverer struct {
-	LogstashHost string
-}
-
-// Init implements the tenso.DeliveryHandler interface.
-func (h *helmDeploymentToElkDeliverer) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
-	var err error
-	h.LogstashHost, err = osext.NeedGetenv("TENSO_HELM_DEPLOYMENT_LOGSTASH_HOST")
-	if err != nil {
-		return err
-	}
-	_, _, err = net.SplitHostPort(h.LogstashHost)
-	if err != nil {
-		return fmt.Errorf(`expected TENSO_HELM_DEPLOYMENT_LOGSTASH_HOST to look like "host:port", but got %q`,
-			h.LogstashHost)
-	}
-	return nil
-}
-
-// PluginTypeID implements the pluggable.Plugin interface.
-func (h *helmDeploymentToElkDeliverer) PluginTypeID() string {
-	return "helm-deployment-to-elk.v1"
-}
-
-// DeliverPayload implements the tenso.DeliveryHandler interface.
-func (h *helmDeploymentToElkDeliverer) DeliverPayload(_ context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
-	// Logstash wants everything on one line, so ensure we don't have unnecessary whitespace in the payload
-	var buf bytes.Buffer
-	err := json.Compact(&buf, payload)
-	if err != nil {
-		return nil, err
-	}
-	err = buf.WriteByte('\n')
-	if err != nil {
-		return nil, err
-	}
-	payload = buf.Bytes()
-
-	// deliver payload to Logstash
-	conn, err := net.Dial("tcp", h.LogstashHost)
-	if err != nil {
-		return nil, err
-	}
-	_, err = conn.Write(payload)
-	if err != nil {
-		return nil, err
-	}
-	return nil, conn.Close()
-}
-
 ////////////////////////////////////////////////////////////////////////////////
 // DeliveryHandler for Swift
 
@@ -215,7 +177,24 @@ func (h *helmDeploymentToSwiftDeliverer) DeliverPayload(ctx context.Context, pay
 		string(event.CombinedOutcome()),
 		event.RecordedAt.Format(time.RFC3339),
 	)
-	return nil, h.Container.Object(objectName).Upload(ctx, bytes.NewReader(payload), nil, nil)
+	return tenso.DeliverToSwift(ctx, h.Container, objectName, payload)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for change events
+
+func helmDeploymentChangeEventOf(payload []byte) (changeevent.Event, error) {
+	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	if err != nil {
+		return changeevent.Event{}, err
+	}
+
+	releaseDesc := strings.Join(releaseDescriptorsOf(event, " to "), ", ")
+	inputDesc := strings.Join(inputDescriptorsOf(event), ", ")
+	result := changeEventOfDeployEvent(changeevent.FamilyHelmDeployment, event)
+	result.Summary = "Deploy " + releaseDesc
+	result.Description = fmt.Sprintf("Deployed %s with versions: %s\nDeployment log: %s\n\nOutcome: %s", releaseDesc, inputDesc, event.Pipeline.BuildURL, string(event.CombinedOutcome()))
+	return result, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -238,24 +217,11 @@ func (h *helmDeploymentToSNowTranslator) PluginTypeID() string {
 
 // TranslatePayload implements the tenso.TranslationHandler interface.
 func (h *helmDeploymentToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
-	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
+	event, err := helmDeploymentChangeEventOf(payload)
 	if err != nil {
 		return nil, err
 	}
-
-	releaseDesc := strings.Join(releaseDescriptorsOf(event, " to "), ", ")
-	inputDesc := strings.Join(inputDescriptorsOf(event), ", ")
-	chg := servicenow.Change{
-		StartedAt:   event.CombinedStartDate(),
-		EndedAt:     event.RecordedAt,
-		Outcome:     event.CombinedOutcome(),
-		Summary:     "Deploy " + releaseDesc,
-		Description: fmt.Sprintf("Deployed %s with versions: %s\nDeployment log: %s\n\nOutcome: %s", releaseDesc, inputDesc, event.Pipeline.BuildURL, string(event.CombinedOutcome())),
-		Executee:    event.Pipeline.CreatedBy, // NOTE: can be empty
-		Region:      event.Region,
-	}
-
-	return chg.Serialize(h.Mapping, h.Mapping.HelmDeployment, routingInfo)
+	return serviceNowChangeOf(event).Serialize(h.Mapping, h.Mapping.HelmDeployment, routingInfo)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -278,5 +244,10 @@ func (h *helmDeploymentToSNowDeliverer) PluginTypeID() string {
 
 // DeliverPayload implements the tenso.DeliveryHandler interface.
 func (h *helmDeploymentToSNowDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
-	return h.Mapping.Endpoints.DeliverChangePayload(ctx, payload, routingInfo)
+	return h.Mapping.Endpoints.DeliverChangePayload(ctx, tenso.DeliveryState{}, payload, routingInfo)
+}
+
+// DeliverPayloadWithState implements the tenso.StatefulDeliveryHandler interface.
+func (h *helmDeploymentToSNowDeliverer) DeliverPayloadWithState(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return h.Mapping.Endpoints.DeliverChangePayload(ctx, state, payload, routingInfo)
 }
@@ -13,6 +13,7 @@ import (
 	"go.xyrillian.de/gg/assert"
 
 	_ "github.com/sapcc/tenso/internal/handlers"
+	"github.com/sapcc/tenso/internal/tenso"
 	"github.com/sapcc/tenso/internal/test"
 )
 
@@ -30,10 +31,16 @@ func TestHelmDeploymentValidationSuccess(t *testing.T) {
 	testCases := []struct {
 		ReleaseName         string
 		ExpectedDescription string
+		ExpectedWarnings    tenso.ValidationErrors
 	}{
 		{
 			ReleaseName:         "kube-system-metal",
 			ExpectedDescription: "services/kube-system-metal: deploy kube-system-metal to qa-de-1",
+			ExpectedWarnings: tenso.ValidationErrors{{
+				Pointer: "/pipeline/created-by",
+				Code:    tenso.ValidationErrorMissing,
+				Message: "field pipeline.created-by is missing",
+			}},
 		},
 		{
 			ReleaseName:         "swift",
@@ -45,9 +52,40 @@ func TestHelmDeploymentValidationSuccess(t *testing.T) {
 		sourcePayloadBytes := must.ReturnT(os.ReadFile(fmt.Sprintf("fixtures/helm-deployment-from-concourse.v1.%s.json", tc.ReleaseName)))(t)
 		payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
 		assert.Equal(t, payloadInfo.Description, tc.ExpectedDescription)
+		assert.Equal(t, payloadInfo.Warnings, tc.ExpectedWarnings)
 	}
 }
 
+func TestHelmDeploymentTeamValidation(t *testing.T) {
+	t.Setenv("TENSO_HELM_DEPLOYMENT_LOGSTASH_HOST", "localhost:1")
+	t.Setenv("TENSO_HELM_DEPLOYMENT_CLUSTER_REGEX", "[a-z]{2}-[a-z]{2}-[0-9]{1}")
+	t.Setenv("TENSO_TEAMS_CONFIG_PATH", "fixtures/teams-config.json")
+	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
+
+	s := test.NewSetup(t,
+		test.WithRoute("helm-deployment-from-concourse.v1 -> helm-deployment-to-elk.v1"),
+	)
+	vh := s.Config.EnabledRoutes[0].ValidationHandler
+
+	// events from known pipelines are accepted, and their attributes are exposed to the policy
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/helm-deployment-from-concourse.v1.swift.json"))(t)
+	payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
+	assert.Equal(t, payloadInfo.PolicyAttributes, map[string]string{
+		"region":   "qa-de-1",
+		"team":     "services",
+		"pipeline": "swift",
+	})
+
+	// events from unknown pipelines are rejected
+	sourcePayloadBytes = must.ReturnT(os.ReadFile("fixtures/helm-deployment-from-concourse.v1.kube-system-metal.json"))(t)
+	_, err := vh.ValidatePayload(sourcePayloadBytes, regionRx)
+	assert.Equal(t, tenso.ValidationErrorsOf(err), tenso.ValidationErrors{{
+		Pointer: "/pipeline/name",
+		Code:    tenso.ValidationErrorUnknown,
+		Message: `field pipeline.name refers to an unknown pipeline in team "services": "kube-system-metal"`,
+	}})
+}
+
 // TODO test validation errors
 
 func TestHelmDeploymentConversionToSNow(t *testing.T) {
@@ -57,9 +95,9 @@ func TestHelmDeploymentConversionToSNow(t *testing.T) {
 	s := test.NewSetup(t,
 		test.WithRoute("helm-deployment-from-concourse.v1 -> helm-deployment-to-servicenow.v1"),
 	)
-	th := s.Config.EnabledRoutes[0].TranslationHandler
+	chain := s.Config.EnabledRoutes[0].TranslationChain
 
 	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/helm-deployment-from-concourse.v1.swift.json"))(t)
-	targetPayloadBytes := must.ReturnT(th.TranslatePayload(sourcePayloadBytes, nil))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
 	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/helm-deployment-to-servicenow.v1.swift.json")
 }
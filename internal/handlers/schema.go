@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/gophercloud/gophercloud/v2"
+
+	"github.com/sapcc/tenso/internal/cloudevents"
+	"github.com/sapcc/tenso/internal/jsonschema"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+// Payload types can be declared in the config file at
+// $TENSO_PAYLOAD_TYPES_CONFIG_PATH instead of in code. Payloads of these
+// types are validated against a JSON Schema, and can be routed to
+// "cloudevent-to-webhook.v1".
+
+func init() {
+	tenso.ConfiguredValidationHandler = func(payloadType string) (tenso.ValidationHandler, error) {
+		pt, err := getDeclaredPayloadType(payloadType)
+		if pt == nil || err != nil {
+			return nil, err
+		}
+		return &schemaValidator{payloadType, pt}, nil
+	}
+	tenso.ConfiguredTranslationHandlers = append(tenso.ConfiguredTranslationHandlers, func(typeID string) (tenso.TranslationHandler, error) {
+		payloadType, ok := strings.CutSuffix(typeID, "->cloudevent-to-webhook.v1")
+		if !ok {
+			return nil, nil
+		}
+		pt, err := getDeclaredPayloadType(payloadType)
+		if pt == nil || err != nil {
+			return nil, err
+		}
+		return &cloudEventWrappingTranslator{sourcePayloadType: payloadType, Describe: pt.describeForCloudEvent}, nil
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// configuration
+
+// payloadTypesConfiguration is the structure of the config file at
+// $TENSO_PAYLOAD_TYPES_CONFIG_PATH.
+type payloadTypesConfiguration struct {
+	PayloadTypes map[string]*declaredPayloadType `json:"payload_types"`
+}
+
+// declaredPayloadType is a payload type that is declared in configuration.
+type declaredPayloadType struct {
+	// Exactly one of Schema and SchemaPath must be given.
+	Schema     json.RawMessage `json:"schema"`
+	SchemaPath string          `json:"schema_path"`
+	// Description computes tenso.PayloadInfo.Description.
+	Description payloadTemplate `json:"description"`
+	// RegionField is a JSON pointer to a field that must match $TENSO_REGION_REGEX.
+	RegionField string `json:"region_field"`
+	// CloudEvent contains templates for attributes of the CloudEvent that this
+	// payload is wrapped into for delivery to "cloudevent-to-webhook.v1".
+	CloudEvent struct {
+		Source  payloadTemplate `json:"source"`
+		Subject payloadTemplate `json:"subject"`
+	} `json:"cloudevent"`
+
+	parsedSchema *jsonschema.Schema
+}
+
+var payloadTypesConfigAtPath = map[string]payloadTypesConfiguration{}
+
+// Returns nil if there is no declaration for this payload type.
+func getDeclaredPayloadType(payloadType string) (*declaredPayloadType, error) {
+	filePath := os.Getenv("TENSO_PAYLOAD_TYPES_CONFIG_PATH")
+	if filePath == "" {
+		return nil, nil
+	}
+
+	// reuse cached result if possible
+	cfg, ok := payloadTypesConfigAtPath[filePath]
+	if !ok {
+		buf, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err = parsePayloadTypesConfiguration(buf)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing %s: %w", filePath, err)
+		}
+		payloadTypesConfigAtPath[filePath] = cfg
+	}
+	return cfg.PayloadTypes[payloadType], nil
+}
+
+func parsePayloadTypesConfiguration(buf []byte) (payloadTypesConfiguration, error) {
+	var cfg payloadTypesConfiguration
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&cfg)
+	if err != nil {
+		return payloadTypesConfiguration{}, err
+	}
+
+	for payloadType, pt := range cfg.PayloadTypes {
+		if !tenso.IsWellFormedPayloadType(payloadType) {
+			return payloadTypesConfiguration{}, fmt.Errorf("invalid payload type: %q", payloadType)
+		}
+		if tenso.ValidationHandlerRegistry.Instantiate(payloadType) != nil {
+			return payloadTypesConfiguration{}, fmt.Errorf("payload type %q is already implemented in code and cannot be declared again", payloadType)
+		}
+
+		schemaBuf := []byte(pt.Schema)
+		switch {
+		case len(pt.Schema) > 0 && pt.SchemaPath != "":
+			return payloadTypesConfiguration{}, fmt.Errorf(`in payload type %q: "schema" and "schema_path" may not both be set`, payloadType)
+		case pt.SchemaPath != "":
+			schemaBuf, err = os.ReadFile(pt.SchemaPath)
+			if err != nil {
+				return payloadTypesConfiguration{}, fmt.Errorf("in payload type %q: %w", payloadType, err)
+			}
+		case len(pt.Schema) == 0:
+			return payloadTypesConfiguration{}, fmt.Errorf(`in payload type %q: one of "schema" and "schema_path" must be set`, payloadType)
+		}
+		pt.parsedSchema, err = jsonschema.Parse(schemaBuf)
+		if err != nil {
+			return payloadTypesConfiguration{}, fmt.Errorf("in payload type %q: invalid schema: %w", payloadType, err)
+		}
+
+		if pt.Description.tpl == nil {
+			return payloadTypesConfiguration{}, fmt.Errorf(`in payload type %q: missing value for "description"`, payloadType)
+		}
+		if pt.RegionField != "" && !strings.HasPrefix(pt.RegionField, "/") {
+			return payloadTypesConfiguration{}, fmt.Errorf(`in payload type %q: value for "region_field" must be a JSON pointer, but is %q`, payloadType, pt.RegionField)
+		}
+	}
+	return cfg, nil
+}
+
+// payloadTemplate is a Go text/template that is executed on a decoded payload.
+// It appears in the configuration as a string.
+type payloadTemplate struct {
+	tpl *template.Template
+}
+
+var payloadTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (pt *payloadTemplate) UnmarshalJSON(buf []byte) error {
+	var source string
+	err := json.Unmarshal(buf, &source)
+	if err != nil {
+		return err
+	}
+	tpl, err := template.New("").Funcs(payloadTemplateFuncs).Parse(source)
+	if err != nil {
+		return err
+	}
+	*pt = payloadTemplate{tpl}
+	return nil
+}
+
+// Execute renders this template with the given payload, which must have been
+// obtained through jsonschema.Decode().
+func (pt payloadTemplate) Execute(payload any) (string, error) {
+	if pt.tpl == nil {
+		return "", nil
+	}
+	var sb strings.Builder
+	err := pt.tpl.Execute(&sb, payload)
+	return sb.String(), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// ValidationHandler
+
+type schemaValidator struct {
+	payloadType string
+	Declaration *declaredPayloadType
+}
+
+// Init implements the tenso.ValidationHandler interface.
+func (v *schemaValidator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
+	return nil // the declaration is already loaded when the handler is instantiated
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (v *schemaValidator) PluginTypeID() string {
+	return v.payloadType
+}
+
+// ValidatePayload implements the tenso.ValidationHandler interface.
+func (v *schemaValidator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
+	value, err := jsonschema.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	var errs tenso.ValidationErrors
+	for _, err := range v.Declaration.parsedSchema.Validate(value) {
+		errs.Add(err.Pointer, validationErrorCodeForSchemaKeyword(err.Keyword), err.Error())
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var policyAttrs map[string]string
+	if v.Declaration.RegionField != "" {
+		region, _ := jsonschema.Lookup(value, v.Declaration.RegionField)
+		regionStr, ok := region.(string)
+		if !ok || !regionRx.MatchString(regionStr) {
+			errs.Add(v.Declaration.RegionField, tenso.ValidationErrorInvalid, `value for field %s is invalid: %q`, v.Declaration.RegionField, regionStr)
+			return nil, errs
+		}
+		policyAttrs = map[string]string{"region": regionStr}
+	}
+
+	desc, err := v.Declaration.Description.Execute(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot render description: %w", err)
+	}
+	return &tenso.PayloadInfo{Description: desc, PolicyAttributes: policyAttrs}, nil
+}
+
+func validationErrorCodeForSchemaKeyword(keyword string) tenso.ValidationErrorCode {
+	switch keyword {
+	case "required":
+		return tenso.ValidationErrorMissing
+	case "false": // e.g. because of "additionalProperties": false
+		return tenso.ValidationErrorNotAllowed
+	default:
+		return tenso.ValidationErrorInvalid
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for CloudEvent egress
+
+func (pt *declaredPayloadType) describeForCloudEvent(payload []byte, ce *cloudevents.Event) error {
+	value, err := jsonschema.Decode(payload)
+	if err != nil {
+		return tenso.Permanent(err)
+	}
+	ce.Source, err = pt.CloudEvent.Source.Execute(value)
+	if err != nil {
+		return fmt.Errorf("cannot render CloudEvent source: %w", err)
+	}
+	ce.Subject, err = pt.CloudEvent.Subject.Execute(value)
+	if err != nil {
+		return fmt.Errorf("cannot render CloudEvent subject: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/v2"
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/cloudevents"
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/test"
+)
+
+func TestDeclaredPayloadTypeValidation(t *testing.T) {
+	t.Setenv("TENSO_PAYLOAD_TYPES_CONFIG_PATH", "fixtures/payload-types-config.json")
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+	regionRx := regexp.MustCompile("^[a-z]{2}-[a-z]{2}-[0-9]$")
+
+	s := test.NewSetup(t,
+		test.WithRoute("backup-run-from-jenkins.v1 -> cloudevent-to-webhook.v1"),
+	)
+	vh := s.Config.EnabledRoutes[0].ValidationHandler
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/backup-run-from-jenkins.v1.good.json"))(t)
+	payloadInfo := must.ReturnT(vh.ValidatePayload(sourcePayloadBytes, regionRx))(t)
+	assert.Equal(t, payloadInfo.Description, "backup-nightly: backup of keystone in qa-de-1 succeeded")
+
+	// all schema violations are reported at once
+	_, err := vh.ValidatePayload([]byte(`{"job":"","database":"Keystone","region":"qa-de-1","outcome":"unknown","finished_at":"yesterday"}`), regionRx)
+	assert.ErrEqual(t, err, "value at /build_url is required, but missing\n"+
+		`value at /database must match the pattern "^[a-z][a-z0-9-]*$", but is "Keystone"`+"\n"+
+		`value at /finished_at must be a valid date-time, but is "yesterday"`+"\n"+
+		"value at /job may not be empty\n"+
+		`value at /outcome must be one of "succeeded", "failed", but is "unknown"`,
+	)
+
+	// the region is checked against $TENSO_REGION_REGEX
+	_, err = vh.ValidatePayload([]byte(`{"job":"backup","database":"keystone","region":"qa-de","outcome":"failed","build_url":"https://example.com/"}`), regionRx)
+	assert.Equal(t, tenso.ValidationErrorsOf(err), tenso.ValidationErrors{{
+		Pointer: "/region",
+		Code:    tenso.ValidationErrorInvalid,
+		Message: `value for field /region is invalid: "qa-de"`,
+	}})
+}
+
+func TestDeclaredPayloadTypeCloudEventEgress(t *testing.T) {
+	t.Setenv("TENSO_PAYLOAD_TYPES_CONFIG_PATH", "fixtures/payload-types-config.json")
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+
+	s := test.NewSetup(t,
+		test.WithRoute("backup-run-from-jenkins.v1 -> cloudevent-to-webhook.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/backup-run-from-jenkins.v1.good.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+
+	var event cloudevents.Event
+	must.SucceedT(t, json.Unmarshal(targetPayloadBytes, &event))
+	must.SucceedT(t, event.Validate())
+	assert.Equal(t, event.Type, "backup-run-from-jenkins.v1")
+	assert.Equal(t, event.Source, "https://jenkins.example.com/job/backup-nightly/42/")
+	assert.Equal(t, event.Subject, "backup-nightly/keystone")
+	var expectedData bytes.Buffer
+	must.SucceedT(t, json.Compact(&expectedData, sourcePayloadBytes))
+	assert.Equal(t, string(event.Data), expectedData.String())
+}
+
+func TestDeclaredPayloadTypeRouting(t *testing.T) {
+	t.Setenv("TENSO_PAYLOAD_TYPES_CONFIG_PATH", "fixtures/payload-types-config.json")
+	t.Setenv("TENSO_WEBHOOK_CONFIG_PATH", "fixtures/webhook-config.json")
+
+	// declared payload types can only be routed to targets that do not need to understand them
+	_, err := tenso.BuildRoutes(t.Context(), []string{"backup-run-from-jenkins.v1 -> helm-deployment-to-webhook.v1"}, nil, gophercloud.EndpointOpts{})
+	assert.ErrEqual(t, err, `route specification "backup-run-from-jenkins.v1 -> helm-deployment-to-webhook.v1" is invalid: do not know how to translate from backup-run-from-jenkins.v1 to helm-deployment-to-webhook.v1`)
+
+	// payload types that are neither implemented nor declared are still rejected
+	_, err = tenso.BuildRoutes(t.Context(), []string{"backup-run-from-cron.v1 -> cloudevent-to-webhook.v1"}, nil, gophercloud.EndpointOpts{})
+	assert.ErrEqual(t, err, `route specification "backup-run-from-cron.v1 -> cloudevent-to-webhook.v1" is invalid: cannot validate backup-run-from-cron.v1`)
+}
@@ -4,9 +4,7 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"regexp"
 	"sort"
@@ -18,13 +16,18 @@ import (
 
 	"github.com/sapcc/go-api-declarations/deployevent"
 
+	"github.com/sapcc/tenso/internal/changeevent"
 	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/teams"
 	"github.com/sapcc/tenso/internal/tenso"
 )
 
 func init() {
 	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &terraformDeploymentValidator{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &terraformDeploymentToSwiftDeliverer{} })
+	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler {
+		return &changeEventTranslator{"terraform-deployment-from-concourse.v1", terraformDeploymentChangeEventOf}
+	})
 	tenso.TranslationHandlerRegistry.Add(func() tenso.TranslationHandler { return &terraformDeploymentToSNowTranslator{} })
 	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler { return &terraformDeploymentToSNowDeliverer{} })
 }
@@ -33,11 +36,13 @@ func init() {
 // ValidationHandler
 
 type terraformDeploymentValidator struct {
+	teams *teams.Registry
 }
 
 // Init implements the tenso.ValidationHandler interface.
-func (v *terraformDeploymentValidator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) error {
-	return nil
+func (v *terraformDeploymentValidator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	v.teams, err = teams.Load("TENSO_TEAMS_CONFIG_PATH")
+	return err
 }
 
 // PluginTypeID implements the pluggable.Plugin interface.
@@ -47,66 +52,74 @@ func (v *terraformDeploymentValidator) PluginTypeID() string {
 
 // ValidatePayload implements the tenso.ValidationHandler interface.
 func (v *terraformDeploymentValidator) ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*tenso.PayloadInfo, error) {
-	event, err := parseAndValidateDeployEvent(payload, regionRx)
+	var errs, warnings tenso.ValidationErrors
+	event, err := parseAndValidateDeployEvent(payload, regionRx, v.teams.Current(), &errs, &warnings)
 	if err != nil {
 		return nil, err
 	}
 
 	if event.ADDeployment != nil {
-		return nil, errors.New("active-directory-deployment may not be set for Terraform run events")
+		errs.Add("/active-directory-deployment", tenso.ValidationErrorNotAllowed, "active-directory-deployment may not be set for Terraform run events")
 	}
 	if len(event.HelmReleases) != 0 {
-		return nil, errors.New("helm-release[] may not be set for Terraform run events")
+		errs.Add("/helm-release", tenso.ValidationErrorNotAllowed, "helm-release[] may not be set for Terraform run events")
 	}
 	if len(event.TerraformRuns) == 0 {
-		return nil, errors.New("terraform-runs[] may not be empty")
+		errs.Add("/terraform-runs", tenso.ValidationErrorMissing, "terraform-runs[] may not be empty")
 	}
 
 	for idx, runInfo := range event.TerraformRuns {
+		field := func(name string) string { return jsonPointer("terraform-runs", idx, name) }
 		if runInfo == nil {
-			return nil, fmt.Errorf(`terraform-runs[%d] may not be nil`, idx)
+			errs.Add(jsonPointer("terraform-runs", idx), tenso.ValidationErrorMissing, `terraform-runs[%d] may not be nil`, idx)
+			continue
 		}
 		if !runInfo.Outcome.IsKnownInputValue() {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: invalid value for field outcome: %q`, idx, runInfo.Outcome)
+			errs.Add(field("outcome"), tenso.ValidationErrorInvalid, `in terraform-runs[%d]: invalid value for field outcome: %q`, idx, runInfo.Outcome)
 		}
 
 		if runInfo.StartedAt == nil && runInfo.Outcome != deployevent.OutcomeNotDeployed {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field started-at must be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("started-at"), tenso.ValidationErrorMissing, `in terraform-runs[%d]: field started-at must be set for outcome %q`, idx, runInfo.Outcome)
 		}
 		if runInfo.StartedAt != nil && runInfo.Outcome == deployevent.OutcomeNotDeployed {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field started-at may not be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("started-at"), tenso.ValidationErrorNotAllowed, `in terraform-runs[%d]: field started-at may not be set for outcome %q`, idx, runInfo.Outcome)
 		}
 		if runInfo.FinishedAt == nil && (runInfo.Outcome != deployevent.OutcomeNotDeployed && runInfo.Outcome != deployevent.OutcomeHelmUpgradeFailed) {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field finished-at must be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("finished-at"), tenso.ValidationErrorMissing, `in terraform-runs[%d]: field finished-at must be set for outcome %q`, idx, runInfo.Outcome)
 		}
 		if runInfo.FinishedAt != nil && (runInfo.Outcome == deployevent.OutcomeNotDeployed || runInfo.Outcome == deployevent.OutcomeHelmUpgradeFailed) {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field finished-at may not be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("finished-at"), tenso.ValidationErrorNotAllowed, `in terraform-runs[%d]: field finished-at may not be set for outcome %q`, idx, runInfo.Outcome)
 		}
 
 		if runInfo.TerraformVersion == "" {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field terraform-version may not be empty`, idx)
+			errs.Add(field("terraform-version"), tenso.ValidationErrorMissing, `in terraform-runs[%d]: field terraform-version may not be empty`, idx)
 		}
 
 		// Terraform will only show the change_summary if the operation completes successfully
 		// Ref: <https://github.com/hashicorp/terraform/blob/6fa5784129f706a4b459b4495394899c6cc3e041/internal/command/apply.go#L131-L138>
 		if runInfo.Outcome == deployevent.OutcomeSucceeded && runInfo.ChangeSummary == nil {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field change-summary must be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("change-summary"), tenso.ValidationErrorMissing, `in terraform-runs[%d]: field change-summary must be set for outcome %q`, idx, runInfo.Outcome)
 		}
 		if runInfo.Outcome != deployevent.OutcomeSucceeded && runInfo.ChangeSummary != nil {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field change-summary may not be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("change-summary"), tenso.ValidationErrorNotAllowed, `in terraform-runs[%d]: field change-summary may not be set for outcome %q`, idx, runInfo.Outcome)
 		}
 
 		if runInfo.Outcome == deployevent.OutcomeTerraformRunFailed && runInfo.ErrorMessage == "" {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field terraform-version must be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("error-message"), tenso.ValidationErrorMissing, `in terraform-runs[%d]: field error-message must be set for outcome %q`, idx, runInfo.Outcome)
 		}
 		if runInfo.Outcome != deployevent.OutcomeTerraformRunFailed && runInfo.ErrorMessage != "" {
-			return nil, fmt.Errorf(`in terraform-runs[%d]: field terraform-version may not be set for outcome %q`, idx, runInfo.Outcome)
+			errs.Add(field("error-message"), tenso.ValidationErrorNotAllowed, `in terraform-runs[%d]: field error-message may not be set for outcome %q`, idx, runInfo.Outcome)
 		}
 	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
 	return &tenso.PayloadInfo{
 		Description: fmt.Sprintf("%s/%s: Terraform run for %s",
 			event.Pipeline.TeamName, event.Pipeline.PipelineName, event.Pipeline.JobName),
+		Warnings:         warnings,
+		PolicyAttributes: policyAttributesOfDeployEvent(event),
 	}, nil
 }
 
@@ -141,32 +154,16 @@ func (h *terraformDeploymentToSwiftDeliverer) DeliverPayload(ctx context.Context
 		string(event.CombinedOutcome()),
 		event.RecordedAt.Format(time.RFC3339),
 	)
-	return nil, h.Container.Object(objectName).Upload(ctx, bytes.NewReader(payload), nil, nil)
+	return tenso.DeliverToSwift(ctx, h.Container, objectName, payload)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
-// TranslationHandler for SNow
-
-type terraformDeploymentToSNowTranslator struct {
-	Mapping servicenow.MappingConfiguration
-}
-
-// Init implements the tenso.TranslationHandler interface.
-func (t *terraformDeploymentToSNowTranslator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
-	t.Mapping, err = servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
-	return err
-}
+// TranslationHandler for change events
 
-// PluginTypeID implements the pluggable.Plugin interface.
-func (t *terraformDeploymentToSNowTranslator) PluginTypeID() string {
-	return "terraform-deployment-from-concourse.v1->terraform-deployment-to-servicenow.v1"
-}
-
-// TranslatePayload implements the tenso.TranslationHandler interface.
-func (t *terraformDeploymentToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+func terraformDeploymentChangeEventOf(payload []byte) (changeevent.Event, error) {
 	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
 	if err != nil {
-		return nil, err
+		return changeevent.Event{}, err
 	}
 
 	sort.SliceStable(event.TerraformRuns, func(i, j int) bool {
@@ -185,8 +182,12 @@ func (t *terraformDeploymentToSNowTranslator) TranslatePayload(payload []byte, r
 	descLines := []string{
 		fmt.Sprintf("Deployed %s for %s with versions: %s", event.Pipeline.PipelineName, event.Pipeline.JobName, inputDesc),
 	}
+	var errorMessages []string
 	for idx, run := range event.TerraformRuns {
 		descLines = append(descLines, fmt.Sprintf("Step %d: %s", idx+1, summaryOfRun(*run)))
+		if run.ErrorMessage != "" {
+			errorMessages = append(errorMessages, fmt.Sprintf("Step %d: %s", idx+1, run.ErrorMessage))
+		}
 	}
 	descLines = append(descLines,
 		"Deployment log: "+event.Pipeline.BuildURL,
@@ -194,20 +195,46 @@ func (t *terraformDeploymentToSNowTranslator) TranslatePayload(payload []byte, r
 		fmt.Sprintf("Outcome: %s", event.CombinedOutcome()),
 	)
 
-	chg := servicenow.Change{
-		StartedAt:   event.CombinedStartDate(),
-		EndedAt:     event.RecordedAt,
-		Outcome:     event.CombinedOutcome(),
-		Summary:     fmt.Sprintf("Deploy %s for %s", event.Pipeline.PipelineName, event.Pipeline.JobName),
-		Description: strings.Join(descLines, "\n"),
-		Executee:    event.Pipeline.CreatedBy, // NOTE: can be empty
-		Region:      event.Region,
-	}
+	result := changeEventOfDeployEvent(changeevent.FamilyTerraformDeployment, event)
+	result.Summary = fmt.Sprintf("Deploy %s for %s", event.Pipeline.PipelineName, event.Pipeline.JobName)
+	result.Description = strings.Join(descLines, "\n")
+	result.ErrorMessages = errorMessages
+	return result, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// TranslationHandler for SNow
+
+type terraformDeploymentToSNowTranslator struct {
+	Mapping servicenow.MappingConfiguration
+}
+
+// Init implements the tenso.TranslationHandler interface.
+func (t *terraformDeploymentToSNowTranslator) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	t.Mapping, err = servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
+	return err
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (t *terraformDeploymentToSNowTranslator) PluginTypeID() string {
+	return "terraform-deployment-from-concourse.v1->terraform-deployment-to-servicenow.v1"
+}
 
-	return chg.Serialize(t.Mapping, t.Mapping.TerraformDeployment, routingInfo)
+// TranslatePayload implements the tenso.TranslationHandler interface.
+func (t *terraformDeploymentToSNowTranslator) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	event, err := terraformDeploymentChangeEventOf(payload)
+	if err != nil {
+		return nil, err
+	}
+	return serviceNowChangeOf(event).Serialize(t.Mapping, t.Mapping.TerraformDeployment, routingInfo)
 }
 
 func summaryOfRun(run deployevent.TerraformRun) string {
+	if run.ChangeSummary == nil {
+		// change summary is only reported for successful runs
+		return fmt.Sprintf("no change summary (outcome: %s)", run.Outcome)
+	}
+
 	var parts []string
 	if run.ChangeSummary.Added > 0 {
 		parts = append(parts, fmt.Sprintf("added %d objects", run.ChangeSummary.Added))
@@ -244,5 +271,10 @@ func (d *terraformDeploymentToSNowDeliverer) PluginTypeID() string {
 
 // DeliverPayload implements the tenso.DeliveryHandler interface.
 func (d *terraformDeploymentToSNowDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
-	return d.Mapping.Endpoints.DeliverChangePayload(ctx, payload, routingInfo)
+	return d.Mapping.Endpoints.DeliverChangePayload(ctx, tenso.DeliveryState{}, payload, routingInfo)
+}
+
+// DeliverPayloadWithState implements the tenso.StatefulDeliveryHandler interface.
+func (d *terraformDeploymentToSNowDeliverer) DeliverPayloadWithState(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return d.Mapping.Endpoints.DeliverChangePayload(ctx, state, payload, routingInfo)
 }
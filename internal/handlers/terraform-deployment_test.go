@@ -4,13 +4,20 @@
 package handlers_test
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 
 	"github.com/sapcc/go-bits/must"
 	"go.xyrillian.de/gg/assert"
 
+	"github.com/sapcc/tenso/internal/tenso"
 	"github.com/sapcc/tenso/internal/test"
 )
 
@@ -36,9 +43,82 @@ func TestTerraformDeploymentConversionToSNow(t *testing.T) {
 	s := test.NewSetup(t,
 		test.WithRoute("terraform-deployment-from-concourse.v1 -> terraform-deployment-to-servicenow.v1"),
 	)
-	th := s.Config.EnabledRoutes[0].TranslationHandler
+	chain := s.Config.EnabledRoutes[0].TranslationChain
 
 	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/terraform-deployment-from-concourse.v1.terragrunt-virtual-apod.json"))(t)
-	targetPayloadBytes := must.ReturnT(th.TranslatePayload(sourcePayloadBytes, nil))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
 	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/terraform-deployment-to-servicenow.v1.terragrunt-virtual-apod.json")
 }
+
+func TestTerraformDeploymentConversionToSNowForFailedRun(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/servicenow-mapping-config.json")
+
+	s := test.NewSetup(t,
+		test.WithRoute("terraform-deployment-from-concourse.v1 -> terraform-deployment-to-servicenow.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	// the mapping config has `outcome_actions` for this outcome, so a change is created (with the error messages in the close notes)
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/terraform-deployment-from-concourse.v1.failed.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/terraform-deployment-to-servicenow.v1.failed.json")
+}
+
+func TestTerraformDeploymentConversionToELK(t *testing.T) {
+	t.Setenv("TENSO_ELK_CONFIG_PATH", "fixtures/elk-config.json")
+
+	s := test.NewSetup(t,
+		test.WithRoute("terraform-deployment-from-concourse.v1 -> terraform-deployment-to-elk.v1"),
+	)
+	chain := s.Config.EnabledRoutes[0].TranslationChain
+
+	// the document contains the totals of the change summaries of all runs
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/terraform-deployment-from-concourse.v1.failed.json"))(t)
+	targetPayloadBytes := must.ReturnT(chain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	expectTranslatedPayload(t, targetPayloadBytes, "fixtures/terraform-deployment-to-elk.v1.failed.json")
+}
+
+func TestTerraformDeploymentDeliveryToELK(t *testing.T) {
+	// collect the document IDs from the bulk requests
+	var documentIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Scan()
+		var action struct {
+			Index struct {
+				ID string `json:"_id"`
+			} `json:"index"`
+		}
+		must.SucceedT(t, json.Unmarshal(scanner.Bytes(), &action))
+		documentIDs = append(documentIDs, action.Index.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"errors":false,"items":[{"index":{"_index":"idx","_id":%q,"result":"created","status":201}}]}`, action.Index.ID)
+	}))
+	t.Cleanup(srv.Close)
+
+	configPath := filepath.Join(t.TempDir(), "elk-config.json")
+	must.SucceedT(t, os.WriteFile(configPath, fmt.Appendf(nil,
+		`{"endpoints":{"default":{"type":"bulk","url":%q,"index":"deployments-{{.Team}}"}}}`, srv.URL), 0o600))
+	t.Setenv("TENSO_ELK_CONFIG_PATH", configPath)
+
+	s := test.NewSetup(t,
+		test.WithRoute("terraform-deployment-from-concourse.v1 -> terraform-deployment-to-elk.v1"),
+	)
+	route := s.Config.EnabledRoutes[0]
+	sourcePayloadBytes := must.ReturnT(os.ReadFile("fixtures/terraform-deployment-from-concourse.v1.failed.json"))(t)
+	targetPayloadBytes := must.ReturnT(route.TranslationChain.TranslatePayload(sourcePayloadBytes, nil))(t)
+	dh, ok := route.DeliveryHandler.(tenso.StatefulDeliveryHandler)
+	assert.Equal(t, ok, true)
+
+	// the document ID identifies the delivery, not the payload, so that
+	// retries overwrite the same document even if the payload changed
+	state := tenso.DeliveryState{EventID: 42, PayloadType: route.TargetPayloadType, Region: "qa-de-1"}
+	dlog := must.ReturnT(dh.DeliverPayloadWithState(t.Context(), state, targetPayloadBytes, nil))(t)
+	assert.Equal(t, dlog.ObjectIDs["id"], "tenso:qa-de-1:42:terraform-deployment-to-elk.v1")
+	_ = must.ReturnT(dh.DeliverPayloadWithState(t.Context(), state, append(targetPayloadBytes, '\n'), nil))(t)
+	assert.Equal(t, documentIDs, []string{
+		"tenso:qa-de-1:42:terraform-deployment-to-elk.v1",
+		"tenso:qa-de-1:42:terraform-deployment-to-elk.v1",
+	})
+}
@@ -5,24 +5,34 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/sapcc/go-api-declarations/deployevent"
+
+	"github.com/sapcc/tenso/internal/changeevent"
+	"github.com/sapcc/tenso/internal/teams"
+	"github.com/sapcc/tenso/internal/tenso"
 )
 
+// jsonUnmarshalStrict decodes the payload into T while rejecting unknown fields.
+// Since a malformed payload will not become valid by retrying, decoding errors
+// are reported as tenso.PermanentError.
 func jsonUnmarshalStrict[T any](payload []byte) (T, error) {
 	var data T
 	dec := json.NewDecoder(bytes.NewReader(payload))
 	dec.DisallowUnknownFields()
 	err := dec.Decode(&data)
-	return data, err
+	return data, tenso.Permanent(err)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -35,70 +45,164 @@ var (
 	sapUserIDRx   = regexp.MustCompile(`^(?:C[0-9]{7}|[DI][0-9]{6})$`)    // e.g. "D123456" or "C1234567"
 )
 
-func isClusterLocatedInRegion(cluster, region string) bool {
-	if cluster == "k-master" {
-		return region == "eu-nl-1"
-	}
-	if strings.HasSuffix(cluster, "-ora-1") {
-		return region == "ora"
-	}
-	qaClusters := map[string]struct{}{
-		"a-qa-de-100": {},
-		"a-qa-de-200": {},
-		"g-qa-de-100": {},
-		"g-qa-de-200": {},
-		"m-qa-de-100": {},
-		"m-qa-de-200": {},
-	}
-	if _, ok := qaClusters[cluster]; ok {
-		return region == "qa-de-1"
-	}
-	return strings.HasSuffix(cluster, region)
-}
-
-func parseAndValidateDeployEvent(payload []byte, regionRx *regexp.Regexp) (deployevent.Event, error) {
+// Parses a deployevent.Event and validates the fields that are shared between
+// all payload types using this format. An error is only returned if the
+// payload cannot be parsed at all; validation errors are appended to `errs`,
+// and validation warnings are appended to `warnings`.
+func parseAndValidateDeployEvent(payload []byte, regionRx *regexp.Regexp, teamsCfg teams.Config, errs, warnings *tenso.ValidationErrors) (deployevent.Event, error) {
 	event, err := jsonUnmarshalStrict[deployevent.Event](payload)
 	if err != nil {
 		return deployevent.Event{}, err
 	}
 
 	if !regionRx.MatchString(event.Region) {
-		return event, fmt.Errorf(`value for field region is invalid: %q`, event.Region)
+		errs.Add("/region", tenso.ValidationErrorInvalid, `value for field region is invalid: %q`, event.Region)
 	}
 	if event.RecordedAt == nil {
-		return event, errors.New("value for field recorded_at is missing")
+		errs.Add("/recorded_at", tenso.ValidationErrorMissing, "value for field recorded_at is missing")
 	}
 
-	for repoName, repoInfo := range event.GitRepos {
+	for _, repoName := range slices.Sorted(maps.Keys(event.GitRepos)) {
+		repoInfo := event.GitRepos[repoName]
 		if !gitCommitRx.MatchString(repoInfo.CommitID) {
-			return event, fmt.Errorf(`value for field git[%q].commit-id is invalid: %q`, repoName, repoInfo.CommitID)
+			errs.Add(jsonPointer("git", repoName, "commit-id"), tenso.ValidationErrorInvalid,
+				`value for field git[%q].commit-id is invalid: %q`, repoName, repoInfo.CommitID)
 		}
 	}
 
-	// TODO: Can we validate values for TeamName by providing a set of valid values in env?
 	if !buildNumberRx.MatchString(event.Pipeline.BuildNumber) {
-		return event, fmt.Errorf("field pipeline.build-number is invalid: %q", event.Pipeline.BuildNumber)
+		errs.Add("/pipeline/build-number", tenso.ValidationErrorInvalid, "field pipeline.build-number is invalid: %q", event.Pipeline.BuildNumber)
 	}
 	_, err = url.Parse(event.Pipeline.BuildURL)
 	if err != nil {
-		return event, fmt.Errorf("field pipeline.build-url is invalid: %q", event.Pipeline.BuildURL)
+		errs.Add("/pipeline/build-url", tenso.ValidationErrorInvalid, "field pipeline.build-url is invalid: %q", event.Pipeline.BuildURL)
 	}
 	if event.Pipeline.JobName == "" {
-		return event, fmt.Errorf("field pipeline.job is invalid: %q", event.Pipeline.JobName)
+		errs.Add("/pipeline/job", tenso.ValidationErrorMissing, "field pipeline.job is invalid: %q", event.Pipeline.JobName)
 	}
 	if event.Pipeline.PipelineName == "" {
-		return event, fmt.Errorf("field pipeline.name is invalid: %q", event.Pipeline.PipelineName)
+		errs.Add("/pipeline/name", tenso.ValidationErrorMissing, "field pipeline.name is invalid: %q", event.Pipeline.PipelineName)
 	}
 	if event.Pipeline.TeamName == "" {
-		return event, fmt.Errorf("field pipeline.team is invalid: %q", event.Pipeline.TeamName)
+		errs.Add("/pipeline/team", tenso.ValidationErrorMissing, "field pipeline.team is invalid: %q", event.Pipeline.TeamName)
+	} else {
+		unknowns := errs
+		if teamsCfg.OnUnknown == teams.ActionWarn {
+			unknowns = warnings
+		}
+		if !teamsCfg.IsKnownTeam(event.Pipeline.TeamName) {
+			unknowns.Add("/pipeline/team", tenso.ValidationErrorUnknown, "field pipeline.team refers to an unknown team: %q", event.Pipeline.TeamName)
+		} else if event.Pipeline.PipelineName != "" && !teamsCfg.IsKnownPipeline(event.Pipeline.TeamName, event.Pipeline.PipelineName) {
+			unknowns.Add("/pipeline/name", tenso.ValidationErrorUnknown, "field pipeline.name refers to an unknown pipeline in team %q: %q", event.Pipeline.TeamName, event.Pipeline.PipelineName)
+		}
 	}
-	if event.Pipeline.CreatedBy != "" && !sapUserIDRx.MatchString(event.Pipeline.CreatedBy) {
-		return event, fmt.Errorf("field pipeline.created-by is invalid: %q", event.Pipeline.CreatedBy)
+	if event.Pipeline.CreatedBy == "" {
+		// not required because not every pipeline can find out who triggered it,
+		// but the change records in ServiceNow are much less useful without it
+		warnings.Add("/pipeline/created-by", tenso.ValidationErrorMissing, "field pipeline.created-by is missing")
+	} else if !sapUserIDRx.MatchString(event.Pipeline.CreatedBy) {
+		errs.Add("/pipeline/created-by", tenso.ValidationErrorInvalid, "field pipeline.created-by is invalid: %q", event.Pipeline.CreatedBy)
 	}
 
 	return event, nil
 }
 
+// Returns the PayloadInfo.PolicyAttributes for a deployevent.Event.
+func policyAttributesOfDeployEvent(event deployevent.Event) map[string]string {
+	attrs := map[string]string{
+		"region":   event.Region,
+		"team":     event.Pipeline.TeamName,
+		"pipeline": event.Pipeline.PipelineName,
+	}
+	if event.ADDeployment != nil {
+		attrs["landscape"] = event.ADDeployment.Landscape
+	}
+	return attrs
+}
+
+// Builds a JSON pointer (RFC 6901) from the given path elements.
+func jsonPointer(elements ...any) string {
+	var sb strings.Builder
+	for _, element := range elements {
+		token := fmt.Sprint(element)
+		token = strings.ReplaceAll(token, "~", "~0")
+		token = strings.ReplaceAll(token, "/", "~1")
+		sb.WriteString("/" + token)
+	}
+	return sb.String()
+}
+
+// Returns an identifier for the deployment described by this event. This is
+// used to correlate "deployment started" events with the respective events for
+// the finished deployment. Since a single Concourse build can perform multiple
+// deployments (e.g. of a Terraform configuration and a Helm chart, or of
+// several Helm releases in separate steps), the identifier covers the family
+// and the affected objects in addition to the build.
+func correlationIDOf(family changeevent.Family, event deployevent.Event, objects []changeevent.Object) string {
+	p := event.Pipeline
+	result := fmt.Sprintf("concourse:%s:%s/%s/%s/%s", family, p.TeamName, p.PipelineName, p.JobName, p.BuildNumber)
+	if len(objects) == 0 {
+		return result
+	}
+
+	// the list of objects can be quite long, so only a checksum is included
+	objectNames := make([]string, len(objects))
+	for idx, obj := range objects {
+		objectNames[idx] = fmt.Sprintf("%s:%s", obj.Kind, obj.Name)
+	}
+	slices.Sort(objectNames)
+	checksum := sha256.Sum256([]byte(strings.Join(objectNames, "\n")))
+	return result + ":" + hex.EncodeToString(checksum[:8])
+}
+
+// Returns the objects affected by this event, most specific first.
+func affectedObjectsOf(event deployevent.Event) (result []changeevent.Object) {
+	var clusters []changeevent.Object
+	for _, rel := range event.HelmReleases {
+		result = append(result, changeevent.Object{
+			Kind: changeevent.ObjectKindHelmRelease,
+			Name: fmt.Sprintf("%s/%s/%s", rel.Cluster, rel.Namespace, rel.Name),
+		})
+		cluster := changeevent.Object{
+			Kind: changeevent.ObjectKindCluster,
+			Name: rel.Cluster,
+		}
+		if !slices.Contains(clusters, cluster) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	result = append(result, clusters...)
+
+	if event.ADDeployment != nil {
+		result = append(result, changeevent.Object{
+			Kind: changeevent.ObjectKindHost,
+			Name: event.ADDeployment.Hostname,
+		})
+	}
+	return result
+}
+
+// Returns a changeevent.Event with all fields filled that can be taken from
+// the deployevent.Event directly. The caller needs to fill Summary and
+// Description.
+func changeEventOfDeployEvent(family changeevent.Family, event deployevent.Event) changeevent.Event {
+	affectedObjects := affectedObjectsOf(event)
+	return changeevent.Event{
+		Family:          family,
+		StartedAt:       event.CombinedStartDate(),
+		FinishedAt:      event.RecordedAt,
+		Outcome:         event.CombinedOutcome(),
+		Region:          event.Region,
+		Initiator:       event.Pipeline.CreatedBy, // NOTE: can be empty
+		CorrelationID:   correlationIDOf(family, event, affectedObjects),
+		AffectedObjects: affectedObjects,
+		Pipeline:        &event.Pipeline,
+		GitRepos:        event.GitRepos,
+		HelmReleases:    event.HelmReleases,
+		TerraformRuns:   event.TerraformRuns,
+	}
+}
+
 func inputDescriptorsOf(event deployevent.Event) (result []string) {
 	var imageVersions []string
 	for _, rel := range event.HelmReleases {
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/gophercloud/gophercloud/v2"
+
+	"github.com/sapcc/tenso/internal/cloudevents"
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/webhook"
+)
+
+// Payload families that can be delivered to webhooks. For each family, there
+// is a payload type "$FAMILY-to-webhook.v1" that contains the ingress payload
+// verbatim (see dummy.go for the respective translations).
+var webhookPayloadFamilies = []string{
+	"active-directory-deployment",
+	"change-event",
+	"helm-deployment",
+	"infra-workflow",
+	"terraform-deployment",
+}
+
+func init() {
+	for _, family := range webhookPayloadFamilies {
+		tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+			return &webhookDeliverer{pluginTypeID: family + "-to-webhook.v1", contentType: "application/json"}
+		})
+	}
+	// CloudEvents are sent in structured content mode (see cloudevents.go for the respective translations)
+	tenso.DeliveryHandlerRegistry.Add(func() tenso.DeliveryHandler {
+		return &webhookDeliverer{pluginTypeID: "cloudevent-to-webhook.v1", contentType: cloudevents.ContentType}
+	})
+}
+
+// webhookDeliverer is a tenso.DeliveryHandler that POSTs payloads to HTTP endpoints.
+type webhookDeliverer struct {
+	pluginTypeID string
+	contentType  string
+	Config       webhook.Configuration
+}
+
+// PluginTypeID implements the pluggable.Plugin interface.
+func (h *webhookDeliverer) PluginTypeID() string {
+	return h.pluginTypeID
+}
+
+// Init implements the tenso.DeliveryHandler interface.
+func (h *webhookDeliverer) Init(context.Context, *gophercloud.ProviderClient, gophercloud.EndpointOpts) (err error) {
+	h.Config, err = webhook.LoadConfiguration("TENSO_WEBHOOK_CONFIG_PATH")
+	return err
+}
+
+// DeliverPayload implements the tenso.DeliveryHandler interface.
+func (h *webhookDeliverer) DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	return h.Config.Endpoints.Deliver(ctx, h.pluginTypeID, h.contentType, payload, routingInfo)
+}
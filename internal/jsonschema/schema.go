@@ -0,0 +1,226 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package jsonschema implements validation of JSON documents against a subset
+// of JSON Schema (draft 2020-12). Only the keywords that are needed to
+// describe simple event payloads are supported. Schemas using other keywords
+// are rejected when parsing, so that schema authors do not get the false
+// impression that those keywords are enforced.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Schema is a parsed JSON Schema document or subschema. Instances are
+// obtained through Parse().
+type Schema struct {
+	// for boolean schemas ("true" or "false"), all other fields are unset
+	boolean *bool
+
+	types                []string
+	enum                 []any
+	constValue           *any
+	ref                  string
+	refTarget            *Schema
+	defs                 map[string]*Schema
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	items                *Schema
+	minItems             *int
+	maxItems             *int
+	minLength            *int
+	maxLength            *int
+	pattern              *regexp.Regexp
+	format               string
+	minimum              *json.Number
+	maximum              *json.Number
+	exclusiveMinimum     *json.Number
+	exclusiveMaximum     *json.Number
+}
+
+// The serialized form of Schema. Annotation keywords are accepted, but ignored.
+type rawSchema struct {
+	SchemaURI            string             `json:"$schema"`
+	ID                   string             `json:"$id"`
+	Comment              string             `json:"$comment"`
+	Title                string             `json:"title"`
+	Description          string             `json:"description"`
+	Examples             []any              `json:"examples"`
+	Default              any                `json:"default"`
+	Type                 json.RawMessage    `json:"type"`
+	Enum                 []any              `json:"enum"`
+	Const                json.RawMessage    `json:"const"`
+	Ref                  string             `json:"$ref"`
+	Defs                 map[string]*Schema `json:"$defs"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              *string            `json:"pattern"`
+	Format               string             `json:"format"`
+	Minimum              *json.Number       `json:"minimum"`
+	Maximum              *json.Number       `json:"maximum"`
+	ExclusiveMinimum     *json.Number       `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *json.Number       `json:"exclusiveMaximum"`
+}
+
+var knownTypes = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+var knownFormats = map[string]func(string) bool{
+	"date-time": isDateTime,
+	"uri":       isURI,
+}
+
+// Parse parses a JSON Schema document.
+func Parse(buf []byte) (*Schema, error) {
+	var s Schema
+	err := json.Unmarshal(buf, &s)
+	if err != nil {
+		return nil, err
+	}
+	err = s.resolveRefs(&s, make(map[*Schema]bool))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. References are not
+// resolved here because that requires knowledge of the document root.
+func (s *Schema) UnmarshalJSON(buf []byte) error {
+	if trimmed := string(bytes.TrimSpace(buf)); trimmed == "true" || trimmed == "false" {
+		b := trimmed == "true"
+		*s = Schema{boolean: &b}
+		return nil
+	}
+
+	var raw rawSchema
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+	err := dec.Decode(&raw)
+	if err != nil {
+		return err
+	}
+
+	*s = Schema{
+		enum:                 raw.Enum,
+		ref:                  raw.Ref,
+		defs:                 raw.Defs,
+		properties:           raw.Properties,
+		required:             raw.Required,
+		additionalProperties: raw.AdditionalProperties,
+		items:                raw.Items,
+		minItems:             raw.MinItems,
+		maxItems:             raw.MaxItems,
+		minLength:            raw.MinLength,
+		maxLength:            raw.MaxLength,
+		format:               raw.Format,
+		minimum:              raw.Minimum,
+		maximum:              raw.Maximum,
+		exclusiveMinimum:     raw.ExclusiveMinimum,
+		exclusiveMaximum:     raw.ExclusiveMaximum,
+	}
+
+	if len(raw.Type) > 0 {
+		var typeName string
+		if json.Unmarshal(raw.Type, &typeName) == nil {
+			s.types = []string{typeName}
+		} else if json.Unmarshal(raw.Type, &s.types) != nil {
+			return errors.New(`value for "type" must be a string or a list of strings`)
+		}
+		for _, typeName := range s.types {
+			if !slices.Contains(knownTypes, typeName) {
+				return fmt.Errorf(`unknown value for "type": %q`, typeName)
+			}
+		}
+	}
+	if len(raw.Const) > 0 {
+		value, err := Decode(raw.Const)
+		if err != nil {
+			return err
+		}
+		s.constValue = &value
+	}
+	if raw.Pattern != nil {
+		s.pattern, err = regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return fmt.Errorf(`invalid value for "pattern": %w`, err)
+		}
+	}
+	if s.format != "" && knownFormats[s.format] == nil {
+		return fmt.Errorf(`unsupported value for "format": %q`, s.format)
+	}
+	for _, number := range []*json.Number{s.minimum, s.maximum, s.exclusiveMinimum, s.exclusiveMaximum} {
+		if number != nil {
+			_, err := number.Float64()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Finds the targets of all "$ref" keywords in this schema and its subschemas.
+// Only references to "$defs" in the document root are supported.
+func (s *Schema) resolveRefs(root *Schema, visited map[*Schema]bool) error {
+	if s == nil || visited[s] {
+		return nil
+	}
+	visited[s] = true
+
+	if s.ref != "" {
+		name, ok := strings.CutPrefix(s.ref, "#/$defs/")
+		if !ok {
+			return fmt.Errorf(`unsupported value for "$ref": %q (only "#/$defs/..." is supported)`, s.ref)
+		}
+		s.refTarget = root.defs[unescapePointerToken(name)]
+		if s.refTarget == nil {
+			return fmt.Errorf(`unresolvable value for "$ref": %q`, s.ref)
+		}
+	}
+
+	subschemas := []*Schema{s.additionalProperties, s.items}
+	for _, sub := range s.defs {
+		subschemas = append(subschemas, sub)
+	}
+	for _, sub := range s.properties {
+		subschemas = append(subschemas, sub)
+	}
+	for _, sub := range subschemas {
+		err := sub.resolveRefs(root, visited)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode parses a JSON document into the representation that Validate()
+// expects. Numbers are decoded as json.Number to preserve their precision.
+func Decode(buf []byte) (any, error) {
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	err := dec.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after end of JSON value")
+	}
+	return value, nil
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonschema_test
+
+import (
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/jsonschema"
+)
+
+const testSchemaJSON = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Backup run",
+	"type": "object",
+	"required": ["job", "database", "outcome", "finished_at"],
+	"additionalProperties": false,
+	"properties": {
+		"job": { "type": "string", "minLength": 1 },
+		"database": { "type": "string", "pattern": "^[a-z][a-z0-9-]*$" },
+		"outcome": { "enum": ["succeeded", "failed"] },
+		"finished_at": { "type": "string", "format": "date-time" },
+		"log_url": { "type": "string", "format": "uri" },
+		"size_bytes": { "type": "integer", "minimum": 0 },
+		"ratio": { "type": "number", "exclusiveMaximum": 1 },
+		"tags": { "type": "array", "maxItems": 2, "items": { "$ref": "#/$defs/tag" } },
+		"version": { "const": 1 }
+	},
+	"$defs": {
+		"tag": { "type": "string", "maxLength": 8 }
+	}
+}`
+
+func TestValidPayload(t *testing.T) {
+	schema := must.ReturnT(jsonschema.Parse([]byte(testSchemaJSON)))(t)
+
+	payload := must.ReturnT(jsonschema.Decode([]byte(`{
+		"job": "backup-nightly",
+		"database": "keystone",
+		"outcome": "succeeded",
+		"finished_at": "2026-04-05T17:31:00Z",
+		"log_url": "https://jenkins.example.com/job/backup-nightly/42/",
+		"size_bytes": 1.0e9,
+		"ratio": 0.5,
+		"tags": ["nightly", "full"],
+		"version": 1.0
+	}`)))(t)
+	assert.Equal(t, len(schema.Validate(payload)), 0)
+}
+
+func TestInvalidPayload(t *testing.T) {
+	schema := must.ReturnT(jsonschema.Parse([]byte(testSchemaJSON)))(t)
+
+	payload := must.ReturnT(jsonschema.Decode([]byte(`{
+		"job": "",
+		"database": "Keystone",
+		"outcome": "unknown",
+		"log_url": "jenkins",
+		"size_bytes": 1.5,
+		"ratio": 1,
+		"tags": ["nightly", "incremental", 42],
+		"version": 2,
+		"extra/field": true
+	}`)))(t)
+	var messages []string
+	for _, err := range schema.Validate(payload) {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, messages, []string{
+		`value at /finished_at is required, but missing`,
+		`value at /database must match the pattern "^[a-z][a-z0-9-]*$", but is "Keystone"`,
+		`value at /extra~1field is not allowed`,
+		`value at /job may not be empty`,
+		`value at /log_url must be a valid uri, but is "jenkins"`,
+		`value at /outcome must be one of "succeeded", "failed", but is "unknown"`,
+		`value at /ratio must be less than 1, but is 1`,
+		`value at /size_bytes must be of type integer, but is of type number`,
+		`value at /tags must have at most 2 entries`,
+		`value at /tags/1 must be at most 8 characters long`,
+		`value at /tags/2 must be of type string, but is of type number`,
+		`value at /version must be 1, but is 2`,
+	})
+
+	errs := schema.Validate(must.ReturnT(jsonschema.Decode([]byte(`[]`)))(t))
+	assert.Equal(t, errs, []jsonschema.Error{{
+		Pointer: "",
+		Keyword: "type",
+		Message: "must be of type object, but is of type array",
+	}})
+}
+
+func TestLookup(t *testing.T) {
+	payload := must.ReturnT(jsonschema.Decode([]byte(`{"a/b":{"c":["x","y"]}}`)))(t)
+
+	value, ok := jsonschema.Lookup(payload, "/a~1b/c/1")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, value, any("y"))
+
+	for _, pointer := range []string{"/a~1b/c/2", "/a~1b/d", "/a/b", "a~1b"} {
+		_, ok := jsonschema.Lookup(payload, pointer)
+		assert.Equal(t, ok, false)
+	}
+}
+
+func TestSchemaParseErrors(t *testing.T) {
+	testCases := []struct {
+		Input         string
+		ExpectedError string
+	}{
+		{`{"type":"text"}`, `unknown value for "type": "text"`},
+		{`{"type":{"name":"string"}}`, `value for "type" must be a string or a list of strings`},
+		{`{"properties":{"x":{"oneOf":[]}}}`, `json: unknown field "oneOf"`},
+		{`{"pattern":"("}`, "invalid value for \"pattern\": error parsing regexp: missing closing ): `(`"},
+		{`{"format":"email"}`, `unsupported value for "format": "email"`},
+		{`{"items":{"$ref":"#/$defs/missing"}}`, `unresolvable value for "$ref": "#/$defs/missing"`},
+		{`{"$ref":"https://example.com/schema.json"}`, `unsupported value for "$ref": "https://example.com/schema.json" (only "#/$defs/..." is supported)`},
+	}
+	for _, tc := range testCases {
+		_, err := jsonschema.Parse([]byte(tc.Input))
+		assert.ErrEqual(t, err, tc.ExpectedError)
+	}
+}
@@ -0,0 +1,278 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Error describes a place in a JSON document that does not conform to a schema.
+type Error struct {
+	// Pointer is a JSON pointer (RFC 6901) to the offending value, e.g.
+	// "/releases/0/name". It is empty if the document root is affected.
+	Pointer string
+	// Keyword is the schema keyword that was violated, e.g. "required".
+	Keyword string
+	Message string
+}
+
+// Error implements the builtin/error interface.
+func (e Error) Error() string {
+	if e.Pointer == "" {
+		return "value " + e.Message
+	}
+	return fmt.Sprintf("value at %s %s", e.Pointer, e.Message)
+}
+
+// Validate checks the given value against this schema and returns all
+// violations that were found. The value must have been obtained through
+// Decode().
+func (s *Schema) Validate(value any) []Error {
+	var errs []Error
+	s.validate(value, "", &errs)
+	return errs
+}
+
+func (s *Schema) validate(value any, pointer string, errs *[]Error) {
+	report := func(keyword, msg string, args ...any) {
+		*errs = append(*errs, Error{Pointer: pointer, Keyword: keyword, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	if s.boolean != nil {
+		if !*s.boolean {
+			report("false", "is not allowed")
+		}
+		return
+	}
+	if s.refTarget != nil {
+		s.refTarget.validate(value, pointer, errs)
+	}
+
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(value, t) }) {
+		report("type", "must be of type %s, but is of type %s", strings.Join(s.types, " or "), typeOf(value))
+		return // further checks would only produce confusing follow-up errors
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(option any) bool { return isEqual(value, option) }) {
+		options := make([]string, len(s.enum))
+		for idx, option := range s.enum {
+			options[idx] = render(option)
+		}
+		report("enum", "must be one of %s, but is %s", strings.Join(options, ", "), render(value))
+	}
+	if s.constValue != nil && !isEqual(value, *s.constValue) {
+		report("const", "must be %s, but is %s", render(*s.constValue), render(value))
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.minLength != nil && length < *s.minLength {
+			if *s.minLength == 1 {
+				report("minLength", "may not be empty")
+			} else {
+				report("minLength", "must be at least %d characters long", *s.minLength)
+			}
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report("maxLength", "must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			report("pattern", "must match the pattern %q, but is %q", s.pattern.String(), value)
+		}
+		if s.format != "" && !knownFormats[s.format](value) {
+			report("format", "must be a valid %s, but is %q", s.format, value)
+		}
+
+	case json.Number:
+		number := mustFloat64(value)
+		if s.minimum != nil && number < mustFloat64(*s.minimum) {
+			report("minimum", "must be at least %s, but is %s", *s.minimum, value)
+		}
+		if s.maximum != nil && number > mustFloat64(*s.maximum) {
+			report("maximum", "must be at most %s, but is %s", *s.maximum, value)
+		}
+		if s.exclusiveMinimum != nil && number <= mustFloat64(*s.exclusiveMinimum) {
+			report("exclusiveMinimum", "must be greater than %s, but is %s", *s.exclusiveMinimum, value)
+		}
+		if s.exclusiveMaximum != nil && number >= mustFloat64(*s.exclusiveMaximum) {
+			report("exclusiveMaximum", "must be less than %s, but is %s", *s.exclusiveMaximum, value)
+		}
+
+	case []any:
+		if s.minItems != nil && len(value) < *s.minItems {
+			if *s.minItems == 1 {
+				report("minItems", "may not be empty")
+			} else {
+				report("minItems", "must have at least %d entries", *s.minItems)
+			}
+		}
+		if s.maxItems != nil && len(value) > *s.maxItems {
+			report("maxItems", "must have at most %d entries", *s.maxItems)
+		}
+		if s.items != nil {
+			for idx, item := range value {
+				s.items.validate(item, pointer+"/"+strconv.Itoa(idx), errs)
+			}
+		}
+
+	case map[string]any:
+		for _, key := range s.required {
+			if _, exists := value[key]; !exists {
+				*errs = append(*errs, Error{
+					Pointer: pointer + "/" + escapePointerToken(key),
+					Keyword: "required",
+					Message: "is required, but missing",
+				})
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			subschema := s.properties[key]
+			if subschema == nil {
+				subschema = s.additionalProperties
+			}
+			if subschema != nil {
+				subschema.validate(value[key], pointer+"/"+escapePointerToken(key), errs)
+			}
+		}
+	}
+}
+
+// Returns the JSON Schema type name for a value obtained through Decode().
+// For numbers, "number" is returned even if the value is an integer.
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		panic(fmt.Sprintf("unexpected type in decoded JSON: %T", value))
+	}
+}
+
+func hasType(value any, typeName string) bool {
+	if typeName == "integer" {
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f := mustFloat64(number)
+		return f == math.Trunc(f) && !math.IsInf(f, 0)
+	}
+	return typeOf(value) == typeName
+}
+
+func isEqual(lhs, rhs any) bool {
+	switch lhs := lhs.(type) {
+	case json.Number:
+		rhs, ok := rhs.(json.Number)
+		return ok && mustFloat64(lhs) == mustFloat64(rhs)
+	case []any:
+		rhs, ok := rhs.([]any)
+		return ok && slices.EqualFunc(lhs, rhs, isEqual)
+	case map[string]any:
+		rhs, ok := rhs.(map[string]any)
+		if !ok || len(lhs) != len(rhs) {
+			return false
+		}
+		for key, lhsValue := range lhs {
+			rhsValue, exists := rhs[key]
+			if !exists || !isEqual(lhsValue, rhsValue) {
+				return false
+			}
+		}
+		return true
+	default:
+		return lhs == rhs
+	}
+}
+
+// Renders a value for use in an error message.
+func render(value any) string {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(buf)
+}
+
+// All json.Number values in this package are either validated during Parse()
+// or produced by the JSON decoder, so they are guaranteed to be well-formed.
+func mustFloat64(number json.Number) float64 {
+	f, err := number.Float64()
+	if err != nil {
+		panic(err.Error())
+	}
+	return f
+}
+
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func unescapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// Lookup returns the value at the given JSON pointer within a value obtained
+// through Decode(), or false if there is no value at that location.
+func Lookup(value any, pointer string) (any, bool) {
+	if pointer == "" {
+		return value, true
+	}
+	rest, ok := strings.CutPrefix(pointer, "/")
+	if !ok {
+		return nil, false
+	}
+	for token := range strings.SplitSeq(rest, "/") {
+		token = unescapePointerToken(token)
+		switch current := value.(type) {
+		case map[string]any:
+			value, ok = current[token]
+			if !ok {
+				return nil, false
+			}
+		case []any:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(current) {
+				return nil, false
+			}
+			value = current[idx]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func isDateTime(value string) bool {
+	_, err := time.Parse(time.RFC3339, value)
+	return err == nil
+}
+
+func isURI(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != ""
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package reloadable provides JSON config files that are reloaded when they
+// change on disk, so that their contents can be updated without restarting
+// Tenso.
+package reloadable
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sapcc/go-bits/logg"
+)
+
+// CheckInterval is the minimum time between two checks of whether a config
+// file has changed on disk. Changing this value only affects files that are
+// loaded afterwards.
+var CheckInterval = 10 * time.Second
+
+// Loader describes how a particular kind of config file is loaded.
+type Loader[T any] struct {
+	// Description is used in log messages, e.g. "topology".
+	Description string
+	// Default is used if no config file is given.
+	Default T
+	// Initial is the value that the file contents are decoded into, e.g. to
+	// provide defaults for optional fields.
+	Initial T
+	// Validate checks the decoded file contents.
+	Validate func(T) error
+}
+
+// File provides the current contents of a config file.
+type File[T any] struct {
+	loader        Loader[T]
+	path          string // empty if loader.Default is used
+	checkInterval time.Duration
+	mutex         sync.Mutex
+	value         T
+	modTime       time.Time
+	checkedAt     time.Time
+}
+
+var (
+	fileAtPath      = map[string]any{}
+	fileAtPathMutex sync.Mutex
+)
+
+// Load loads the config file specified in the given environment variable. If
+// the environment variable is not set, the Default is used.
+//
+// Files are cached by path, so that all users of the same file share the same
+// File instance.
+func (l Loader[T]) Load(envVarName string) (*File[T], error) {
+	filePath := os.Getenv(envVarName)
+	if filePath == "" {
+		return &File[T]{loader: l, value: l.Default}, nil
+	}
+
+	// reuse cached result if possible
+	fileAtPathMutex.Lock()
+	defer fileAtPathMutex.Unlock()
+	if cached, ok := fileAtPath[filePath]; ok {
+		f, ok := cached.(*File[T])
+		if !ok {
+			return nil, fmt.Errorf("cannot load %s as %s: file is already in use for a different purpose", filePath, l.Description)
+		}
+		return f, nil
+	}
+
+	f := &File[T]{loader: l, path: filePath, checkInterval: CheckInterval}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	err := f.reload()
+	if err != nil {
+		return nil, err
+	}
+	fileAtPath[filePath] = f
+	return f, nil
+}
+
+// Current returns the current contents of the config file. If the file has
+// changed, it is reloaded first. If the changed file is invalid, the previous
+// contents are kept.
+func (f *File[T]) Current() T {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.path != "" && time.Since(f.checkedAt) >= f.checkInterval {
+		err := f.reload()
+		if err != nil {
+			logg.Error("could not reload %s from %s: %s", f.loader.Description, f.path, err.Error())
+		}
+	}
+	return f.value
+}
+
+// Reloads the config file if it has changed since it was last loaded.
+// The caller must hold f.mutex.
+func (f *File[T]) reload() error {
+	f.checkedAt = time.Now()
+	fileInfo, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if !f.modTime.IsZero() && fileInfo.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	buf, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	value := f.loader.Initial
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&value)
+	if err != nil {
+		return fmt.Errorf("while parsing %s: %w", f.path, err)
+	}
+	err = f.loader.Validate(value)
+	if err != nil {
+		return fmt.Errorf("while parsing %s: %w", f.path, err)
+	}
+
+	if !f.modTime.IsZero() {
+		logg.Info("reloaded %s from %s", f.loader.Description, f.path)
+	}
+	f.value = value
+	f.modTime = fileInfo.ModTime()
+	return nil
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package reloadable_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/reloadable"
+)
+
+type testConfig struct {
+	Value int  `json:"value"`
+	Flag  bool `json:"flag"`
+}
+
+var testLoader = reloadable.Loader[testConfig]{
+	Description: "test config",
+	Default:     testConfig{Value: 1},
+	Initial:     testConfig{Flag: true},
+	Validate: func(cfg testConfig) error {
+		if cfg.Value < 0 {
+			return errors.New("value may not be negative")
+		}
+		return nil
+	},
+}
+
+func writeConfig(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	must.SucceedT(t, os.WriteFile(path, []byte(content), 0o600))
+	must.SucceedT(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestLoad(t *testing.T) {
+	// without a file, the default is used
+	t.Setenv("TEST_CONFIG_PATH", "")
+	file := must.ReturnT(testLoader.Load("TEST_CONFIG_PATH"))(t)
+	assert.Equal(t, file.Current(), testConfig{Value: 1})
+
+	// file contents are decoded on top of the initial value
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"value":42}`, time.Now())
+	t.Setenv("TEST_CONFIG_PATH", path)
+	file = must.ReturnT(testLoader.Load("TEST_CONFIG_PATH"))(t)
+	assert.Equal(t, file.Current(), testConfig{Value: 42, Flag: true})
+
+	// loading the same file again yields the same instance
+	assert.Equal(t, must.ReturnT(testLoader.Load("TEST_CONFIG_PATH"))(t), file)
+
+	// ...unless it is loaded into a different type
+	otherLoader := reloadable.Loader[map[string]int]{
+		Description: "other config",
+		Validate:    func(map[string]int) error { return nil },
+	}
+	_, err := otherLoader.Load("TEST_CONFIG_PATH")
+	assert.ErrEqual(t, err, "cannot load "+path+" as other config: file is already in use for a different purpose")
+
+	// invalid files are rejected
+	for content, expectedError := range map[string]string{
+		`{"value":-1}`:     "value may not be negative",
+		`{"unknown":true}`: `json: unknown field "unknown"`,
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeConfig(t, path, content, time.Now())
+		t.Setenv("TEST_CONFIG_PATH", path)
+		_, err := testLoader.Load("TEST_CONFIG_PATH")
+		assert.ErrEqual(t, err, "while parsing "+path+": "+expectedError)
+	}
+}
+
+func TestReloadIsRateLimited(t *testing.T) {
+	defer func(interval time.Duration) { reloadable.CheckInterval = interval }(reloadable.CheckInterval)
+	now := time.Now().Truncate(time.Second)
+
+	// with a long check interval, changes are not picked up right away
+	reloadable.CheckInterval = time.Hour
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"value":23}`, now.Add(-time.Hour))
+	t.Setenv("TEST_CONFIG_PATH", path)
+	file := must.ReturnT(testLoader.Load("TEST_CONFIG_PATH"))(t)
+	writeConfig(t, path, `{"value":42}`, now)
+	assert.Equal(t, file.Current().Value, 23)
+
+	// without a check interval, every call checks for changes
+	reloadable.CheckInterval = 0
+	path = filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, `{"value":23}`, now.Add(-time.Hour))
+	t.Setenv("TEST_CONFIG_PATH", path)
+	file = must.ReturnT(testLoader.Load("TEST_CONFIG_PATH"))(t)
+	writeConfig(t, path, `{"value":42}`, now)
+	assert.Equal(t, file.Current().Value, 42)
+
+	// when the changed file is invalid, the previous contents are kept
+	writeConfig(t, path, `{"value":-1}`, now.Add(time.Hour))
+	assert.Equal(t, file.Current().Value, 42)
+}
@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+// authenticator adds credentials to requests going to ServiceNow.
+type authenticator interface {
+	// Authorize is called with every request before it is sent.
+	Authorize(ctx context.Context, httpClient *http.Client, req *http.Request) error
+	// Invalidate is called when ServiceNow rejects the credentials that were
+	// added by Authorize, so that they can be refreshed for the next attempt.
+	Invalidate()
+}
+
+// Builds the authenticator for the credentials that are configured in this
+// Client. Client certificates are not handled here since they are part of the
+// TLS config instead.
+func (c *Client) newAuthenticator() (authenticator, error) {
+	hasClientCert := c.ClientCertPath != "" || c.PrivateKeyPath != ""
+	hasBasicAuth := c.Username != "" || c.PasswordPath != ""
+	hasOAuth := c.OAuthTokenURL != "" || c.OAuthClientID != "" || c.OAuthClientSecretPath != ""
+
+	methodCount := 0
+	for _, has := range []bool{hasClientCert, hasBasicAuth, hasOAuth} {
+		if has {
+			methodCount++
+		}
+	}
+	switch {
+	case methodCount == 0:
+		return nil, errors.New(`no authentication method configured (need either "client_cert", "username" or "oauth_token_url")`)
+	case methodCount > 1:
+		return nil, errors.New(`only one authentication method may be configured (either "client_cert", "username" or "oauth_token_url")`)
+	}
+
+	switch {
+	case hasClientCert:
+		switch {
+		case c.ClientCertPath == "":
+			return nil, errors.New(`missing "client_cert" attribute`)
+		case c.PrivateKeyPath == "":
+			return nil, errors.New(`missing "private_key" attribute`)
+		}
+		return noAuth{}, nil
+
+	case hasBasicAuth:
+		switch {
+		case c.Username == "":
+			return nil, errors.New(`missing "username" attribute`)
+		case c.PasswordPath == "":
+			return nil, errors.New(`missing "password_path" attribute`)
+		}
+		password, err := tenso.ReadSecretFile(c.PasswordPath)
+		if err != nil {
+			return nil, err
+		}
+		return basicAuth{c.Username, password}, nil
+
+	default:
+		switch {
+		case c.OAuthTokenURL == "":
+			return nil, errors.New(`missing "oauth_token_url" attribute`)
+		case c.OAuthClientID == "":
+			return nil, errors.New(`missing "oauth_client_id" attribute`)
+		case c.OAuthClientSecretPath == "":
+			return nil, errors.New(`missing "oauth_client_secret_path" attribute`)
+		}
+		clientSecret, err := tenso.ReadSecretFile(c.OAuthClientSecretPath)
+		if err != nil {
+			return nil, err
+		}
+		return &oauthClientCredentials{
+			TokenURL:     c.OAuthTokenURL,
+			ClientID:     c.OAuthClientID,
+			ClientSecret: clientSecret,
+		}, nil
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// client certificates
+
+// noAuth is used with client certificates, which are handled in the TLS config.
+type noAuth struct{}
+
+// Authorize implements the authenticator interface.
+func (noAuth) Authorize(context.Context, *http.Client, *http.Request) error { return nil }
+
+// Invalidate implements the authenticator interface.
+func (noAuth) Invalidate() {}
+
+////////////////////////////////////////////////////////////////////////////////
+// basic auth
+
+type basicAuth struct {
+	Username string
+	Password string
+}
+
+// Authorize implements the authenticator interface.
+func (a basicAuth) Authorize(_ context.Context, _ *http.Client, req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// Invalidate implements the authenticator interface.
+func (basicAuth) Invalidate() {}
+
+////////////////////////////////////////////////////////////////////////////////
+// OAuth2 client credentials
+
+type oauthClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+
+	mutex     sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// Tokens are refreshed a bit before they actually expire, so that they do not
+// expire while a request is in flight.
+const oauthTokenExpiryMargin = 1 * time.Minute
+
+// Authorize implements the authenticator interface.
+func (a *oauthClientCredentials) Authorize(ctx context.Context, httpClient *http.Client, req *http.Request) error {
+	token, err := a.getToken(ctx, httpClient)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate implements the authenticator interface.
+func (a *oauthClientCredentials) Invalidate() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.token = ""
+}
+
+func (a *oauthClientCredentials) getToken(ctx context.Context, httpClient *http.Client) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// reuse cached token if possible
+	if a.token != "" && time.Now().Before(a.expiresAt.Add(-oauthTokenExpiryMargin)) {
+		return a.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.ClientID},
+		"client_secret": {a.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("while preparing token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("while requesting OAuth token: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("while reading OAuth token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// NOTE: This is not a permanent error even for 4xx, since credentials
+		// may be rotated while the delivery is pending.
+		return "", fmt.Errorf("OAuth token request failed with status %d and response: %q", resp.StatusCode, string(respBody))
+	}
+
+	var data struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.Unmarshal(respBody, &data)
+	if err != nil {
+		return "", fmt.Errorf("while parsing OAuth token response: %w", err)
+	}
+	if data.AccessToken == "" {
+		return "", errors.New("OAuth token response did not contain an access token")
+	}
+
+	a.token = data.AccessToken
+	a.expiresAt = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	return a.token, nil
+}
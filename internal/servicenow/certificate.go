@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapcc/go-bits/logg"
+)
+
+var clientCertExpiryGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tenso_servicenow_client_cert_expiry_timestamp_seconds",
+		Help: "UNIX timestamp at which the client certificate for a ServiceNow endpoint expires.",
+	},
+	[]string{"endpoint"},
+)
+
+func init() {
+	prometheus.MustRegister(clientCertExpiryGauge)
+}
+
+var (
+	// all certificateLoaders that were created, for CheckClientCertificates()
+	certLoaders      []*certificateLoader
+	certLoadersMutex sync.Mutex
+)
+
+// certificateLoader provides the client certificate for a ServiceNow endpoint.
+// The certificate and key are reloaded when the files change on disk (e.g.
+// when cert-manager rotates them), so that the new certificate is used without
+// restarting Tenso.
+type certificateLoader struct {
+	EndpointName string
+	CertPath     string
+	KeyPath      string
+
+	mutex       sync.Mutex
+	cert        *tls.Certificate
+	expiresAt   time.Time
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+func newCertificateLoader(endpointName, certPath, keyPath string) (*certificateLoader, error) {
+	l := &certificateLoader{
+		EndpointName: endpointName,
+		CertPath:     certPath,
+		KeyPath:      keyPath,
+	}
+	err := l.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	certLoadersMutex.Lock()
+	defer certLoadersMutex.Unlock()
+	certLoaders = append(certLoaders, l)
+	return l, nil
+}
+
+// GetClientCertificate is used as a callback in tls.Config.
+func (l *certificateLoader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	err := l.refresh()
+	if err != nil {
+		// keep using the previous certificate; if the files are being replaced
+		// right now, we will pick up the new certificate on the next attempt
+		logg.Error("could not reload client certificate for ServiceNow endpoint %q: %s", l.EndpointName, err.Error())
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.cert, nil
+}
+
+// Reloads the certificate and key if the files have changed since they were last loaded.
+func (l *certificateLoader) refresh() error {
+	certInfo, err := os.Stat(l.CertPath)
+	if err != nil {
+		return fmt.Errorf("cannot load client certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(l.KeyPath)
+	if err != nil {
+		return fmt.Errorf("cannot load client certificate: %w", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.cert != nil && certInfo.ModTime().Equal(l.certModTime) && keyInfo.ModTime().Equal(l.keyModTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.CertPath, l.KeyPath)
+	if err != nil {
+		return fmt.Errorf("cannot load client certificate: %w", err)
+	}
+	if l.cert != nil {
+		logg.Info("reloaded client certificate for ServiceNow endpoint %q", l.EndpointName)
+	}
+	l.cert = &cert
+	l.expiresAt = cert.Leaf.NotAfter
+	l.certModTime = certInfo.ModTime()
+	l.keyModTime = keyInfo.ModTime()
+	clientCertExpiryGauge.WithLabelValues(l.EndpointName).Set(float64(l.expiresAt.Unix()))
+	return nil
+}
+
+// CheckClientCertificates returns an error if the client certificate for any
+// ServiceNow endpoint has expired. Certificates that have been replaced on disk
+// are reloaded before the check.
+//
+// This is intended to be used as part of a health check.
+func CheckClientCertificates(now time.Time) error {
+	certLoadersMutex.Lock()
+	loaders := certLoaders
+	certLoadersMutex.Unlock()
+
+	var errs []error
+	for _, l := range loaders {
+		err := l.refresh()
+		if err != nil {
+			logg.Error("could not reload client certificate for ServiceNow endpoint %q: %s", l.EndpointName, err.Error())
+		}
+
+		l.mutex.Lock()
+		expiresAt := l.expiresAt
+		l.mutex.Unlock()
+		if !now.Before(expiresAt) {
+			errs = append(errs, fmt.Errorf("client certificate for ServiceNow endpoint %q has expired at %s",
+				l.EndpointName, expiresAt.UTC().Format(time.RFC3339)))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+)
+
+// Writes a self-signed client certificate expiring at the given time, and its
+// private key, into the given paths. The files' modification time is set to
+// `modTime` to simulate a rotation without having to wait for the filesystem
+// clock to advance.
+func writeClientCert(t *testing.T, certPath, keyPath string, notAfter, modTime time.Time) {
+	t.Helper()
+	key := must.ReturnT(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))(t)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(notAfter.Unix()),
+		Subject:      pkix.Name{CommonName: "tenso"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER := must.ReturnT(x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key))(t)
+	keyDER := must.ReturnT(x509.MarshalECPrivateKey(key))(t)
+
+	must.SucceedT(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o600))
+	must.SucceedT(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	must.SucceedT(t, os.Chtimes(certPath, modTime, modTime))
+	must.SucceedT(t, os.Chtimes(keyPath, modTime, modTime))
+}
+
+func getCertExpiryMetric(t *testing.T, endpointName string) float64 {
+	t.Helper()
+	families := must.ReturnT(prometheus.DefaultGatherer.Gather())(t)
+	for _, family := range families {
+		if family.GetName() != "tenso_servicenow_client_cert_expiry_timestamp_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "endpoint" && label.GetValue() == endpointName {
+					return metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	t.Fatalf("no cert expiry metric found for endpoint %q", endpointName)
+	return 0
+}
+
+func TestClientCertificateRotation(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	passwordPath := filepath.Join(dir, "password")
+	must.SucceedT(t, os.WriteFile(passwordPath, []byte("swordfish"), 0o600))
+
+	// start with a certificate that is about to expire
+	now := time.Now().Truncate(time.Second)
+	firstExpiry := now.Add(time.Hour)
+	writeClientCert(t, certPath, keyPath, firstExpiry, now.Add(-time.Hour))
+
+	var cs servicenow.ClientSet
+	must.SucceedT(t, json.Unmarshal([]byte(`{
+		"default": {"url": "https://servicenow.example.com", "username": "tenso", "password_path": "`+passwordPath+`"},
+		"rotating": {"url": "https://servicenow.example.com", "client_cert": "`+certPath+`", "private_key": "`+keyPath+`"}
+	}`), &cs))
+	must.SucceedT(t, cs.Init())
+	assert.Equal(t, getCertExpiryMetric(t, "rotating"), float64(firstExpiry.Unix()))
+
+	// health check is fine until the certificate expires
+	assert.ErrEqual(t, servicenow.CheckClientCertificates(now), nil)
+	assert.ErrEqual(t, servicenow.CheckClientCertificates(firstExpiry),
+		`client certificate for ServiceNow endpoint "rotating" has expired at `+firstExpiry.UTC().Format(time.RFC3339))
+
+	// when the certificate is rotated on disk, the new certificate is picked up without reinitializing the client
+	secondExpiry := now.Add(90 * 24 * time.Hour)
+	writeClientCert(t, certPath, keyPath, secondExpiry, now)
+	assert.ErrEqual(t, servicenow.CheckClientCertificates(firstExpiry), nil)
+	assert.Equal(t, getCertExpiryMetric(t, "rotating"), float64(secondExpiry.Unix()))
+
+	// if the files are broken (e.g. because they are being replaced right now), the previous certificate is kept
+	must.SucceedT(t, os.WriteFile(keyPath, []byte("garbage"), 0o600))
+	assert.ErrEqual(t, servicenow.CheckClientCertificates(firstExpiry), nil)
+	assert.Equal(t, getCertExpiryMetric(t, "rotating"), float64(secondExpiry.Unix()))
+}
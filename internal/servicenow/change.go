@@ -7,92 +7,182 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/sapcc/go-api-declarations/deployevent"
+
+	"github.com/sapcc/tenso/internal/tenso"
 )
 
 // Change describes the data that we can pass into a ServiceNow change object.
 type Change struct {
+	// StartedAt must always be set. EndedAt is nil for deployments that have
+	// only started. In this case, an in-progress change will be opened that will
+	// be closed once the deployment finishes.
 	StartedAt         *time.Time
 	EndedAt           *time.Time
-	Outcome           deployevent.Outcome
+	Outcome           deployevent.Outcome // empty if EndedAt is nil
 	Summary           string
 	Description       string
 	ConfigurationItem string
+	// ConfigurationItemRefs (optional) list the objects affected by this change,
+	// most specific first. The first of them that can be resolved into a
+	// configuration item during delivery is used instead of ConfigurationItem.
+	ConfigurationItemRefs []ConfigurationItemRef
+	// ErrorMessages (optional) explain why the deployment did not succeed.
+	// They are added to the close notes.
+	ErrorMessages []string
 
 	// Executee (optional) is the user ID of the user who triggered/executed this change.
 	Executee string
 	// Region or AvailabilityZone describes the system that was targeted. Exactly one needs to be set.
 	Region           string
 	AvailabilityZone string
+	// CorrelationID (optional) identifies the deployment across its "started"
+	// and "finished" events. If empty, no change can be opened before the
+	// deployment finishes.
+	CorrelationID string
+
+	// These attributes (all optional) are not sent to ServiceNow directly, but
+	// mapping rules can match on them.
+	Pipeline        *deployevent.Pipeline
+	HelmReleases    []*deployevent.HelmRelease
+	GitRepos        map[string]deployevent.GitRepo
+	AWXWorkflowName string
 }
 
-// Serialize returns the payload that we can send into SNow.
-func (chg Change) Serialize(cfg MappingConfiguration, ruleset MappingRuleset, routingInfo map[string]string) ([]byte, error) {
-	// we will not create a change object in ServiceNow if:
-	//- we did not start deploying (OutcomeNotDeployed)
-	//- the deployment did not finish (e.g. OutcomeHelmUpgradeFailed) -- as
-	//  requested by our change coordinator, because the state of the Helm
-	//  deployment is not clear at this point
-	if chg.Outcome != deployevent.OutcomeSucceeded {
-		return []byte("skip"), nil
-	}
+// Location describes where a Change takes place, as resolved from the
+// Region or AvailabilityZone of the Change using the mapping configuration.
+type Location struct {
+	// Region is empty if the Change has an AvailabilityZone that does not
+	// belong to any configured region.
+	Region            string
+	AvailabilityZones []string
+	Datacenters       []string
+	Environment       string
+}
 
-	// find AZs for this change
-	if chg.Region == "" && chg.AvailabilityZone == "" {
-		return nil, errors.New("cannot serialize a servicenow.Change without a value for either Region or AvailabilityZone")
-	}
-	var azNames []string
+// ResolveLocation finds the Location of this Change in the given mapping configuration.
+func (chg Change) ResolveLocation(cfg MappingConfiguration) (Location, error) {
+	var loc Location
 	switch {
 	case chg.AvailabilityZone != "":
-		azNames = []string{chg.AvailabilityZone}
+		loc.AvailabilityZones = []string{chg.AvailabilityZone}
+		loc.Region, _ = cfg.regionOfAvailabilityZone(chg.AvailabilityZone)
 	case chg.Region != "":
 		var ok bool
-		azNames, ok = cfg.Regions[chg.Region]
+		loc.Region = chg.Region
+		loc.AvailabilityZones, ok = cfg.availabilityZonesOf(chg.Region)
 		if !ok {
-			return nil, fmt.Errorf("region not found in mapping config: %q", chg.Region)
+			return Location{}, fmt.Errorf("region not found in mapping config or topology: %q", chg.Region)
 		}
+	default:
+		return Location{}, errors.New("cannot serialize a servicenow.Change without a value for either Region or AvailabilityZone")
 	}
 
-	// find datacenters and environment for this change from AZ mapping config
-	var (
-		datacenters []string
-		environment string
-	)
-	for _, azName := range azNames {
+	// find datacenters and environment from AZ mapping config
+	for _, azName := range loc.AvailabilityZones {
 		azMapping, ok := cfg.AvailabilityZones[azName]
 		if !ok {
-			return nil, fmt.Errorf("availability zone not found in mapping config: %q", azName)
+			return Location{}, fmt.Errorf("availability zone not found in mapping config: %q", azName)
 		}
-		datacenters = append(datacenters, azMapping.Datacenters...)
-		if environment == "" {
-			environment = azMapping.Environment
-		} else if environment != azMapping.Environment {
-			return nil, fmt.Errorf(`found inconsistent values of field "environment" across AZs of region %q`, chg.Region)
+		loc.Datacenters = append(loc.Datacenters, azMapping.Datacenters...)
+		if loc.Environment == "" {
+			loc.Environment = azMapping.Environment
+		} else if loc.Environment != azMapping.Environment {
+			return Location{}, fmt.Errorf(`found inconsistent values of field "environment" across AZs of region %q`, chg.Region)
+		}
+	}
+	return loc, nil
+}
+
+// ChangeAction is an enumeration of the things that the delivery of a
+// ChangePayload can do in ServiceNow.
+type ChangeAction string
+
+const (
+	// ChangeActionOpen creates an in-progress change and remembers it for a
+	// later ChangeActionClose with the same correlation ID.
+	ChangeActionOpen ChangeAction = "open"
+	// ChangeActionClose closes the change that was opened for the same
+	// correlation ID. If there is no such change, a closed change is created
+	// instead if CreateIfMissing is set.
+	ChangeActionClose ChangeAction = "close"
+)
+
+// ChangePayload is the payload format of all "*-to-servicenow.v1" payload
+// types. It is generated by Change.Serialize().
+type ChangePayload struct {
+	Action        ChangeAction `json:"action"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	// only for ChangeActionClose
+	Outcome         deployevent.Outcome `json:"outcome,omitempty"`
+	CreateIfMissing bool                `json:"create_if_missing,omitempty"`
+	// Record contains the fields of the change object in ServiceNow.
+	Record map[string]any `json:"record"`
+	// Incident (only for ChangeActionClose) contains the fields of an incident
+	// object in ServiceNow that shall be created in addition to closing the
+	// change (if any).
+	Incident map[string]any `json:"incident,omitempty"`
+	// ConfigurationItemRefs are resolved into the "cmdb_ci" field of the record
+	// and incident during delivery (see Change.ConfigurationItemRefs).
+	ConfigurationItemRefs []ConfigurationItemRef `json:"configuration_item_refs,omitempty"`
+}
+
+// These fields of a ChangePayload record are sent when closing a change that
+// was opened earlier.
+var closingFields = []string{"end_date", "close_code", "close_notes"}
+
+// Serialize returns the payload that we can send into SNow.
+func (chg Change) Serialize(cfg MappingConfiguration, ruleset MappingRuleset, routingInfo map[string]string) ([]byte, error) {
+	isStarted := chg.EndedAt == nil
+	if isStarted && chg.CorrelationID == "" {
+		return nil, tenso.Permanent(errors.New("cannot open a change without a correlation ID"))
+	}
+
+	// find AZs, datacenters and environment for this change (mapping rules can match on those)
+	loc, err := chg.ResolveLocation(cfg)
+	if err != nil {
+		return nil, tenso.Permanent(err)
+	}
+
+	// by default, we will not create a change object in ServiceNow if:
+	//- we did not start deploying (OutcomeNotDeployed)
+	//- the deployment did not finish (e.g. OutcomeHelmUpgradeFailed) -- as
+	//  requested by our change coordinator, because the state of the Helm
+	//  deployment is not clear at this point
+	//
+	// This can be overridden through the "outcome_actions" in the mapping rules.
+	// If a change was already opened for this deployment, it needs to be closed
+	// regardless of the outcome, so that decision is deferred to the delivery.
+	rule := ruleset.Evaluate(chg, loc, routingInfo)
+	var action OutcomeAction
+	if !isStarted {
+		action = rule.ActionFor(chg.Outcome)
+		if action == OutcomeActionSkip && chg.CorrelationID == "" {
+			return nil, tenso.SkipDeliveryError{
+				Reason:  string(chg.Outcome),
+				Message: fmt.Sprintf("no ServiceNow record is configured for outcome %q", chg.Outcome),
+			}
 		}
 	}
 
-	rule := ruleset.Evaluate(chg, routingInfo)
 	data := map[string]any{
 		"standard_change_template_id": rule.ChangeTemplateID,
 		"assigned_to":                 rule.Assignee,
 		"requested_by":                rule.Requester,
 		"u_implementation_contact":    chg.Executee,
 		"service_offering":            rule.ServiceOffering,
-		"u_data_center":               strings.Join(datacenters, ", "),
+		"u_data_center":               strings.Join(loc.Datacenters, ", "),
 		"u_responsible_manager":       rule.ResponsibleManager,
 		// Custom fields cannot be baked into the template, therefore statically setting it here.
 		"u_impacted_lobs":         "d367e6471ba388d020c8fddacd4bcb45", // "GCS Global Cloud Services" --> robust against naming changes
-		"u_affected_environments": environment,
+		"u_affected_environments": loc.Environment,
 		"start_date":              sNowTimeStr(chg.StartedAt),
-		"end_date":                sNowTimeStr(chg.EndedAt),
-		// Status fields cannot be baked into the template, therefore statically setting it here.
-		"close_code":        "Implemented - Successfully",
-		"close_notes":       nl2br(chg.Description),
-		"short_description": chg.Summary,
+		"short_description":       chg.Summary,
 		// This field is required, but since we don't have a way to judge security-relevance of changes,
 		// we have been told to always report false. The truthy value would be "Change is Security relevant".
 		"u_lob_field_1": "Change not Security relevant",
@@ -100,7 +190,90 @@ func (chg Change) Serialize(cfg MappingConfiguration, ruleset MappingRuleset, ro
 	if chg.ConfigurationItem != "" {
 		data["cmdb_ci"] = chg.ConfigurationItem
 	}
-	return json.Marshal(data)
+
+	if isStarted {
+		data["state"] = "implement"
+		data["description"] = nl2br(chg.Description)
+		err := applyFieldTemplates(data, rule.Fields, newTemplateContext(chg, loc, routingInfo), isStarted)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(ChangePayload{
+			Action:                ChangeActionOpen,
+			CorrelationID:         chg.CorrelationID,
+			Record:                data,
+			ConfigurationItemRefs: chg.ConfigurationItemRefs,
+		})
+	}
+
+	closeNotes := chg.Description
+	if len(chg.ErrorMessages) > 0 {
+		closeNotes += "\n\nErrors:\n" + strings.Join(chg.ErrorMessages, "\n")
+	}
+
+	// Status fields cannot be baked into the template, therefore statically setting them here.
+	data["end_date"] = sNowTimeStr(chg.EndedAt)
+	data["close_code"] = closeCodeForOutcome(chg.Outcome)
+	data["close_notes"] = nl2br(closeNotes)
+	err = applyFieldTemplates(data, rule.Fields, newTemplateContext(chg, loc, routingInfo), isStarted)
+	if err != nil {
+		return nil, err
+	}
+	payload := ChangePayload{
+		Action:                ChangeActionClose,
+		CorrelationID:         chg.CorrelationID,
+		Outcome:               chg.Outcome,
+		CreateIfMissing:       action == OutcomeActionCreateChange,
+		Record:                data,
+		ConfigurationItemRefs: chg.ConfigurationItemRefs,
+	}
+
+	if action == OutcomeActionCreateIncident {
+		payload.Incident = map[string]any{
+			"short_description": fmt.Sprintf("%s (outcome: %s)", chg.Summary, chg.Outcome),
+			"description":       nl2br(closeNotes),
+			"assigned_to":       rule.Assignee,
+			"caller_id":         rule.Requester,
+			"service_offering":  rule.ServiceOffering,
+			"u_data_center":     data["u_data_center"],
+		}
+		if chg.ConfigurationItem != "" {
+			payload.Incident["cmdb_ci"] = chg.ConfigurationItem
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// Overrides fields in the given change record with the respective templates
+// from the mapping rules. Fields that are only relevant for closing a change
+// are not set when opening a change.
+func applyFieldTemplates(data map[string]any, fields map[string]FieldTemplate, ctx TemplateContext, isStarted bool) error {
+	for key, ft := range fields {
+		if isStarted && slices.Contains(closingFields, key) {
+			continue
+		}
+		value, err := ft.Execute(ctx)
+		if err != nil {
+			return tenso.Permanent(fmt.Errorf("while rendering template for field %q: %w", key, err))
+		}
+		data[key] = value
+	}
+	return nil
+}
+
+func closeCodeForOutcome(outcome deployevent.Outcome) string {
+	switch outcome {
+	case deployevent.OutcomeSucceeded:
+		return "Implemented - Successfully"
+	case deployevent.OutcomePartiallyDeployed, deployevent.OutcomeE2ETestFailed:
+		// something was deployed, but not everything went as planned
+		return "Implemented - with Issues"
+	case deployevent.OutcomeNotDeployed:
+		return "Not Implemented"
+	default:
+		// the deployment was attempted, but failed
+		return "Unsuccessful"
+	}
 }
 
 func sNowTimeStr(t *time.Time) string {
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-api-declarations/deployevent"
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/topology"
+)
+
+const testTemplatedRulesetJSON = `[
+	{
+		"change_template_id": "My Test Template",
+		"fields": {
+			"u_impacted_lobs": "custom-lob",
+			"close_code": "{{ if eq .Outcome \"succeeded\" }}Done{{ else }}Not Done{{ end }}",
+			"u_deployed_releases": "{{ range $idx, $rel := .HelmReleases }}{{ if $idx }}, {{ end }}{{ $rel.Name }}@{{ $rel.Cluster }}{{ end }}",
+			"u_team": "{{ upper .Pipeline.TeamName }} in {{ .Environment }} ({{ join .Datacenters \" + \" }})",
+			"u_versions": "{{ range $name, $repo := .GitRepos }}{{ $name }}={{ $repo.CommitID }}{{ end }}"
+		}
+	}
+]`
+
+func TestChangeSerializeWithFieldTemplates(t *testing.T) {
+	var cfg servicenow.MappingConfiguration
+	must.SucceedT(t, json.Unmarshal([]byte(`{
+		"regions": { "qa-de-1": [ "qa-de-1a", "qa-de-1b" ] },
+		"availability_zones": {
+			"qa-de-1a": { "environment": "QA", "datacenters": [ "ROT 1" ] },
+			"qa-de-1b": { "environment": "QA", "datacenters": [ "ROT 2" ] }
+		}
+	}`), &cfg))
+	var ruleset servicenow.MappingRuleset
+	must.SucceedT(t, json.Unmarshal([]byte(testTemplatedRulesetJSON), &ruleset))
+
+	startedAt := time.Unix(1700000000, 0)
+	endedAt := startedAt.Add(5 * time.Minute)
+	chg := servicenow.Change{
+		StartedAt: &startedAt,
+		EndedAt:   &endedAt,
+		Outcome:   deployevent.OutcomeSucceeded,
+		Summary:   "Deploy swift",
+		Region:    "qa-de-1",
+		Pipeline:  &deployevent.Pipeline{TeamName: "services", PipelineName: "swift"},
+		HelmReleases: []*deployevent.HelmRelease{
+			{Name: "swift-proxy", Cluster: "s-qa-de-1"},
+			{Name: "swift-account", Cluster: "s-qa-de-1"},
+		},
+		GitRepos: map[string]deployevent.GitRepo{
+			"helm-charts.git": {CommitID: "eb6e28db0be6a56cda4e6edd473ab61ba698efbe"},
+		},
+	}
+
+	buf := must.ReturnT(chg.Serialize(cfg, ruleset, nil))(t)
+	var payload servicenow.ChangePayload
+	must.SucceedT(t, json.Unmarshal(buf, &payload))
+	assert.Equal(t, payload.Record["u_impacted_lobs"], any("custom-lob"))
+	assert.Equal(t, payload.Record["close_code"], any("Done"))
+	assert.Equal(t, payload.Record["u_deployed_releases"], any("swift-proxy@s-qa-de-1, swift-account@s-qa-de-1"))
+	assert.Equal(t, payload.Record["u_team"], any("SERVICES in QA (ROT 1 + ROT 2)"))
+	assert.Equal(t, payload.Record["u_versions"], any("helm-charts.git=eb6e28db0be6a56cda4e6edd473ab61ba698efbe"))
+	// fields without templates are unaffected
+	assert.Equal(t, payload.Record["short_description"], any("Deploy swift"))
+
+	// when opening a change, closing fields are not rendered
+	chg.EndedAt = nil
+	chg.Outcome = ""
+	chg.CorrelationID = "concourse:services/swift/deploy/42"
+	buf = must.ReturnT(chg.Serialize(cfg, ruleset, nil))(t)
+	payload = servicenow.ChangePayload{}
+	must.SucceedT(t, json.Unmarshal(buf, &payload))
+	assert.Equal(t, payload.Action, servicenow.ChangeActionOpen)
+	_, exists := payload.Record["close_code"]
+	assert.Equal(t, exists, false)
+	assert.Equal(t, payload.Record["u_impacted_lobs"], any("custom-lob"))
+}
+
+func TestChangeResolveLocationFromTopology(t *testing.T) {
+	topologyPath := filepath.Join(t.TempDir(), "topology.json")
+	must.SucceedT(t, os.WriteFile(topologyPath, []byte(`{"regions":{"qa-de-1":["qa-de-1a","qa-de-1b"],"qa-de-2":["qa-de-2a"]}}`), 0o600))
+	t.Setenv("TENSO_TOPOLOGY_CONFIG_PATH", topologyPath)
+
+	var cfg servicenow.MappingConfiguration
+	must.SucceedT(t, json.Unmarshal([]byte(`{
+		"regions": { "qa-de-2": [ "qa-de-2b" ] },
+		"availability_zones": {
+			"qa-de-1a": { "environment": "QA", "datacenters": [ "ROT 1" ] },
+			"qa-de-1b": { "environment": "QA", "datacenters": [ "ROT 2" ] },
+			"qa-de-2b": { "environment": "QA", "datacenters": [ "ROT 3" ] }
+		}
+	}`), &cfg))
+	cfg.Topology = must.ReturnT(topology.Load("TENSO_TOPOLOGY_CONFIG_PATH"))(t)
+
+	// regions that are not in the mapping config are taken from the topology
+	loc := must.ReturnT(servicenow.Change{Region: "qa-de-1"}.ResolveLocation(cfg))(t)
+	assert.Equal(t, loc.AvailabilityZones, []string{"qa-de-1a", "qa-de-1b"})
+	assert.Equal(t, loc.Datacenters, []string{"ROT 1", "ROT 2"})
+	loc = must.ReturnT(servicenow.Change{AvailabilityZone: "qa-de-1b"}.ResolveLocation(cfg))(t)
+	assert.Equal(t, loc.Region, "qa-de-1")
+
+	// regions in the mapping config take precedence
+	loc = must.ReturnT(servicenow.Change{Region: "qa-de-2"}.ResolveLocation(cfg))(t)
+	assert.Equal(t, loc.AvailabilityZones, []string{"qa-de-2b"})
+
+	_, err := servicenow.Change{Region: "qa-de-3"}.ResolveLocation(cfg)
+	assert.ErrEqual(t, err, `region not found in mapping config or topology: "qa-de-3"`)
+}
@@ -11,7 +11,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sapcc/go-bits/logg"
+	"go.xyrillian.de/gg/option"
 
 	"github.com/sapcc/tenso/internal/tenso"
 )
@@ -28,6 +34,7 @@ func (cs ClientSet) Init() error {
 	}
 
 	for clientName, client := range cs {
+		client.name = clientName
 		err := client.Init()
 		if err != nil {
 			return fmt.Errorf("in initialization of endpoint client %q: %w", clientName, err)
@@ -37,108 +44,528 @@ func (cs ClientSet) Init() error {
 }
 
 // DeliverChangePayload delivers a change payload to ServiceNow. This function
-// has the same interface as DeliverPayload() in the tenso.DeliveryHandler
-// interface.
-func (cs ClientSet) DeliverChangePayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+// has the same interface as DeliverPayloadWithState() in the
+// tenso.StatefulDeliveryHandler interface.
+func (cs ClientSet) DeliverChangePayload(ctx context.Context, state tenso.DeliveryState, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
 	clientName, exists := routingInfo["servicenow-target"]
 	if !exists {
 		clientName = "default"
 	}
 	client, exists := cs[clientName]
 	if !exists {
-		return nil, fmt.Errorf("unknown routing info: servicenow-target=%q", clientName)
+		return nil, tenso.Permanent(fmt.Errorf("unknown routing info: servicenow-target=%q", clientName))
 	}
-	return client.DeliverChangePayload(ctx, payload)
+	return client.DeliverChangePayload(ctx, state, clientName, payload)
 }
 
 // Client can submit change payloads to ServiceNow.
 //
 // This type appears in type MappingConfiguration through type ClientSet.
 type Client struct {
-	EndpointURL    string       `json:"url"`
-	ClientCertPath string       `json:"client_cert"`
-	PrivateKeyPath string       `json:"private_key"`
-	httpClient     *http.Client `json:"-"`
+	EndpointURL string `json:"url"`
+	UpdateURL   string `json:"update_url"`
+	IncidentURL string `json:"incident_url"`
+	// Exactly one authentication method must be configured:
+	// either a client certificate for mutual TLS,
+	ClientCertPath string `json:"client_cert"`
+	PrivateKeyPath string `json:"private_key"`
+	// or basic auth,
+	Username     string `json:"username"`
+	PasswordPath string `json:"password_path"`
+	// or OAuth2 client credentials.
+	OAuthTokenURL         string `json:"oauth_token_url"`
+	OAuthClientID         string `json:"oauth_client_id"`
+	OAuthClientSecretPath string `json:"oauth_client_secret_path"`
+	// Configuration items (all optional) can be resolved through a mapping
+	// file and/or through a lookup in the CMDB of this ServiceNow instance.
+	ConfigurationItemMappingPath string                           `json:"cmdb_ci_mapping_path"`
+	CMDBLookupURLs               map[ConfigurationItemKind]string `json:"cmdb_lookup_urls"`
+	CMDBCacheTTL                 string                           `json:"cmdb_cache_ttl"`
+
+	name        string // only used in log messages and metrics
+	auth        authenticator
+	httpClient  *http.Client
+	ciResolvers []configurationItemResolver
 }
 
 // Init validates the provided Client config and initializes the internal HTTP client.
 func (c *Client) Init() error {
-	// in unit tests, we are setting this dummy value to circumvent the
-	// client-cert loading
-	if c.EndpointURL == "http://www.example.com" {
-		return nil
-	}
-
-	switch {
-	case c.EndpointURL == "":
+	if c.EndpointURL == "" {
 		return errors.New(`missing "url" attribute`)
-	case c.ClientCertPath == "":
-		return errors.New(`missing "client_cert" attribute`)
-	case c.PrivateKeyPath == "":
-		return errors.New(`missing "private_key" attribute`)
 	}
-	cert, err := tls.LoadX509KeyPair(c.ClientCertPath, c.PrivateKeyPath)
+	var err error
+	c.auth, err = c.newAuthenticator()
+	if err != nil {
+		return err
+	}
+	c.ciResolvers, err = c.newConfigurationItemResolvers()
 	if err != nil {
-		return fmt.Errorf("cannot load client certificate: %w", err)
+		return err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCertPath != "" {
+		loader, err := newCertificateLoader(c.name, c.ClientCertPath, c.PrivateKeyPath)
+		if err != nil {
+			return err
+		}
+		tlsConfig.GetClientCertificate = loader.GetClientCertificate
 	}
 
 	c.httpClient = &http.Client{
 		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				Certificates: []tls.Certificate{cert},
-				MinVersion:   tls.VersionTLS12,
-			},
-			Proxy: http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+			Proxy:           http.ProxyFromEnvironment,
 		},
 	}
 	return nil
 }
 
+// OverrideHTTPClient replaces the HTTP client that is used for talking to
+// ServiceNow. This is used by unit tests to redirect requests into a mock.
+// Credentials are still added to each request as configured.
+func (c *Client) OverrideHTTPClient(hc *http.Client) *Client {
+	c.httpClient = hc
+	return c
+}
+
 // DeliverChangePayload delivers a change payload to ServiceNow.
 // It is usually called through ClientSet.DeliverChangePayload().
-func (c *Client) DeliverChangePayload(ctx context.Context, payload []byte) (*tenso.DeliveryLog, error) {
-	// if the TranslationHandler wants us to ignore this payload, skip the delivery
+func (c *Client) DeliverChangePayload(ctx context.Context, state tenso.DeliveryState, clientName string, payload []byte) (*tenso.DeliveryLog, error) {
+	// payloads converted by older versions of Tenso may still contain this
+	// marker instead of having been skipped during translation
 	if string(payload) == "skip" {
-		return nil, nil
+		return nil, tenso.SkipDeliveryError{Reason: "legacy-skip-payload"}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.EndpointURL, bytes.NewReader(payload))
+	// payloads converted by older versions of Tenso may also contain just the
+	// change record instead of a ChangePayload
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(payload, &fields)
 	if err != nil {
-		return nil, fmt.Errorf("while preparing request for POST %s: %w", c.EndpointURL, err)
+		return nil, tenso.Permanent(err)
+	}
+	if _, exists := fields["record"]; !exists {
+		result, err := c.createRecord(ctx, c.EndpointURL, payload, deliveryCorrelationID(state, clientName, "change"))
+		return result.deliveryLog("created", ""), err
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	var cp ChangePayload
+	err = json.Unmarshal(payload, &cp)
 	if err != nil {
-		return nil, fmt.Errorf("during POST %s: %w", c.EndpointURL, err)
+		return nil, tenso.Permanent(err)
+	}
+	missNote := c.resolveConfigurationItem(ctx, &cp)
+	dl, err := c.deliverChange(ctx, state, clientName, cp)
+	if dl != nil && dl.Message != "" {
+		dl.Message += missNote
+	}
+	return dl, err
+}
+
+// Deliveries of the start and finish of the same deployment may run
+// concurrently in different workers. This lock serializes them until the
+// delivery transaction ends, so that both see the servicenow_changes record
+// written by the other one. (Hash collisions only cause unnecessary waiting.)
+const lockServiceNowChangeQuery = `SELECT pg_advisory_xact_lock(hashtextextended($1 || ':' || $2, 0))`
+
+// Implements DeliverChangePayload() once the payload has been parsed.
+func (c *Client) deliverChange(ctx context.Context, state tenso.DeliveryState, clientName string, cp ChangePayload) (*tenso.DeliveryLog, error) {
+	recordBytes, err := json.Marshal(cp.Record)
+	if err != nil {
+		return nil, tenso.Permanent(err)
+	}
+
+	// find the change that we opened earlier (if any)
+	var existingChange option.Option[tenso.ServiceNowChange]
+	if cp.CorrelationID != "" && state.Tx != nil {
+		_, err = state.Tx.ExecContext(ctx, lockServiceNowChangeQuery, clientName, cp.CorrelationID)
+		if err != nil {
+			return nil, err
+		}
+		existingChange, err = tenso.ServiceNowChangeStore.SelectOneOrNoneWhere(ctx, state.Tx,
+			`target = $1 AND correlation_id = $2`, clientName, cp.CorrelationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch cp.Action {
+	case ChangeActionOpen:
+		if c.UpdateURL == "" {
+			return nil, tenso.SkipDeliveryError{
+				Reason:  "open-not-supported",
+				Message: fmt.Sprintf(`changes cannot be opened on ServiceNow endpoint %q because it does not have an "update_url"`, clientName),
+			}
+		}
+		if state.Tx == nil {
+			return nil, tenso.Permanent(errors.New("cannot open changes without access to the database"))
+		}
+		if change, ok := existingChange.Unpack(); ok {
+			if change.ClosedAt != nil {
+				// the deployment finished before its start was delivered
+				return nil, tenso.SkipDeliveryError{
+					Reason:  "already-closed",
+					Message: "the change was already closed for " + cp.CorrelationID,
+				}
+			}
+			return nil, tenso.SkipDeliveryError{
+				Reason:  "already-opened",
+				Message: fmt.Sprintf("%s was already opened for %s", change.Number, cp.CorrelationID),
+			}
+		}
+
+		result, err := c.createRecord(ctx, c.EndpointURL, recordBytes, deliveryCorrelationID(state, clientName, "change"))
+		if err != nil {
+			return nil, err
+		}
+		if result.SysID.Value == "" {
+			// we cannot fail here because this would create a duplicate change on
+			// retry; the record is stored anyway to stop the finish event from
+			// creating another change
+			logg.Error("could not find sys_id of change opened in ServiceNow for %s; the change will have to be closed manually", cp.CorrelationID)
+		}
+		err = tenso.ServiceNowChangeStore.Insert(ctx, state.Tx, &tenso.ServiceNowChange{
+			Target:        clientName,
+			CorrelationID: cp.CorrelationID,
+			SysID:         result.SysID.Value,
+			Number:        result.Number.Value,
+			CreatedAt:     state.Now,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.deliveryLog("opened", ""), nil
+
+	case ChangeActionClose:
+		if change, ok := existingChange.Unpack(); ok {
+			if change.ClosedAt != nil {
+				return nil, tenso.SkipDeliveryError{
+					Reason:  "already-closed",
+					Message: fmt.Sprintf("%s was already closed for %s", change.Number, cp.CorrelationID),
+				}
+			}
+			if change.SysID == "" {
+				change.ClosedAt = &state.Now
+				err := tenso.ServiceNowChangeStore.Update(ctx, state.Tx, change)
+				if err != nil {
+					return nil, err
+				}
+				return nil, tenso.SkipDeliveryError{
+					Reason:  "unknown-sys-id",
+					Message: fmt.Sprintf("the change opened for %s has to be closed manually because its sys_id is not known", cp.CorrelationID),
+				}
+			}
+			// NOTE: Closing the change is idempotent, so if creating the incident
+			// fails, we can safely close the change again on retry.
+			err := c.closeChange(ctx, change.SysID, cp.Record)
+			if err != nil {
+				return nil, err
+			}
+			dl := createdRecord{
+				SysID:  responseValue{change.SysID},
+				Number: responseValue{change.Number},
+			}.deliveryLog("closed", "")
+			if cp.Incident != nil {
+				incident, err := c.createIncident(ctx, cp.Incident, deliveryCorrelationID(state, clientName, "incident"))
+				if err != nil {
+					return nil, err
+				}
+				dl = mergeDeliveryLogs(dl, incident.deliveryLog("created", "incident_"))
+			}
+			change.ClosedAt = &state.Now
+			err = tenso.ServiceNowChangeStore.Update(ctx, state.Tx, change)
+			if err != nil {
+				return nil, err
+			}
+			return dl, nil
+		}
+
+		// no change was opened before -> create a closed change and/or an incident (if desired)
+		switch {
+		case cp.CreateIfMissing:
+			result, err := c.createRecord(ctx, c.EndpointURL, recordBytes, deliveryCorrelationID(state, clientName, "change"))
+			if err != nil {
+				return nil, err
+			}
+			if result.SysID.Value != "" {
+				// remember this change to avoid creating another one for the same deployment
+				err = rememberClosedChange(ctx, state, clientName, cp.CorrelationID, result)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return result.deliveryLog("created", ""), nil
+		case cp.Incident != nil:
+			incident, err := c.createIncident(ctx, cp.Incident, deliveryCorrelationID(state, clientName, "incident"))
+			if err != nil {
+				return nil, err
+			}
+			err = rememberClosedChange(ctx, state, clientName, cp.CorrelationID, createdRecord{})
+			if err != nil {
+				return nil, err
+			}
+			return incident.deliveryLog("created", "incident_"), nil
+		default:
+			err := rememberClosedChange(ctx, state, clientName, cp.CorrelationID, createdRecord{})
+			if err != nil {
+				return nil, err
+			}
+			return nil, tenso.SkipDeliveryError{
+				Reason:  string(cp.Outcome),
+				Message: fmt.Sprintf("no ServiceNow record is configured for outcome %q", cp.Outcome),
+			}
+		}
+
+	default:
+		return nil, tenso.Permanent(fmt.Errorf("unknown action in change payload: %q", cp.Action))
+	}
+}
+
+// Records that the deployment with the given correlation ID has finished, so
+// that a start event for it that is delivered late does not open a change that
+// would never be closed. If no change was created for the deployment, the
+// given record is empty and only this marker is stored.
+func rememberClosedChange(ctx context.Context, state tenso.DeliveryState, clientName, correlationID string, change createdRecord) error {
+	if correlationID == "" || state.Tx == nil {
+		return nil
+	}
+	return tenso.ServiceNowChangeStore.Insert(ctx, state.Tx, &tenso.ServiceNowChange{
+		Target:        clientName,
+		CorrelationID: correlationID,
+		SysID:         change.SysID.Value,
+		Number:        change.Number.Value,
+		CreatedAt:     state.Now,
+		ClosedAt:      &state.Now,
+	})
+}
+
+// createdRecord contains the parts of a response to a record creation that we are interested in.
+type createdRecord struct {
+	SysID  responseValue `json:"sys_id"`
+	Number responseValue `json:"number"`
+	// If true, the record was not created by this request, but found through
+	// its correlation ID because it was created by an earlier delivery attempt.
+	Existed bool `json:"-"`
+}
+
+// The keyPrefix is added to the keys in DeliveryLog.ObjectIDs to distinguish
+// different types of records that are created by the same delivery.
+func (cr createdRecord) deliveryLog(verb, keyPrefix string) *tenso.DeliveryLog {
+	if cr.Existed {
+		verb = "found previously " + verb
+	}
+	dl := tenso.DeliveryLog{ObjectIDs: make(map[string]string)}
+	if cr.Number.Value != "" {
+		dl.Message = fmt.Sprintf("%s %s in ServiceNow", verb, cr.Number.Value)
+		dl.ObjectIDs[keyPrefix+"number"] = cr.Number.Value
+	}
+	if cr.SysID.Value != "" {
+		dl.ObjectIDs[keyPrefix+"sys_id"] = cr.SysID.Value
+	}
+	return &dl
+}
+
+// responseValue is a field value in a ServiceNow API response. Depending on
+// the API and the request parameters, field values are either rendered as
+// plain strings or as objects like `{"value":"...","display_value":"..."}`.
+type responseValue struct {
+	Value string
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *responseValue) UnmarshalJSON(buf []byte) error {
+	if len(buf) > 0 && buf[0] == '"' {
+		return json.Unmarshal(buf, &v.Value)
+	}
+	var data struct {
+		Value string `json:"value"`
+	}
+	err := json.Unmarshal(buf, &data)
+	v.Value = data.Value
+	return err
+}
+
+func mergeDeliveryLogs(lhs, rhs *tenso.DeliveryLog) *tenso.DeliveryLog {
+	var messages []string
+	for _, msg := range []string{lhs.Message, rhs.Message} {
+		if msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+	objectIDs := maps.Clone(lhs.ObjectIDs)
+	maps.Copy(objectIDs, rhs.ObjectIDs)
+	return &tenso.DeliveryLog{
+		Message:   strings.Join(messages, " and "),
+		ObjectIDs: objectIDs,
+	}
+}
+
+// deliveryCorrelationID identifies a record that is created in ServiceNow by
+// a specific delivery. It is stored in the "correlation_id" field of the
+// record, so that a retried delivery can find the record instead of creating
+// a duplicate (e.g. when the worker crashed after ServiceNow had already
+// created the record). The region is included because event IDs are only
+// unique within one Tenso instance, but Tenso instances in several regions
+// may deliver into the same ServiceNow. The result is empty if the delivery
+// or the region is not known.
+func deliveryCorrelationID(state tenso.DeliveryState, clientName, recordType string) string {
+	if state.EventID == 0 || state.Region == "" {
+		return ""
+	}
+	return fmt.Sprintf("tenso:%s:%d:%s:%s:%s", state.Region, state.EventID, state.PayloadType, clientName, recordType)
+}
+
+// If correlationID is not empty, an existing record with that correlation ID
+// is returned instead of creating a new one.
+func (c *Client) createRecord(ctx context.Context, reqURL string, record []byte, correlationID string) (createdRecord, error) {
+	if correlationID != "" {
+		existing, found, err := c.findRecord(ctx, reqURL, correlationID)
+		if err != nil {
+			return createdRecord{}, err
+		}
+		if found {
+			logg.Info("not creating a duplicate record in ServiceNow: %s was already created for correlation ID %q", existing.Number.Value, correlationID)
+			return existing, nil
+		}
+
+		var fields map[string]json.RawMessage
+		err = json.Unmarshal(record, &fields)
+		if err != nil {
+			return createdRecord{}, tenso.Permanent(err)
+		}
+		fields["correlation_id"], err = json.Marshal(correlationID)
+		if err != nil {
+			return createdRecord{}, err
+		}
+		record, err = json.Marshal(fields)
+		if err != nil {
+			return createdRecord{}, err
+		}
+	}
+
+	respBody, err := c.doRequest(ctx, http.MethodPost, reqURL, record)
+	if err != nil {
+		return createdRecord{}, err
 	}
-	defer resp.Body.Close()
 
 	// on success, make a best-effort attempt to retrieve the object ID from the
-	// response...
-	if resp.StatusCode < 400 {
-		var respData struct {
-			Result struct {
-				Number struct {
-					Value string `json:"value"`
-				} `json:"number"`
-			} `json:"result"`
+	// response, but failure to retrieve it is not an error, because we want to
+	// avoid double delivery of the same payload if at all possible
+	var respData struct {
+		Result createdRecord `json:"result"`
+	}
+	_ = json.Unmarshal(respBody, &respData)
+	return respData.Result, nil
+}
+
+// Looks for a record with the given correlation ID in the table that the given
+// URL refers to. This uses the query interface of the ServiceNow Table API.
+func (c *Client) findRecord(ctx context.Context, tableURL, correlationID string) (createdRecord, bool, error) {
+	u, err := url.Parse(tableURL)
+	if err != nil {
+		return createdRecord{}, false, tenso.Permanent(err)
+	}
+	query := u.Query()
+	query.Set("sysparm_query", "correlation_id="+correlationID)
+	query.Set("sysparm_fields", "sys_id,number")
+	query.Set("sysparm_limit", "1")
+	u.RawQuery = query.Encode()
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		// the record itself has not been submitted yet, so a failed lookup says
+		// nothing about whether the record would be accepted; retry later
+		// instead of failing the delivery permanently
+		if perr, ok := errors.AsType[tenso.PermanentError](err); ok {
+			err = perr.Inner
 		}
-		err := json.NewDecoder(resp.Body).Decode(&respData)
-		if err == nil && respData.Result.Number.Value != "" {
-			return &tenso.DeliveryLog{
-				Message: fmt.Sprintf("created %s in ServiceNow", respData.Result.Number.Value),
-			}, nil
+		return createdRecord{}, false, fmt.Errorf("while looking for records with correlation ID %q: %w", correlationID, err)
+	}
+	var respData struct {
+		Result []createdRecord `json:"result"`
+	}
+	err = json.Unmarshal(respBody, &respData)
+	if err != nil {
+		return createdRecord{}, false, fmt.Errorf("while looking for records with correlation ID %q: %w", correlationID, err)
+	}
+	if len(respData.Result) == 0 {
+		return createdRecord{}, false, nil
+	}
+	result := respData.Result[0]
+	result.Existed = true
+	return result, true, nil
+}
+
+func (c *Client) createIncident(ctx context.Context, incident map[string]any, correlationID string) (createdRecord, error) {
+	if c.IncidentURL == "" {
+		return createdRecord{}, tenso.Permanent(errors.New(`cannot create incident without "incident_url"`))
+	}
+	buf, err := json.Marshal(incident)
+	if err != nil {
+		return createdRecord{}, err
+	}
+	return c.createRecord(ctx, c.IncidentURL, buf, correlationID)
+}
+
+func (c *Client) closeChange(ctx context.Context, sysID string, record map[string]any) error {
+	if c.UpdateURL == "" {
+		return tenso.Permanent(errors.New(`cannot close change without "update_url"`))
+	}
+	data := map[string]any{"state": "closed"}
+	for _, field := range closingFields {
+		if value, exists := record[field]; exists {
+			data[field] = value
 		}
-		// ...but failure to retrieve it is not an error, because we want
-		// to avoid double delivery of the same payload if at all possible
-		return nil, nil
 	}
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = c.doRequest(ctx, http.MethodPatch, strings.TrimSuffix(c.UpdateURL, "/")+"/"+url.PathEscape(sysID), buf)
+	return err
+}
+
+// Returns the response body if the request was successful.
+func (c *Client) doRequest(ctx context.Context, method, reqURL string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("while preparing request for %s %s: %w", method, reqURL, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	err = c.auth.Authorize(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("while authenticating %s %s: %w", method, reqURL, err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("during %s %s: %w", method, reqURL, err)
+	}
+	defer resp.Body.Close()
 
-	// unexpected error -> log response body
 	bodyBytes, err := io.ReadAll(resp.Body)
+	if resp.StatusCode < 400 {
+		// the request was successful, so if we cannot read the response body,
+		// it's not a big deal
+		return bodyBytes, nil
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// get fresh credentials for the next attempt
+		c.auth.Invalidate()
+	}
+
+	// unexpected error -> log response body
 	if err != nil {
-		return nil, fmt.Errorf("while reading response body for failed POST %s: %w", c.EndpointURL, err)
+		return nil, fmt.Errorf("while reading response body for failed %s %s: %w", method, reqURL, err)
+	}
+	err = fmt.Errorf("%s failed with status %d and response: %q", method, resp.StatusCode, string(bodyBytes))
+	if tenso.IsPermanentFailureStatus(resp.StatusCode) {
+		return nil, tenso.Permanent(err)
 	}
-	return nil, fmt.Errorf("POST failed with status %d and response: %q", resp.StatusCode, string(bodyBytes))
+	return nil, err
 }
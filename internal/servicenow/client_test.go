@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+const testChangePayload = `{"action":"close","outcome":"succeeded","create_if_missing":true,"record":{"short_description":"Deploy swift"}}`
+
+// Builds a mock ServiceNow that expects the given Authorization header on
+// requests to /change_request, and issues tokens on /oauth_token.do.
+func newAuthCheckingServer(t *testing.T, expectedAuth *string, tokenCount *int) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth_token.do", func(w http.ResponseWriter, r *http.Request) {
+		must.SucceedT(t, r.ParseForm())
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_id") != "tenso" || r.PostForm.Get("client_secret") != "swordfish" {
+			http.Error(w, "invalid client", http.StatusUnauthorized)
+			return
+		}
+		*tokenCount++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":1800}`, *tokenCount)
+	})
+	mux.HandleFunc("POST /change_request", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != *expectedAuth {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"result":{"sys_id":"abcdef","number":"CHG0012345"}}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server, configJSON string) *servicenow.Client {
+	t.Helper()
+	var c servicenow.Client
+	must.SucceedT(t, json.Unmarshal(fmt.Appendf(nil, configJSON, srv.URL), &c))
+	must.SucceedT(t, c.Init())
+	return c.OverrideHTTPClient(srv.Client())
+}
+
+func TestClientWithBasicAuth(t *testing.T) {
+	expectedAuth := "Basic dGVuc286c3dvcmRmaXNo" // "tenso:swordfish"
+	tokenCount := 0
+	srv := newAuthCheckingServer(t, &expectedAuth, &tokenCount)
+	c := newTestClient(t, srv, `{"url":"%s/change_request","username":"tenso","password_path":"fixtures/servicenow-password.txt"}`)
+
+	dl := must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0012345 in ServiceNow")
+	assert.Equal(t, tokenCount, 0)
+}
+
+func TestClientWithOAuthClientCredentials(t *testing.T) {
+	expectedAuth := "Bearer token1"
+	tokenCount := 0
+	srv := newAuthCheckingServer(t, &expectedAuth, &tokenCount)
+	c := newTestClient(t, srv, `{"url":"%[1]s/change_request","oauth_token_url":"%[1]s/oauth_token.do","oauth_client_id":"tenso","oauth_client_secret_path":"fixtures/servicenow-password.txt"}`)
+
+	// the first request obtains a token, the second one reuses it
+	for range 2 {
+		dl := must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", []byte(testChangePayload)))(t)
+		assert.Equal(t, dl.Message, "created CHG0012345 in ServiceNow")
+	}
+	assert.Equal(t, tokenCount, 1)
+
+	// when the token is rejected, the delivery fails, but a fresh token is obtained for the next attempt
+	expectedAuth = "Bearer token2"
+	_, err := c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", []byte(testChangePayload))
+	if err == nil {
+		t.Fatal("expected delivery with rejected token to fail")
+	}
+	assert.Equal(t, tenso.IsPermanent(err), false)
+	dl := must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0012345 in ServiceNow")
+	assert.Equal(t, tokenCount, 2)
+}
+
+func TestClientConfigValidation(t *testing.T) {
+	testCases := map[string]string{
+		`{}`:                   `missing "url" attribute`,
+		`{"url":"https://sn"}`: `no authentication method configured (need either "client_cert", "username" or "oauth_token_url")`,
+		`{"url":"https://sn","username":"tenso","oauth_token_url":"https://sn/oauth_token.do"}`:                                                 `only one authentication method may be configured (either "client_cert", "username" or "oauth_token_url")`,
+		`{"url":"https://sn","username":"tenso"}`:                                                                                               `missing "password_path" attribute`,
+		`{"url":"https://sn","client_cert":"cert.pem"}`:                                                                                         `missing "private_key" attribute`,
+		`{"url":"https://sn","oauth_token_url":"https://sn/oauth_token.do","oauth_client_id":"tenso"}`:                                          `missing "oauth_client_secret_path" attribute`,
+		`{"url":"https://sn","username":"tenso","password_path":"fixtures/does-not-exist.txt"}`:                                                 `cannot load secret: open fixtures/does-not-exist.txt: no such file or directory`,
+		`{"url":"https://sn","oauth_token_url":"https://sn/oauth_token.do","oauth_client_secret_path":"x.txt"}`:                                 `missing "oauth_client_id" attribute`,
+		`{"url":"https://sn","username":"tenso","password_path":"fixtures/servicenow-password.txt","cmdb_cache_ttl":"1h"}`:                      `"cmdb_cache_ttl" cannot be set without "cmdb_lookup_urls"`,
+		`{"url":"https://sn","username":"tenso","password_path":"fixtures/servicenow-password.txt","cmdb_ci_mapping_path":"fixtures/x.json"}`:   `cannot load configuration item mapping: open fixtures/x.json: no such file or directory`,
+		`{"url":"https://sn","username":"tenso","password_path":"fixtures/servicenow-password.txt","cmdb_lookup_urls":{"server":"https://sn"}}`: `invalid key in "cmdb_lookup_urls": unknown kind of configuration item: "server"`,
+		`{"url":"https://sn","username":"tenso","password_path":"fixtures/servicenow-password.txt","cmdb_lookup_urls":{"host":""}}`:             `invalid value in "cmdb_lookup_urls": missing URL for "host"`,
+	}
+	for configJSON, expectedError := range testCases {
+		var c servicenow.Client
+		must.SucceedT(t, json.Unmarshal([]byte(configJSON), &c))
+		assert.ErrEqual(t, c.Init(), expectedError)
+	}
+}
+
+func TestClientDoesNotCreateDuplicates(t *testing.T) {
+	// a minimal mock of the ServiceNow Table API that supports lookup by correlation ID
+	var records []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /change_request", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Query().Get("sysparm_limit"), "1")
+		result := []map[string]any{}
+		for _, record := range records {
+			if "correlation_id="+record["correlation_id"].(string) == r.URL.Query().Get("sysparm_query") {
+				result = append(result, map[string]any{"sys_id": record["sys_id"], "number": record["number"]})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		must.SucceedT(t, json.NewEncoder(w).Encode(map[string]any{"result": result}))
+	})
+	mux.HandleFunc("POST /change_request", func(w http.ResponseWriter, r *http.Request) {
+		var record map[string]any
+		must.SucceedT(t, json.NewDecoder(r.Body).Decode(&record))
+		record["sys_id"] = fmt.Sprintf("sys%d", len(records)+1)
+		record["number"] = fmt.Sprintf("CHG%07d", len(records)+1)
+		records = append(records, record)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		must.SucceedT(t, json.NewEncoder(w).Encode(map[string]any{"result": record}))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := newTestClient(t, srv, `{"url":"%s/change_request","username":"tenso","password_path":"fixtures/servicenow-password.txt"}`)
+
+	// first delivery creates a change with a correlation ID identifying the delivery
+	state := tenso.DeliveryState{EventID: 42, PayloadType: "helm-deployment-to-servicenow.v1", Region: "qa-de-1"}
+	dl := must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000001 in ServiceNow")
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0]["correlation_id"], any("tenso:qa-de-1:42:helm-deployment-to-servicenow.v1:default:change"))
+
+	// if the same delivery is retried (e.g. because the worker crashed before
+	// recording the successful delivery), the existing change is reported instead
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "found previously created CHG0000001 in ServiceNow")
+	assert.Equal(t, dl.ObjectIDs["sys_id"], "sys1")
+	assert.Equal(t, len(records), 1)
+
+	// a delivery of a different event creates a separate change
+	state.EventID = 43
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000002 in ServiceNow")
+	assert.Equal(t, len(records), 2)
+
+	// so does the delivery of an event with the same ID from a Tenso instance in a different region
+	state.Region = "qa-de-2"
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000003 in ServiceNow")
+	assert.Equal(t, records[2]["correlation_id"], any("tenso:qa-de-2:43:helm-deployment-to-servicenow.v1:default:change"))
+}
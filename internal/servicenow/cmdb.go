@@ -0,0 +1,257 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapcc/go-bits/logg"
+)
+
+var configurationItemMissCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tenso_servicenow_configuration_item_misses_total",
+		Help: "Number of ServiceNow deliveries for which no configuration item could be resolved.",
+	},
+	[]string{"endpoint"},
+)
+
+func init() {
+	prometheus.MustRegister(configurationItemMissCounter)
+}
+
+// ConfigurationItemKind is an enumeration of the types of objects that can be
+// resolved into configuration items in the ServiceNow CMDB.
+type ConfigurationItemKind string
+
+const (
+	// ConfigurationItemKindHelmRelease refers to a Helm release.
+	// The name is formatted as "<cluster>/<namespace>/<release>".
+	ConfigurationItemKindHelmRelease ConfigurationItemKind = "helm-release"
+	// ConfigurationItemKindCluster refers to a Kubernetes cluster by name.
+	ConfigurationItemKindCluster ConfigurationItemKind = "cluster"
+	// ConfigurationItemKindHost refers to a single server by its FQDN.
+	ConfigurationItemKindHost ConfigurationItemKind = "host"
+	// ConfigurationItemKindBuildingBlock refers to a building block by its ID, e.g. "bb091".
+	ConfigurationItemKindBuildingBlock ConfigurationItemKind = "building-block"
+)
+
+func (k ConfigurationItemKind) isValid() bool {
+	switch k {
+	case ConfigurationItemKindHelmRelease, ConfigurationItemKindCluster,
+		ConfigurationItemKindHost, ConfigurationItemKindBuildingBlock:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConfigurationItemRef identifies an object affected by a Change that may
+// have a configuration item in the ServiceNow CMDB.
+type ConfigurationItemRef struct {
+	Kind ConfigurationItemKind `json:"kind"`
+	Name string                `json:"name"`
+}
+
+// String returns a human-readable representation of this reference.
+func (r ConfigurationItemRef) String() string {
+	return fmt.Sprintf("%s %q", r.Kind, r.Name)
+}
+
+// configurationItemResolver finds the configuration item in the ServiceNow
+// CMDB for an object affected by a change.
+type configurationItemResolver interface {
+	// The result is empty if there is no matching configuration item.
+	ResolveConfigurationItem(ctx context.Context, ref ConfigurationItemRef) (string, error)
+}
+
+// Builds the resolvers for the configuration that is provided in this Client.
+// If both are configured, the mapping file takes precedence over the CMDB lookup.
+func (c *Client) newConfigurationItemResolvers() ([]configurationItemResolver, error) {
+	var result []configurationItemResolver
+	if c.ConfigurationItemMappingPath != "" {
+		m, err := loadConfigurationItemMapping(c.ConfigurationItemMappingPath)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	if len(c.CMDBLookupURLs) > 0 {
+		for kind, tableURL := range c.CMDBLookupURLs {
+			if !kind.isValid() {
+				return nil, fmt.Errorf(`invalid key in "cmdb_lookup_urls": unknown kind of configuration item: %q`, kind)
+			}
+			if tableURL == "" {
+				return nil, fmt.Errorf(`invalid value in "cmdb_lookup_urls": missing URL for %q`, kind)
+			}
+		}
+		l := &cmdbLookup{
+			Client:    c,
+			TableURLs: c.CMDBLookupURLs,
+			CacheTTL:  1 * time.Hour,
+			cache:     make(map[ConfigurationItemRef]cachedConfigurationItem),
+		}
+		if c.CMDBCacheTTL != "" {
+			var err error
+			l.CacheTTL, err = time.ParseDuration(c.CMDBCacheTTL)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid value for "cmdb_cache_ttl": %w`, err)
+			}
+		}
+		result = append(result, l)
+	} else if c.CMDBCacheTTL != "" {
+		return nil, errors.New(`"cmdb_cache_ttl" cannot be set without "cmdb_lookup_urls"`)
+	}
+	return result, nil
+}
+
+// Resolves the first of the given refs that has a configuration item, and
+// puts it into the change record and incident. If none of the refs can be
+// resolved, a note about the miss is returned for the delivery log. Misses are
+// not fatal because the change is still useful without a configuration item.
+func (c *Client) resolveConfigurationItem(ctx context.Context, cp *ChangePayload) (missNote string) {
+	if len(c.ciResolvers) == 0 || len(cp.ConfigurationItemRefs) == 0 {
+		return ""
+	}
+
+	for _, ref := range cp.ConfigurationItemRefs {
+		for _, resolver := range c.ciResolvers {
+			ci, err := resolver.ResolveConfigurationItem(ctx, ref)
+			if err != nil {
+				logg.Error("while resolving configuration item for %s on ServiceNow endpoint %q: %s", ref, c.name, err.Error())
+				continue
+			}
+			if ci != "" {
+				cp.Record["cmdb_ci"] = ci
+				if cp.Incident != nil {
+					cp.Incident["cmdb_ci"] = ci
+				}
+				return ""
+			}
+		}
+	}
+
+	refStrings := make([]string, len(cp.ConfigurationItemRefs))
+	for idx, ref := range cp.ConfigurationItemRefs {
+		refStrings[idx] = ref.String()
+	}
+	configurationItemMissCounter.WithLabelValues(c.name).Inc()
+	logg.Info("no configuration item found on ServiceNow endpoint %q for %s", c.name, strings.Join(refStrings, ", "))
+	return fmt.Sprintf(" (no configuration item found for %s)", strings.Join(refStrings, ", "))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// mapping file
+
+// configurationItemMapping is the structure of the file at Client.ConfigurationItemMappingPath.
+type configurationItemMapping map[ConfigurationItemKind]map[string]string
+
+func loadConfigurationItemMapping(path string) (configurationItemMapping, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load configuration item mapping: %w", err)
+	}
+	var m configurationItemMapping
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing %s: %w", path, err)
+	}
+	for kind := range m {
+		if !kind.isValid() {
+			return nil, fmt.Errorf("while parsing %s: unknown kind of configuration item: %q", path, kind)
+		}
+	}
+	return m, nil
+}
+
+// ResolveConfigurationItem implements the configurationItemResolver interface.
+func (m configurationItemMapping) ResolveConfigurationItem(_ context.Context, ref ConfigurationItemRef) (string, error) {
+	return m[ref.Kind][ref.Name], nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// CMDB lookup
+
+// cmdbLookup finds configuration items by name through the ServiceNow Table
+// API, using the CMDB table that holds the configuration items for each kind
+// of object. Results (including misses) are cached to avoid a CMDB query for
+// each delivery.
+type cmdbLookup struct {
+	Client    *Client
+	TableURLs map[ConfigurationItemKind]string
+	CacheTTL  time.Duration
+
+	mutex sync.Mutex
+	cache map[ConfigurationItemRef]cachedConfigurationItem
+}
+
+type cachedConfigurationItem struct {
+	SysID     string
+	ExpiresAt time.Time
+}
+
+// ResolveConfigurationItem implements the configurationItemResolver interface.
+func (l *cmdbLookup) ResolveConfigurationItem(ctx context.Context, ref ConfigurationItemRef) (string, error) {
+	tableURL := l.TableURLs[ref.Kind]
+	if tableURL == "" {
+		// this kind of object is not looked up on this endpoint
+		return "", nil
+	}
+	// "^" separates conditions in encoded queries, so a name containing it
+	// could add arbitrary conditions to the query
+	if strings.Contains(ref.Name, "^") {
+		return "", fmt.Errorf("cannot look up %s in CMDB: name may not contain \"^\"", ref)
+	}
+
+	l.mutex.Lock()
+	entry, ok := l.cache[ref]
+	l.mutex.Unlock()
+	if ok && time.Now().Before(entry.ExpiresAt) {
+		return entry.SysID, nil
+	}
+
+	u, err := url.Parse(tableURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("sysparm_query", "name="+ref.Name)
+	query.Set("sysparm_fields", "sys_id")
+	query.Set("sysparm_limit", "1")
+	u.RawQuery = query.Encode()
+
+	respBody, err := l.Client.doRequest(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	var respData struct {
+		Result []struct {
+			SysID responseValue `json:"sys_id"`
+		} `json:"result"`
+	}
+	err = json.Unmarshal(respBody, &respData)
+	if err != nil {
+		return "", fmt.Errorf("while parsing CMDB response: %w", err)
+	}
+
+	entry = cachedConfigurationItem{ExpiresAt: time.Now().Add(l.CacheTTL)}
+	if len(respData.Result) > 0 {
+		entry.SysID = respData.Result[0].SysID.Value
+	}
+	l.mutex.Lock()
+	l.cache[ref] = entry
+	l.mutex.Unlock()
+	return entry.SysID, nil
+}
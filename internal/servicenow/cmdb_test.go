@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/servicenowmock"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+func newMockedClient(t *testing.T, s *servicenowmock.Server, extraConfigJSON string) *servicenow.Client {
+	t.Helper()
+	var c servicenow.Client
+	configJSON := `{"url":"https://servicenow.example.com/api/now/table/change_request","username":"tenso","password_path":"fixtures/servicenow-password.txt",` + extraConfigJSON + `}`
+	must.SucceedT(t, json.Unmarshal([]byte(configJSON), &c))
+	must.SucceedT(t, c.Init())
+	return c.OverrideHTTPClient(s.HTTPClient())
+}
+
+func makeChangePayload(t *testing.T, refs ...servicenow.ConfigurationItemRef) []byte {
+	t.Helper()
+	return must.ReturnT(json.Marshal(servicenow.ChangePayload{
+		Action:                servicenow.ChangeActionClose,
+		Outcome:               "succeeded",
+		CreateIfMissing:       true,
+		Record:                map[string]any{"short_description": "Deploy swift"},
+		ConfigurationItemRefs: refs,
+	}))(t)
+}
+
+var (
+	hostRef          = servicenow.ConfigurationItemRef{Kind: servicenow.ConfigurationItemKindHost, Name: "node002-bb091.cc.qa-de-1.cloud.sap"}
+	buildingBlockRef = servicenow.ConfigurationItemRef{Kind: servicenow.ConfigurationItemKindBuildingBlock, Name: "bb091"}
+	helmReleaseRef   = servicenow.ConfigurationItemRef{Kind: servicenow.ConfigurationItemKindHelmRelease, Name: "qa-de-1/swift/swift"}
+	clusterRef       = servicenow.ConfigurationItemRef{Kind: servicenow.ConfigurationItemKindCluster, Name: "qa-de-1"}
+)
+
+func TestConfigurationItemFromMappingFile(t *testing.T) {
+	s := servicenowmock.NewServer()
+	c := newMockedClient(t, s, `"cmdb_ci_mapping_path":"fixtures/cmdb-ci-mapping.json"`)
+
+	// the first ref that is known to the mapping is used
+	for idx, refs := range [][]servicenow.ConfigurationItemRef{{hostRef, buildingBlockRef}, {helmReleaseRef, buildingBlockRef}} {
+		dl := must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", makeChangePayload(t, refs...)))(t)
+		assert.Equal(t, dl.Message, fmt.Sprintf("created CHG%07d in ServiceNow", idx+1))
+	}
+	records := s.Records("change_request")
+	assert.Equal(t, records[0]["cmdb_ci"], "f00dcafe")
+	assert.Equal(t, records[1]["cmdb_ci"], "deadbeef")
+}
+
+func TestConfigurationItemFromCMDBLookup(t *testing.T) {
+	s := servicenowmock.NewServer()
+	s.AddRecord("cmdb_ci_kubernetes_cluster", servicenowmock.Record{"sys_id": "c1", "name": "qa-de-1"})
+	c := newMockedClient(t, s, `"cmdb_lookup_urls":{`+
+		`"cluster":"https://servicenow.example.com/api/now/table/cmdb_ci_kubernetes_cluster",`+
+		`"helm-release":"https://servicenow.example.com/api/now/table/u_cmdb_ci_helm_release",`+
+		`"host":"https://servicenow.example.com/api/now/table/cmdb_ci_server"}`)
+
+	// the Helm release is not in the CMDB, but its cluster is
+	payload := makeChangePayload(t, helmReleaseRef, clusterRef)
+	dl := must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", payload))(t)
+	assert.Equal(t, dl.Message, "created CHG0000001 in ServiceNow")
+	assert.Equal(t, s.Records("change_request")[0]["cmdb_ci"], "c1")
+
+	// lookup results are cached, so a CI that was added in the meantime is not picked up yet
+	s.AddRecord("u_cmdb_ci_helm_release", servicenowmock.Record{"sys_id": "c2", "name": "qa-de-1/swift/swift"})
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", payload))(t)
+	assert.Equal(t, dl.Message, "created CHG0000002 in ServiceNow")
+	assert.Equal(t, s.Records("change_request")[1]["cmdb_ci"], "c1")
+
+	// objects are only looked up in the table for their kind (and building blocks are not looked up at all)
+	s.AddRecord("cmdb_ci_kubernetes_cluster", servicenowmock.Record{"sys_id": "c3", "name": hostRef.Name})
+	s.AddRecord("cmdb_ci_server", servicenowmock.Record{"sys_id": "c4", "name": buildingBlockRef.Name})
+
+	// when nothing can be resolved, the change is created anyway and the miss is reported
+	payload = makeChangePayload(t, hostRef, buildingBlockRef)
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", payload))(t)
+	assert.Equal(t, dl.Message, `created CHG0000003 in ServiceNow (no configuration item found for host "node002-bb091.cc.qa-de-1.cloud.sap", building-block "bb091")`)
+	_, exists := s.Records("change_request")[2]["cmdb_ci"]
+	assert.Equal(t, exists, false)
+
+	// CMDB errors are treated like misses, since the change is more important than its CI
+	s.FailNextRequests(1, 500)
+	payload = makeChangePayload(t, servicenow.ConfigurationItemRef{Kind: servicenow.ConfigurationItemKindCluster, Name: "qa-de-2"})
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", payload))(t)
+	assert.Equal(t, dl.Message, `created CHG0000004 in ServiceNow (no configuration item found for cluster "qa-de-2")`)
+
+	// names that would alter the query are not looked up
+	payload = makeChangePayload(t, servicenow.ConfigurationItemRef{Kind: servicenow.ConfigurationItemKindCluster, Name: "qa-de-2^ORname=qa-de-1"})
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", payload))(t)
+	assert.Equal(t, dl.Message, `created CHG0000005 in ServiceNow (no configuration item found for cluster "qa-de-2^ORname=qa-de-1")`)
+}
@@ -7,9 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 
+	"github.com/sapcc/go-api-declarations/deployevent"
 	"github.com/sapcc/go-bits/osext"
 	"github.com/sapcc/go-bits/regexpext"
+
+	"github.com/sapcc/tenso/internal/topology"
 )
 
 // MappingConfiguration is the structure of the config file at
@@ -24,12 +28,15 @@ type MappingConfiguration struct {
 	AWXWorkflow               MappingRuleset `json:"awx-workflow"`
 	TerraformDeployment       MappingRuleset `json:"terraform-deployment"`
 
-	// datacenter mapping
+	// datacenter mapping (regions that are not listed here are looked up in the Topology)
 	Regions           map[string][]string `json:"regions"`
 	AvailabilityZones map[string]struct {
 		Datacenters []string `json:"datacenters"`
 		Environment string   `json:"environment"`
 	} `json:"availability_zones"`
+
+	// Topology is loaded from $TENSO_TOPOLOGY_CONFIG_PATH.
+	Topology *topology.Topology `json:"-"`
 }
 
 var mappingConfigAtPath = map[string]MappingConfiguration{}
@@ -61,19 +68,59 @@ func LoadMappingConfiguration(envVarName string) (MappingConfiguration, error) {
 	if err != nil {
 		return MappingConfiguration{}, fmt.Errorf("while parsing %s: %w", filePath, err)
 	}
+	result.Topology, err = topology.Load("TENSO_TOPOLOGY_CONFIG_PATH")
+	if err != nil {
+		return MappingConfiguration{}, err
+	}
+	rulesets := map[string]MappingRuleset{
+		"helm-deployment":             result.HelmDeployment,
+		"active-directory-deployment": result.ActiveDirectoryDeployment,
+		"awx-workflow":                result.AWXWorkflow,
+		"terraform-deployment":        result.TerraformDeployment,
+	}
+	for name, ruleset := range rulesets {
+		err = ruleset.validate()
+		if err != nil {
+			return MappingConfiguration{}, fmt.Errorf("while parsing %s: in %s: %w", filePath, name, err)
+		}
+	}
 
 	mappingConfigAtPath[filePath] = result
 	return result, nil
 }
 
+// Returns the AZs of the given region, or false if the region is not known.
+func (cfg MappingConfiguration) availabilityZonesOf(region string) ([]string, bool) {
+	if azNames, ok := cfg.Regions[region]; ok {
+		return azNames, true
+	}
+	if cfg.Topology == nil {
+		return nil, false
+	}
+	return cfg.Topology.Current().AvailabilityZonesOf(region)
+}
+
+// Returns the region containing the given AZ, or false if the AZ is not known.
+func (cfg MappingConfiguration) regionOfAvailabilityZone(az string) (string, bool) {
+	for regionName, azNames := range cfg.Regions {
+		if slices.Contains(azNames, az) {
+			return regionName, true
+		}
+	}
+	if cfg.Topology == nil {
+		return "", false
+	}
+	return cfg.Topology.Current().RegionOfAvailabilityZone(az)
+}
+
 // MappingRuleset is a set of rules for filling missing fields in a Change object.
 type MappingRuleset []MappingRule
 
 // Evaluate returns the sum of all rules in this ruleset that match the given Change object.
 // For each field, the last matching rule takes precedence.
-func (rs MappingRuleset) Evaluate(chg Change, routingInfo map[string]string) (result MappingRule) {
+func (rs MappingRuleset) Evaluate(chg Change, loc Location, routingInfo map[string]string) (result MappingRule) {
 	for _, r := range rs {
-		if !r.matches(chg, routingInfo) {
+		if !r.matches(chg, loc, routingInfo) {
 			continue
 		}
 		if r.ChangeTemplateID != "" {
@@ -91,25 +138,178 @@ func (rs MappingRuleset) Evaluate(chg Change, routingInfo map[string]string) (re
 		if r.Requester != "" {
 			result.Requester = r.Requester
 		}
+		for key, ft := range r.Fields {
+			if result.Fields == nil {
+				result.Fields = make(map[string]FieldTemplate)
+			}
+			result.Fields[key] = ft
+		}
+		for outcome, action := range r.OutcomeActions {
+			if result.OutcomeActions == nil {
+				result.OutcomeActions = make(map[deployevent.Outcome]OutcomeAction)
+			}
+			result.OutcomeActions[outcome] = action
+		}
 	}
 	return result
 }
 
+func (rs MappingRuleset) validate() error {
+	for idx, r := range rs {
+		switch r.MatchEnvironment {
+		case "", "Development", "QA", "Production":
+		default:
+			return fmt.Errorf("rule %d: invalid value for match_environment: %q", idx, r.MatchEnvironment)
+		}
+		err := validateFieldTemplates(r.Fields)
+		if err != nil {
+			return fmt.Errorf("rule %d: %w", idx, err)
+		}
+		for outcome, action := range r.OutcomeActions {
+			if !outcome.IsKnownInputValue() && outcome != deployevent.OutcomePartiallyDeployed {
+				return fmt.Errorf("rule %d: invalid outcome in outcome_actions: %q", idx, outcome)
+			}
+			if !action.isValid() {
+				return fmt.Errorf("rule %d: invalid action in outcome_actions[%q]: %q", idx, outcome, action)
+			}
+		}
+	}
+	return nil
+}
+
 // MappingRule is a rule for filling missing fields in a Change object.
+//
+// All "Match..." fields are optional. A rule only applies to a change if all
+// of its matchers match. Matchers for attributes that a change does not have
+// (e.g. MatchHelmReleaseName for an AWX workflow) never match.
 type MappingRule struct {
 	MatchSummary          regexpext.BoundedRegexp `json:"match_summary"`
 	MatchServiceNowTarget string                  `json:"match_servicenow_target"`
-	ChangeTemplateID      string                  `json:"change_template_id"`
-	Assignee              string                  `json:"assignee"`
-	ResponsibleManager    string                  `json:"responsible_manager"`
-	ServiceOffering       string                  `json:"service_offering"`
-	Requester             string                  `json:"requester"`
+	MatchRegion           regexpext.BoundedRegexp `json:"match_region"`
+	MatchAvailabilityZone regexpext.BoundedRegexp `json:"match_availability_zone"`
+	MatchEnvironment      string                  `json:"match_environment"`
+	MatchPipelineTeam     regexpext.BoundedRegexp `json:"match_pipeline_team"`
+	MatchPipelineName     regexpext.BoundedRegexp `json:"match_pipeline_name"`
+	// The Helm release matchers apply to a change if at least one Helm release
+	// in it matches all of them.
+	MatchHelmReleaseName      regexpext.BoundedRegexp            `json:"match_helm_release_name"`
+	MatchHelmReleaseNamespace regexpext.BoundedRegexp            `json:"match_helm_release_namespace"`
+	MatchHelmReleaseCluster   regexpext.BoundedRegexp            `json:"match_helm_release_cluster"`
+	MatchAWXWorkflowName      regexpext.BoundedRegexp            `json:"match_awx_workflow_name"`
+	MatchRoutingInfo          map[string]regexpext.BoundedRegexp `json:"match_routing_info"`
+
+	ChangeTemplateID   string `json:"change_template_id"`
+	Assignee           string `json:"assignee"`
+	ResponsibleManager string `json:"responsible_manager"`
+	ServiceOffering    string `json:"service_offering"`
+	Requester          string `json:"requester"`
+	// Fields contains templates for fields of the change record in ServiceNow.
+	// These override the values that would otherwise be generated for these
+	// fields, and can also be used to set additional fields.
+	Fields map[string]FieldTemplate `json:"fields"`
+	// OutcomeActions configures what to do in ServiceNow for finished
+	// deployments with the respective outcome. For outcomes not listed here,
+	// DefaultOutcomeAction() applies.
+	OutcomeActions map[deployevent.Outcome]OutcomeAction `json:"outcome_actions"`
 }
 
-func (r MappingRule) matches(chg Change, routingInfo map[string]string) bool {
+// ActionFor returns the action that this rule configures for deployments with
+// the given outcome.
+func (r MappingRule) ActionFor(outcome deployevent.Outcome) OutcomeAction {
+	action, exists := r.OutcomeActions[outcome]
+	if exists {
+		return action
+	}
+	return DefaultOutcomeAction(outcome)
+}
+
+// OutcomeAction is an enumeration of the things that can be done in ServiceNow
+// for a finished deployment.
+type OutcomeAction string
+
+const (
+	// OutcomeActionSkip means that nothing is recorded in ServiceNow.
+	// If a change was opened when the deployment started, it is still closed.
+	OutcomeActionSkip OutcomeAction = "skip"
+	// OutcomeActionCreateChange means that a closed change is created, with a
+	// close code and close notes that reflect the outcome of the deployment.
+	OutcomeActionCreateChange OutcomeAction = "create-change"
+	// OutcomeActionCreateIncident means that an incident is created instead of
+	// a change. If a change was opened when the deployment started, it is also
+	// closed.
+	OutcomeActionCreateIncident OutcomeAction = "create-incident"
+)
+
+// DefaultOutcomeAction returns the action that applies to the given outcome
+// when no mapping rule configures a different action: Only successful
+// deployments are recorded as changes.
+func DefaultOutcomeAction(outcome deployevent.Outcome) OutcomeAction {
+	if outcome == deployevent.OutcomeSucceeded {
+		return OutcomeActionCreateChange
+	}
+	return OutcomeActionSkip
+}
+
+func (a OutcomeAction) isValid() bool {
+	switch a {
+	case OutcomeActionSkip, OutcomeActionCreateChange, OutcomeActionCreateIncident:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r MappingRule) matches(chg Change, loc Location, routingInfo map[string]string) bool {
 	if (r.MatchServiceNowTarget != "" && r.MatchServiceNowTarget != routingInfo["servicenow-target"]) ||
 		r.MatchSummary != "" && !r.MatchSummary.MatchString(chg.Summary) {
 		return false
 	}
+	if !matchesOptional(r.MatchRegion, loc.Region) ||
+		!matchesAny(r.MatchAvailabilityZone, loc.AvailabilityZones) ||
+		(r.MatchEnvironment != "" && r.MatchEnvironment != loc.Environment) ||
+		!matchesOptional(r.MatchAWXWorkflowName, chg.AWXWorkflowName) {
+		return false
+	}
+	if r.MatchPipelineTeam != "" || r.MatchPipelineName != "" {
+		if chg.Pipeline == nil ||
+			!matchesOptional(r.MatchPipelineTeam, chg.Pipeline.TeamName) ||
+			!matchesOptional(r.MatchPipelineName, chg.Pipeline.PipelineName) {
+			return false
+		}
+	}
+	if r.MatchHelmReleaseName != "" || r.MatchHelmReleaseNamespace != "" || r.MatchHelmReleaseCluster != "" {
+		if !slices.ContainsFunc(chg.HelmReleases, r.matchesHelmRelease) {
+			return false
+		}
+	}
+	for key, rx := range r.MatchRoutingInfo {
+		if !matchesOptional(rx, routingInfo[key]) {
+			return false
+		}
+	}
 	return true
 }
+
+func (r MappingRule) matchesHelmRelease(rel *deployevent.HelmRelease) bool {
+	return rel != nil &&
+		matchesOptional(r.MatchHelmReleaseName, rel.Name) &&
+		matchesOptional(r.MatchHelmReleaseNamespace, rel.Namespace) &&
+		matchesOptional(r.MatchHelmReleaseCluster, rel.Cluster)
+}
+
+// Returns whether the given value matches the given regex. An empty regex
+// matches everything, but a non-empty regex never matches an empty value.
+func matchesOptional(rx regexpext.BoundedRegexp, value string) bool {
+	if rx == "" {
+		return true
+	}
+	return value != "" && rx.MatchString(value)
+}
+
+// Like matchesOptional, but matches if any of the values matches.
+func matchesAny(rx regexpext.BoundedRegexp, values []string) bool {
+	if rx == "" {
+		return true
+	}
+	return slices.ContainsFunc(values, func(value string) bool { return matchesOptional(rx, value) })
+}
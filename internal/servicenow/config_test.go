@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sapcc/go-api-declarations/deployevent"
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+)
+
+const testRulesetJSON = `[
+	{ "change_template_id": "default" },
+	{ "match_region": "qa-de-.*", "change_template_id": "qa-region" },
+	{ "match_availability_zone": "qa-de-1b", "change_template_id": "qa-de-1b" },
+	{ "match_environment": "Production", "change_template_id": "production" },
+	{ "match_pipeline_team": "services", "match_pipeline_name": "swift", "change_template_id": "swift-pipeline" },
+	{ "match_helm_release_name": "swift-proxy", "match_helm_release_cluster": "s-qa-de-1", "change_template_id": "swift-proxy" },
+	{ "match_helm_release_namespace": "kube-system", "change_template_id": "kube-system" },
+	{ "match_awx_workflow_name": "upgrade-.*", "change_template_id": "awx-upgrade" },
+	{ "match_routing_info": { "priority": "high|urgent" }, "change_template_id": "prioritized" }
+]`
+
+func TestMappingRulesetEvaluate(t *testing.T) {
+	var ruleset servicenow.MappingRuleset
+	must.SucceedT(t, json.Unmarshal([]byte(testRulesetJSON), &ruleset))
+
+	qaDE1 := servicenow.Location{
+		Region:            "qa-de-1",
+		AvailabilityZones: []string{"qa-de-1a", "qa-de-1b"},
+		Environment:       "Development",
+	}
+	euDE1 := servicenow.Location{
+		Region:            "eu-de-1",
+		AvailabilityZones: []string{"eu-de-1a"},
+		Environment:       "Production",
+	}
+	swiftPipeline := &deployevent.Pipeline{TeamName: "services", PipelineName: "swift"}
+
+	testCases := []struct {
+		Change      servicenow.Change
+		Location    servicenow.Location
+		RoutingInfo map[string]string
+		Expected    string
+	}{
+		// no matchers apply except for the fallback rule
+		{servicenow.Change{}, servicenow.Location{}, nil, "default"},
+		// region matcher applies (but AZ matcher does not, since it requires qa-de-1b)
+		{servicenow.Change{}, servicenow.Location{Region: "qa-de-2", AvailabilityZones: []string{"qa-de-2a"}}, nil, "qa-region"},
+		// AZ matcher applies if any AZ of the region matches
+		{servicenow.Change{}, qaDE1, nil, "qa-de-1b"},
+		{servicenow.Change{}, euDE1, nil, "production"},
+		// pipeline matchers need to match together
+		{servicenow.Change{Pipeline: swiftPipeline}, euDE1, nil, "swift-pipeline"},
+		{servicenow.Change{Pipeline: &deployevent.Pipeline{TeamName: "services", PipelineName: "keystone"}}, euDE1, nil, "production"},
+		// Helm release matchers need to match on the same release
+		{servicenow.Change{HelmReleases: []*deployevent.HelmRelease{
+			{Name: "swift-proxy", Cluster: "s-eu-de-1"},
+			{Name: "swift-account", Cluster: "s-qa-de-1"},
+		}}, euDE1, nil, "production"},
+		{servicenow.Change{HelmReleases: []*deployevent.HelmRelease{
+			{Name: "swift-account", Cluster: "s-qa-de-1"},
+			{Name: "swift-proxy", Cluster: "s-qa-de-1"},
+		}}, euDE1, nil, "swift-proxy"},
+		{servicenow.Change{HelmReleases: []*deployevent.HelmRelease{
+			{Name: "coredns", Namespace: "kube-system", Cluster: "s-qa-de-1"},
+		}}, euDE1, nil, "kube-system"},
+		// AWX workflow name matcher
+		{servicenow.Change{AWXWorkflowName: "upgrade-firmware"}, euDE1, nil, "awx-upgrade"},
+		{servicenow.Change{AWXWorkflowName: "reboot"}, euDE1, nil, "production"},
+		// routing info matcher
+		{servicenow.Change{}, euDE1, map[string]string{"priority": "urgent"}, "prioritized"},
+		{servicenow.Change{}, euDE1, map[string]string{"priority": "low"}, "production"},
+		{servicenow.Change{}, euDE1, map[string]string{"servicenow-target": "urgent"}, "production"},
+	}
+
+	for idx, tc := range testCases {
+		rule := ruleset.Evaluate(tc.Change, tc.Location, tc.RoutingInfo)
+		if rule.ChangeTemplateID != tc.Expected {
+			t.Errorf("in test case %d: expected rule %q to apply, but got %q", idx, tc.Expected, rule.ChangeTemplateID)
+		}
+	}
+}
+
+func TestMappingRulesetValidation(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/invalid-environment.json")
+	_, err := servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
+	assert.ErrEqual(t, err, `while parsing fixtures/invalid-environment.json: in helm-deployment: rule 1: invalid value for match_environment: "Staging"`)
+}
+
+func TestMappingRulesetTemplateValidation(t *testing.T) {
+	t.Setenv("TENSO_SERVICENOW_MAPPING_CONFIG_PATH", "fixtures/invalid-template.json")
+	_, err := servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH")
+	assert.ErrEqual(t, err, `while parsing fixtures/invalid-template.json: in helm-deployment: rule 0: invalid template for field "u_pipeline": template: :1:12: executing "" at <.Pipeline.Team>: can't evaluate field Team in type deployevent.Pipeline`)
+}
@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sapcc/go-api-declarations/deployevent"
+)
+
+// TemplateContext is the data that a FieldTemplate is executed with.
+// Its fields are documented in the README.
+type TemplateContext struct {
+	Summary     string
+	Description string
+	// Outcome is empty for deployments that have only started.
+	Outcome deployevent.Outcome
+	// StartedAt is always set, EndedAt is nil for deployments that have only started.
+	StartedAt *time.Time
+	EndedAt   *time.Time
+
+	Region            string
+	AvailabilityZones []string
+	Datacenters       []string
+	Environment       string
+
+	// Pipeline is zero-valued if the change did not come from Concourse.
+	Pipeline        deployevent.Pipeline
+	HelmReleases    []*deployevent.HelmRelease
+	GitRepos        map[string]deployevent.GitRepo
+	AWXWorkflowName string
+	RoutingInfo     map[string]string
+}
+
+func newTemplateContext(chg Change, loc Location, routingInfo map[string]string) TemplateContext {
+	ctx := TemplateContext{
+		Summary:           chg.Summary,
+		Description:       chg.Description,
+		Outcome:           chg.Outcome,
+		StartedAt:         chg.StartedAt,
+		EndedAt:           chg.EndedAt,
+		Region:            loc.Region,
+		AvailabilityZones: loc.AvailabilityZones,
+		Datacenters:       loc.Datacenters,
+		Environment:       loc.Environment,
+		HelmReleases:      chg.HelmReleases,
+		GitRepos:          chg.GitRepos,
+		AWXWorkflowName:   chg.AWXWorkflowName,
+		RoutingInfo:       routingInfo,
+	}
+	if chg.Pipeline != nil {
+		ctx.Pipeline = *chg.Pipeline
+	}
+	return ctx
+}
+
+// This context is used to check templates for errors when the mapping
+// configuration is loaded. All pointers and lists are filled, so that mistakes
+// like misspelled field names are found in all branches of the template that
+// do not depend on specific values.
+func sampleTemplateContext() TemplateContext {
+	now := time.Now()
+	return TemplateContext{
+		Summary:           "Deploy example",
+		Description:       "Deployed example",
+		Outcome:           deployevent.OutcomeSucceeded,
+		StartedAt:         &now,
+		EndedAt:           &now,
+		Region:            "qa-de-1",
+		AvailabilityZones: []string{"qa-de-1a"},
+		Datacenters:       []string{"Example 1"},
+		Environment:       "QA",
+		Pipeline:          deployevent.Pipeline{TeamName: "example", PipelineName: "example", JobName: "example"},
+		HelmReleases:      []*deployevent.HelmRelease{{Name: "example", Outcome: deployevent.OutcomeSucceeded}},
+		GitRepos:          map[string]deployevent.GitRepo{"example.git": {CommitID: "0000000000000000000000000000000000000000"}},
+		AWXWorkflowName:   "example",
+		RoutingInfo:       map[string]string{"servicenow-target": "default"},
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// FieldTemplate is a Go text/template that computes the value of a field in a
+// ServiceNow record from a TemplateContext. It appears in the mapping
+// configuration as a string.
+type FieldTemplate struct {
+	tpl *template.Template
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (ft *FieldTemplate) UnmarshalJSON(buf []byte) error {
+	var source string
+	err := json.Unmarshal(buf, &source)
+	if err != nil {
+		return err
+	}
+	tpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return err
+	}
+	*ft = FieldTemplate{tpl}
+	return nil
+}
+
+// Execute renders this template with the given context.
+func (ft FieldTemplate) Execute(ctx TemplateContext) (string, error) {
+	if ft.tpl == nil {
+		return "", nil
+	}
+	var sb strings.Builder
+	err := ft.tpl.Execute(&sb, ctx)
+	return sb.String(), err
+}
+
+func validateFieldTemplates(fields map[string]FieldTemplate) error {
+	ctx := sampleTemplateContext()
+	for key, ft := range fields {
+		_, err := ft.Execute(ctx)
+		if err != nil {
+			return fmt.Errorf("invalid template for field %q: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,444 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package servicenowmock provides a mock of the parts of the ServiceNow Table
+// API that Tenso uses. It is used in unit tests and in the "dev-servicenow"
+// mode of Tenso.
+package servicenowmock
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a record in one of the tables of the mock.
+// All field values are strings, like in the real ServiceNow.
+type Record map[string]string
+
+// Server is a mock of the ServiceNow Table API. It implements http.Handler.
+//
+// The following endpoints are supported:
+//
+//	POST  /api/now/table/{table}           -- create a record
+//	GET   /api/now/table/{table}           -- query records (sysparm_query, sysparm_fields, sysparm_limit)
+//	GET   /api/now/table/{table}/{sys_id}  -- show a record
+//	PATCH /api/now/table/{table}/{sys_id}  -- update a record
+//	POST  /oauth_token.do                  -- obtain a token with OAuth2 client credentials
+//
+// For local development, there are also some endpoints to control the mock:
+//
+//	GET   /_mock/tables/{table}            -- list all records in a table
+//	POST  /_mock/failures?count=N&status=S -- make the next N requests fail with status code S
+type Server struct {
+	// If set, requests must use basic auth with these credentials.
+	Username string
+	Password string
+	// If set, tokens for bearer authentication can be obtained from the OAuth
+	// endpoint with these client credentials, and requests must use such tokens.
+	OAuthClientID     string
+	OAuthClientSecret string
+	// If set, requests must be made over TLS with a client certificate. The
+	// certificate is verified by the TLS config (see TLSConfig()), not here.
+	RequireClientCert bool
+
+	mux      *http.ServeMux
+	mutex    sync.Mutex
+	tables   map[string][]Record
+	counters map[string]int // for numbering records in each table
+	tokens   map[string]bool
+	failures []int // status codes for upcoming requests
+	latency  time.Duration
+}
+
+// NewServer creates an empty Server.
+func NewServer() *Server {
+	s := &Server{
+		tables:   make(map[string][]Record),
+		counters: make(map[string]int),
+		tokens:   make(map[string]bool),
+	}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("POST /api/now/table/{table}", s.handleCreate)
+	s.mux.HandleFunc("GET /api/now/table/{table}", s.handleQuery)
+	s.mux.HandleFunc("GET /api/now/table/{table}/{sys_id}", s.handleShow)
+	s.mux.HandleFunc("PATCH /api/now/table/{table}/{sys_id}", s.handleUpdate)
+	s.mux.HandleFunc("POST /oauth_token.do", s.handleOAuthToken)
+	s.mux.HandleFunc("GET /_mock/tables/{table}", s.handleListTable)
+	s.mux.HandleFunc("POST /_mock/failures", s.handlePostFailures)
+	return s
+}
+
+// SetLatency configures a delay that is added to each request.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency = latency
+}
+
+// FailNextRequests makes the next `count` requests (except for requests to
+// the /_mock endpoints) fail with the given status code.
+func (s *Server) FailNextRequests(count, statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for range count {
+		s.failures = append(s.failures, statusCode)
+	}
+}
+
+// AddRecord adds a record to the given table, e.g. to prepare CMDB entries
+// that Tenso can look up. The sys_id and number fields are filled if missing.
+// The stored record is returned.
+func (s *Server) AddRecord(table string, record Record) Record {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addRecord(table, record)
+}
+
+// Records returns a copy of all records in the given table, in the order in which they were created.
+func (s *Server) Records(table string) []Record {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	result := make([]Record, len(s.tables[table]))
+	for idx, record := range s.tables[table] {
+		result[idx] = maps.Clone(record)
+	}
+	return result
+}
+
+// HTTPClient returns a client that delivers all requests directly into this
+// Server (regardless of the host name in the request URL) without going
+// through the network. This can be given to servicenow.Client.OverrideHTTPClient().
+func (s *Server) HTTPClient() *http.Client {
+	return &http.Client{Transport: roundTripper{s}}
+}
+
+type roundTripper struct {
+	s *Server
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	rt.s.ServeHTTP(rec, req)
+	// like a real transport, report cancellation instead of a partial response
+	err := req.Context().Err()
+	if err != nil {
+		return nil, err
+	}
+	return rec.Result(), nil
+}
+
+// TLSConfig returns a TLS config for serving this mock with the given server
+// certificate. If clientCAs is not nil, client certificates are verified
+// against it. Otherwise, if s.RequireClientCert is set, any client certificate
+// is accepted.
+func (s *Server) TLSConfig(serverCert tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	switch {
+	case clientCAs != nil:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = clientCAs
+	case s.RequireClientCert:
+		cfg.ClientAuth = tls.RequireAnyClientCert
+	}
+	return cfg
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/_mock/") {
+		s.mutex.Lock()
+		latency := s.latency
+		failStatus := 0
+		if len(s.failures) > 0 {
+			failStatus = s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mutex.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if failStatus != 0 {
+			respondWithError(w, failStatus, "Injected failure", "this failure was requested through FailNextRequests()")
+			return
+		}
+		if r.URL.Path != "/oauth_token.do" && !s.isAuthorized(r) {
+			respondWithError(w, http.StatusUnauthorized, "User Not Authenticated", "Required to provide Auth information")
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) isAuthorized(r *http.Request) bool {
+	if s.RequireClientCert && (r.TLS == nil || len(r.TLS.PeerCertificates) == 0) {
+		return false
+	}
+	if s.Username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.Username || password != s.Password {
+			return false
+		}
+	}
+	if s.OAuthClientID != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if !ok || !s.tokens[token] {
+			return false
+		}
+	}
+	return true
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Table API
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var fields map[string]any
+	err := json.NewDecoder(r.Body).Decode(&fields)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+	record := make(Record, len(fields))
+	for key, value := range fields {
+		if str, ok := value.(string); ok {
+			record[key] = str
+		} else {
+			record[key] = fmt.Sprint(value)
+		}
+	}
+	// these fields are managed by ServiceNow
+	delete(record, "sys_id")
+	delete(record, "number")
+
+	s.mutex.Lock()
+	record = s.addRecord(r.PathValue("table"), record)
+	s.mutex.Unlock()
+	respondWithJSON(w, http.StatusCreated, map[string]any{"result": record})
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	conditions := make(map[string]string)
+	if sysparmQuery := query.Get("sysparm_query"); sysparmQuery != "" {
+		for condition := range strings.SplitSeq(sysparmQuery, "^") {
+			key, value, ok := strings.Cut(condition, "=")
+			if !ok {
+				respondWithError(w, http.StatusBadRequest, "Invalid query", fmt.Sprintf("unsupported condition: %q", condition))
+				return
+			}
+			conditions[key] = value
+		}
+	}
+	limit := -1
+	if sysparmLimit := query.Get("sysparm_limit"); sysparmLimit != "" {
+		var err error
+		limit, err = strconv.Atoi(sysparmLimit)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid query", "invalid value for sysparm_limit")
+			return
+		}
+	}
+	var fields []string
+	if sysparmFields := query.Get("sysparm_fields"); sysparmFields != "" {
+		fields = strings.Split(sysparmFields, ",")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	result := []Record{}
+	for _, record := range s.tables[r.PathValue("table")] {
+		if limit >= 0 && len(result) >= limit {
+			break
+		}
+		matches := true
+		for key, value := range conditions {
+			if record[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, selectFields(record, fields))
+		}
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{"result": result})
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record := s.findRecord(r.PathValue("table"), r.PathValue("sys_id"))
+	if record == nil {
+		respondWithError(w, http.StatusNotFound, "No Record found", "Record doesn't exist or ACL restricts the record retrieval")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{"result": record})
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	var fields map[string]any
+	err := json.NewDecoder(r.Body).Decode(&fields)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Exception while reading request", err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	record := s.findRecord(r.PathValue("table"), r.PathValue("sys_id"))
+	if record == nil {
+		respondWithError(w, http.StatusNotFound, "No Record found", "Record doesn't exist or ACL restricts the record retrieval")
+		return
+	}
+	for key, value := range fields {
+		if key == "sys_id" || key == "number" {
+			continue
+		}
+		if str, ok := value.(string); ok {
+			record[key] = str
+		} else {
+			record[key] = fmt.Sprint(value)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, map[string]any{"result": record})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// OAuth
+
+func (s *Server) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.OAuthClientID == "" || r.PostForm.Get("grant_type") != "client_credentials" ||
+		r.PostForm.Get("client_id") != s.OAuthClientID || r.PostForm.Get("client_secret") != s.OAuthClientSecret {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]any{"error": "access_denied", "error_description": "access_denied"})
+		return
+	}
+
+	token := randomHex(16)
+	s.mutex.Lock()
+	s.tokens[token] = true
+	s.mutex.Unlock()
+	respondWithJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   1799,
+		"scope":        "useraccount",
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// control endpoints
+
+func (s *Server) handleListTable(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]any{"result": s.Records(r.PathValue("table"))})
+}
+
+func (s *Server) handlePostFailures(w http.ResponseWriter, r *http.Request) {
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 0 {
+		http.Error(w, "invalid value for query parameter \"count\"", http.StatusBadRequest)
+		return
+	}
+	status, err := strconv.Atoi(r.URL.Query().Get("status"))
+	if err != nil || status < 400 || status > 599 {
+		http.Error(w, "invalid value for query parameter \"status\"", http.StatusBadRequest)
+		return
+	}
+	s.FailNextRequests(count, status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// helper functions
+
+// numberPrefixes contains the prefixes for the "number" field in the tables that have one.
+var numberPrefixes = map[string]string{
+	"change_request": "CHG",
+	"incident":       "INC",
+}
+
+// The caller must hold s.mutex.
+func (s *Server) addRecord(table string, record Record) Record {
+	record = maps.Clone(record)
+	s.counters[table]++
+	if record["sys_id"] == "" {
+		record["sys_id"] = randomHex(16)
+	}
+	if prefix, ok := numberPrefixes[table]; ok && record["number"] == "" {
+		record["number"] = fmt.Sprintf("%s%07d", prefix, s.counters[table])
+	}
+	if record["sys_created_on"] == "" {
+		record["sys_created_on"] = time.Now().UTC().Format(time.DateTime)
+	}
+	s.tables[table] = append(s.tables[table], record)
+	return maps.Clone(record)
+}
+
+// The caller must hold s.mutex.
+func (s *Server) findRecord(table, sysID string) Record {
+	idx := slices.IndexFunc(s.tables[table], func(r Record) bool { return r["sys_id"] == sysID })
+	if idx < 0 {
+		return nil
+	}
+	return s.tables[table][idx]
+}
+
+func selectFields(record Record, fields []string) Record {
+	if len(fields) == 0 {
+		return maps.Clone(record)
+	}
+	result := make(Record, len(fields))
+	for _, field := range fields {
+		if value, ok := record[field]; ok {
+			result[field] = value
+		}
+	}
+	return result
+}
+
+func randomHex(byteCount int) string {
+	buf := make([]byte, byteCount)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func respondWithJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// Renders an error in the same format as the actual ServiceNow API.
+func respondWithError(w http.ResponseWriter, status int, message, detail string) {
+	respondWithJSON(w, status, map[string]any{
+		"error":  map[string]string{"message": message, "detail": detail},
+		"status": "failure",
+	})
+}
@@ -0,0 +1,222 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package servicenowmock_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/servicenowmock"
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+const testChangePayload = `{"action":"close","outcome":"succeeded","create_if_missing":true,"record":{"short_description":"Deploy swift"}}`
+
+// Builds a servicenow.Client that talks to the given mock with the given auth config.
+func newClient(t *testing.T, s *servicenowmock.Server, authConfigJSON string) *servicenow.Client {
+	t.Helper()
+	secretPath := filepath.Join(t.TempDir(), "secret")
+	must.SucceedT(t, os.WriteFile(secretPath, []byte("swordfish"), 0o600))
+
+	var c servicenow.Client
+	configJSON := fmt.Sprintf(`{"url":"https://servicenow.example.com/api/now/table/change_request",%s}`,
+		fmt.Sprintf(authConfigJSON, secretPath))
+	must.SucceedT(t, json.Unmarshal([]byte(configJSON), &c))
+	must.SucceedT(t, c.Init())
+	return c.OverrideHTTPClient(s.HTTPClient())
+}
+
+func TestMockWithBasicAuth(t *testing.T) {
+	s := servicenowmock.NewServer()
+	s.Username = "tenso"
+	s.Password = "swordfish"
+	c := newClient(t, s, `"username":"tenso","password_path":%q`)
+
+	// a change is created with the correlation ID identifying the delivery
+	state := tenso.DeliveryState{EventID: 42, PayloadType: "helm-deployment-to-servicenow.v1", Region: "qa-de-1"}
+	dl := must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000001 in ServiceNow")
+	records := s.Records("change_request")
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, records[0]["short_description"], "Deploy swift")
+	assert.Equal(t, records[0]["correlation_id"], "tenso:qa-de-1:42:helm-deployment-to-servicenow.v1:default:change")
+	assert.Equal(t, dl.ObjectIDs["sys_id"], records[0]["sys_id"])
+
+	// a retry of the same delivery finds the existing change through the correlation ID
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "found previously created CHG0000001 in ServiceNow")
+	assert.Equal(t, len(s.Records("change_request")), 1)
+
+	// injected failures are reported as non-permanent errors
+	s.FailNextRequests(1, http.StatusServiceUnavailable)
+	state.EventID = 43
+	_, err := c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload))
+	if err == nil {
+		t.Fatal("expected delivery to fail because of injected failure")
+	}
+	assert.Equal(t, tenso.IsPermanent(err), false)
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000002 in ServiceNow")
+
+	// failures while looking for an existing record are non-permanent even for 4xx statuses,
+	// since the record itself has not been rejected
+	s.FailNextRequests(1, http.StatusBadRequest)
+	state.EventID = 44
+	_, err = c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload))
+	if err == nil {
+		t.Fatal("expected delivery to fail because of injected failure")
+	}
+	assert.Equal(t, tenso.IsPermanent(err), false)
+	dl = must.ReturnT(c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000003 in ServiceNow")
+
+	// wrong credentials are rejected
+	s.Password = "hunter2"
+	_, err = c.DeliverChangePayload(t.Context(), state, "default", []byte(testChangePayload))
+	if err == nil {
+		t.Fatal("expected delivery with wrong credentials to fail")
+	}
+	assert.Equal(t, tenso.IsPermanent(err), false)
+}
+
+func TestMockWithOAuthClientCredentials(t *testing.T) {
+	s := servicenowmock.NewServer()
+	s.OAuthClientID = "tenso"
+	s.OAuthClientSecret = "swordfish"
+	c := newClient(t, s, `"oauth_token_url":"https://servicenow.example.com/oauth_token.do","oauth_client_id":"tenso","oauth_client_secret_path":%q`)
+
+	dl := must.ReturnT(c.DeliverChangePayload(t.Context(), tenso.DeliveryState{}, "default", []byte(testChangePayload)))(t)
+	assert.Equal(t, dl.Message, "created CHG0000001 in ServiceNow")
+
+	// requests without a token are rejected
+	resp := must.ReturnT(http.Post(newHTTPServer(t, s).URL+"/api/now/table/change_request", "application/json", nil))(t)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusUnauthorized)
+}
+
+func TestMockLatency(t *testing.T) {
+	s := servicenowmock.NewServer()
+	s.SetLatency(time.Hour)
+	c := newClient(t, s, `"username":"tenso","password_path":%q`)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.DeliverChangePayload(ctx, tenso.DeliveryState{}, "default", []byte(testChangePayload))
+	if err == nil {
+		t.Fatal("expected delivery to time out")
+	}
+	assert.Equal(t, len(s.Records("change_request")), 0)
+}
+
+func TestMockControlEndpoints(t *testing.T) {
+	s := servicenowmock.NewServer()
+	s.AddRecord("cmdb_ci", servicenowmock.Record{"sys_id": "ci1", "name": "swift"})
+	srv := newHTTPServer(t, s)
+
+	// tables can be inspected without authentication
+	resp := must.ReturnT(http.Get(srv.URL + "/_mock/tables/cmdb_ci"))(t)
+	var data struct {
+		Result []servicenowmock.Record `json:"result"`
+	}
+	must.SucceedT(t, json.NewDecoder(resp.Body).Decode(&data))
+	resp.Body.Close()
+	assert.Equal(t, len(data.Result), 1)
+	assert.Equal(t, data.Result[0]["name"], "swift")
+
+	// failures can be injected
+	resp = must.ReturnT(http.Post(srv.URL+"/_mock/failures?count=2&status=502", "", nil))(t)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNoContent)
+	for _, expectedStatus := range []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK} {
+		resp = must.ReturnT(http.Get(srv.URL + "/api/now/table/cmdb_ci?sysparm_query=name=swift"))(t)
+		resp.Body.Close()
+		assert.Equal(t, resp.StatusCode, expectedStatus)
+	}
+}
+
+func TestMockWithClientCert(t *testing.T) {
+	s := servicenowmock.NewServer()
+	s.RequireClientCert = true
+	s.AddRecord("change_request", servicenowmock.Record{"sys_id": "abc"})
+
+	serverCert := newCertificate(t, x509.ExtKeyUsageServerAuth)
+	clientCert := newCertificate(t, x509.ExtKeyUsageClientAuth)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert.Leaf)
+
+	srv := httptest.NewUnstartedServer(s)
+	srv.TLS = s.TLSConfig(serverCert, clientCAs)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCert.Leaf)
+	newHTTPClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: certs,
+			MinVersion:   tls.VersionTLS12,
+		}}}
+	}
+
+	// without a client certificate, the request is rejected during the TLS handshake
+	_, err := newHTTPClient().Get(srv.URL + "/api/now/table/change_request/abc")
+	if err == nil {
+		t.Fatal("expected request without client certificate to fail")
+	}
+
+	// with a client certificate, the request goes through
+	resp := must.ReturnT(newHTTPClient(clientCert).Get(srv.URL + "/api/now/table/change_request/abc"))(t)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+}
+
+// Generates a self-signed certificate for 127.0.0.1.
+func newCertificate(t *testing.T, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key := must.ReturnT(ecdsa.GenerateKey(elliptic.P256(), rand.Reader))(t)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "servicenowmock"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER := must.ReturnT(x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key))(t)
+	return tls.Certificate{
+		Certificate: [][]byte{certDER},
+		PrivateKey:  key,
+		Leaf:        must.ReturnT(x509.ParseCertificate(certDER))(t),
+	}
+}
+
+func newHTTPServer(t *testing.T, s *servicenowmock.Server) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return srv
+}
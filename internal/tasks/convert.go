@@ -6,6 +6,7 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,7 +22,7 @@ import (
 // WARNING: This must be run in a transaction, or else `FOR UPDATE SKIP LOCKED`
 // will not work as expected.
 var selectNextConversionQuery = tenso.PendingDeliveryStore.MustPrepareSelectQueryWhere(sqlext.SimplifyWhitespace(`
-	converted_at IS NULL AND next_conversion_at <= $1
+	converted_at IS NULL AND failed_at IS NULL AND next_conversion_at <= $1
 	ORDER BY next_conversion_at ASC, payload_type ASC   -- secondary order ensures deterministic behavior during test
 	LIMIT 1 FOR UPDATE SKIP LOCKED
 `))
@@ -52,14 +53,17 @@ func (c *Context) ConversionJob(registerer prometheus.Registerer) jobloop.Job {
 }
 
 func (c *Context) processConversion(ctx context.Context, tx *gsql.Tx, pd tenso.PendingDelivery, labels prometheus.Labels) (returnedError error) {
-	var event tenso.Event
+	var (
+		event   tenso.Event
+		skipped bool // if true, skipDelivery() takes care of logging
+	)
 
 	labels["target_payload_type"] = pd.PayloadType
 
 	defer func() {
-		if returnedError == nil {
+		if returnedError == nil && !skipped {
 			logg.Info("converted payload for event %d (%q) into %s", pd.EventID, event.Description, pd.PayloadType)
-		} else {
+		} else if returnedError != nil {
 			returnedError = fmt.Errorf("while trying to convert payload for event %d (%q) into %s: %w", pd.EventID, event.Description, pd.PayloadType, returnedError)
 		}
 	}()
@@ -81,32 +85,45 @@ func (c *Context) processConversion(ctx context.Context, tx *gsql.Tx, pd tenso.P
 
 	labels["source_payload_type"] = event.PayloadType
 
-	// find the translation handler
-	var th tenso.TranslationHandler
+	// find the translation chain
+	var chain tenso.TranslationChain
 	for _, route := range c.Config.EnabledRoutes {
 		if route.SourcePayloadType == event.PayloadType && route.TargetPayloadType == pd.PayloadType {
-			th = route.TranslationHandler
+			chain = route.TranslationChain
 			break
 		}
 	}
-	if th == nil {
+	if chain == nil {
 		return fmt.Errorf("no TranslationHandler found for %s -> %s (was this route disabled recently?)",
 			event.PayloadType, pd.PayloadType)
 	}
 
-	// try to translate the payload, or set up a delayed retry on failure
-	targetPayloadBytes, err := th.TranslatePayload([]byte(event.Payload), routingInfo)
+	// try to translate the payload through all steps of the chain, or set up a
+	// delayed retry on failure (or give up entirely if the failure is permanent)
+	targetPayloadBytes, err := chain.TranslatePayload([]byte(event.Payload), routingInfo)
+	if skip, ok := errors.AsType[tenso.SkipDeliveryError](err); ok {
+		skipped = true
+		return c.skipDelivery(ctx, tx, pd, event, skip)
+	}
 	if err != nil {
-		pd.NextConversionAt = c.timeNow().Add(ConversionRetryInterval)
 		pd.FailedConversionCount++
+		msg := "translation failed"
+		if tenso.IsPermanent(err) {
+			now := c.timeNow()
+			pd.FailedAt = &now
+			pd.FailureMessage = err.Error()
+			msg = "translation failed permanently"
+		} else {
+			pd.NextConversionAt = c.timeNow().Add(ConversionRetryInterval)
+		}
 		err2 := tenso.PendingDeliveryStore.Update(ctx, tx, pd)
 		if err2 == nil {
 			err2 = tx.Commit()
 		}
 		if err2 != nil {
-			return fmt.Errorf("translation failed: %w (additional error during DB update: %s)", err, err2.Error())
+			return fmt.Errorf("%s: %w (additional error during DB update: %s)", msg, err, err2.Error())
 		}
-		return fmt.Errorf("translation failed: %w", err)
+		return fmt.Errorf("%s: %w", msg, err)
 	}
 
 	// store the translated payload
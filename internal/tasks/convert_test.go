@@ -98,3 +98,115 @@ func TestConversionCommon(t *testing.T) {
 	assert.ErrEqual(t, conversionJob.ProcessOne(s.Ctx), sql.ErrNoRows.Error())
 	tr.DBChanges().AssertEmpty()
 }
+
+func TestConversionPermanentFailure(t *testing.T) {
+	ctx := t.Context()
+	s := test.NewSetup(t,
+		test.WithTaskContext,
+		test.WithRoute("test-foo.v1 -> test-bar.v1"),
+	)
+
+	// set up an event that cannot be translated (negative values are rejected by the test translator)
+	s.Clock.StepBy(1 * time.Hour)
+	user := tenso.User{
+		Name:       "testusername",
+		UUID:       "testuserid",
+		DomainName: "testdomainname",
+	}
+	must.SucceedT(t, tenso.UserStore.Insert(ctx, s.DB, &user))
+	event := tenso.Event{
+		CreatorID:   user.ID,
+		CreatedAt:   s.Clock.Now(),
+		PayloadType: "test-foo.v1",
+		Payload:     `{"event":"foo","value":-1}`,
+		Description: "foo event with value -1",
+	}
+	must.SucceedT(t, tenso.EventStore.Insert(ctx, s.DB, &event))
+	must.SucceedT(t, tenso.PendingDeliveryStore.Insert(ctx, s.DB, &tenso.PendingDelivery{
+		EventID:          event.ID,
+		PayloadType:      "test-bar.v1",
+		NextConversionAt: s.Clock.Now(),
+		NextDeliveryAt:   s.Clock.Now(),
+	}))
+
+	tr, _ := easypg.NewTracker(t, s.DB.DB)
+	conversionJob := s.TaskContext.ConversionJob(s.Registry)
+
+	// permanent failure marks the PendingDelivery as failed instead of scheduling a retry
+	s.Clock.StepBy(5 * time.Minute)
+	assert.ErrEqual(t,
+		conversionJob.ProcessOne(s.Ctx),
+		`while trying to convert payload for event 1 ("foo event with value -1") into test-bar.v1: translation failed permanently: simulating permanently failed translation because of negative value`,
+	)
+	tr.DBChanges().AssertEqualf(`
+			UPDATE pending_deliveries SET failed_conversions = 1, failed_at = %[1]d, failure_message = 'simulating permanently failed translation because of negative value' WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+		`,
+		s.Clock.Now().Unix(),
+	)
+
+	// failed conversions are not retried
+	s.Clock.StepBy(1 * time.Hour)
+	assert.ErrEqual(t, conversionJob.ProcessOne(s.Ctx), sql.ErrNoRows.Error())
+	tr.DBChanges().AssertEmpty()
+}
+
+func TestConversionSkipped(t *testing.T) {
+	ctx := t.Context()
+	s := test.NewSetup(t,
+		test.WithTaskContext,
+		test.WithRoute("test-foo.v1 -> test-bar.v1"),
+	)
+
+	// set up an event that does not need to be delivered (the test translator skips zero values)
+	s.Clock.StepBy(1 * time.Hour)
+	user := tenso.User{
+		Name:       "testusername",
+		UUID:       "testuserid",
+		DomainName: "testdomainname",
+	}
+	must.SucceedT(t, tenso.UserStore.Insert(ctx, s.DB, &user))
+	event := tenso.Event{
+		CreatorID:   user.ID,
+		CreatedAt:   s.Clock.Now(),
+		PayloadType: "test-foo.v1",
+		Payload:     `{"event":"foo","value":0}`,
+		Description: "foo event with value 0",
+	}
+	must.SucceedT(t, tenso.EventStore.Insert(ctx, s.DB, &event))
+	must.SucceedT(t, tenso.PendingDeliveryStore.Insert(ctx, s.DB, &tenso.PendingDelivery{
+		EventID:          event.ID,
+		PayloadType:      "test-bar.v1",
+		NextConversionAt: s.Clock.Now(),
+		NextDeliveryAt:   s.Clock.Now(),
+	}))
+
+	tr, _ := easypg.NewTracker(t, s.DB.DB)
+	conversionJob := s.TaskContext.ConversionJob(s.Registry)
+	garbageJob := s.TaskContext.GarbageCollectionJob(s.Registry)
+
+	// skipping replaces the PendingDelivery with a SkippedDelivery
+	s.Clock.StepBy(5 * time.Minute)
+	must.SucceedT(t, conversionJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEqualf(`
+			DELETE FROM pending_deliveries WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+			INSERT INTO skipped_deliveries (event_id, payload_type, skipped_at, reason, message) VALUES (1, 'test-bar.v1', %[1]d, 'zero-value', 'nothing to deliver');
+		`,
+		s.Clock.Now().Unix(),
+	)
+
+	// since nothing is pending anymore, GC cleans up the event, but keeps the skip record
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEqual(`
+			DELETE FROM events WHERE id = 1;
+		`)
+
+	// the skip record is removed together with the delivery results of the same age
+	s.Clock.StepBy(s.TaskContext.Config.DeliveryResultRetention)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEmpty()
+	s.Clock.StepBy(1 * time.Minute)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEqual(`
+			DELETE FROM skipped_deliveries WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+		`)
+}
@@ -6,6 +6,7 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,7 +22,7 @@ import (
 // WARNING: This must be run in a transaction, or else `FOR UPDATE SKIP LOCKED`
 // will not work as expected.
 var selectNextDeliveryQuery = tenso.PendingDeliveryStore.MustPrepareSelectQueryWhere(sqlext.SimplifyWhitespace(`
-	converted_at IS NOT NULL AND next_delivery_at <= $1
+	converted_at IS NOT NULL AND failed_at IS NULL AND next_delivery_at <= $1
 	ORDER BY next_delivery_at ASC, payload_type ASC   -- secondary order ensures deterministic behavior during test
 	LIMIT 1 FOR UPDATE SKIP LOCKED
 `))
@@ -52,14 +53,17 @@ func (c *Context) DeliveryJob(registerer prometheus.Registerer) jobloop.Job {
 }
 
 func (c *Context) processDelivery(ctx context.Context, tx *gsql.Tx, pd tenso.PendingDelivery, labels prometheus.Labels) (returnedError error) {
-	var event tenso.Event
+	var (
+		event   tenso.Event
+		skipped bool // if true, skipDelivery() takes care of logging
+	)
 
 	labels["payload_type"] = pd.PayloadType
 
 	defer func() {
-		if returnedError == nil {
+		if returnedError == nil && !skipped {
 			logg.Info("delivered %s payload for event %d (%q)", pd.PayloadType, pd.EventID, event.Description)
-		} else {
+		} else if returnedError != nil {
 			returnedError = fmt.Errorf("while trying to deliver %s payload for event %d (%q): %w", pd.PayloadType, pd.EventID, event.Description, returnedError)
 		}
 	}()
@@ -91,29 +95,74 @@ func (c *Context) processDelivery(ctx context.Context, tx *gsql.Tx, pd tenso.Pen
 		return fmt.Errorf("no DeliveryHandler found for %s (was this route disabled recently?)", pd.PayloadType)
 	}
 
-	// try to translate the payload, or set up a delayed retry on failure
-	dlog, err := dh.DeliverPayload(ctx, []byte(*pd.Payload), routingInfo)
+	// try to deliver the payload, or set up a delayed retry on failure
+	// (or give up entirely if the failure is permanent)
+	var dlog *tenso.DeliveryLog
+	if sdh, ok := dh.(tenso.StatefulDeliveryHandler); ok {
+		state := tenso.DeliveryState{
+			Tx:          tx,
+			Now:         c.timeNow(),
+			EventID:     pd.EventID,
+			PayloadType: pd.PayloadType,
+			Region:      c.Config.Region,
+		}
+		dlog, err = sdh.DeliverPayloadWithState(ctx, state, []byte(*pd.Payload), routingInfo)
+	} else {
+		dlog, err = dh.DeliverPayload(ctx, []byte(*pd.Payload), routingInfo)
+	}
+	if skip, ok := errors.AsType[tenso.SkipDeliveryError](err); ok {
+		skipped = true
+		return c.skipDelivery(ctx, tx, pd, event, skip)
+	}
 	if err != nil {
-		pd.NextDeliveryAt = c.timeNow().Add(DeliveryRetryInterval)
 		pd.FailedDeliveryCount++
+		msg := "delivery failed"
+		if tenso.IsPermanent(err) {
+			now := c.timeNow()
+			pd.FailedAt = &now
+			pd.FailureMessage = err.Error()
+			msg = "delivery failed permanently"
+		} else {
+			pd.NextDeliveryAt = c.timeNow().Add(DeliveryRetryInterval)
+		}
 		err2 := tenso.PendingDeliveryStore.Update(ctx, tx, pd)
 		if err2 == nil {
 			err2 = tx.Commit()
 		}
 		if err2 != nil {
-			return fmt.Errorf("delivery failed: %w (additional error during DB update: %s)", err, err2.Error())
+			return fmt.Errorf("%s: %w (additional error during DB update: %s)", msg, err, err2.Error())
 		}
-		return fmt.Errorf("delivery failed: %w", err)
+		return fmt.Errorf("%s: %w", msg, err)
 	}
-	if dlog != nil {
+	if dlog != nil && dlog.Message != "" {
 		logg.Info("delivery of %s payload for event %d (%q) reported: %s", pd.PayloadType, pd.EventID, event.Description, dlog.Message)
 	}
 	logg.Debug("delivered %s payload for event %d (%q) was: %s", pd.PayloadType, pd.EventID, event.Description, *pd.Payload)
 
-	// on successful delivery, remove the PendingDelivery
+	// on successful delivery, replace the PendingDelivery with a DeliveryResult
 	err = tenso.PendingDeliveryStore.Delete(ctx, tx, pd)
 	if err != nil {
 		return err
 	}
+	result := tenso.DeliveryResult{
+		EventID:       pd.EventID,
+		PayloadType:   pd.PayloadType,
+		DeliveredAt:   c.timeNow(),
+		ObjectIDsJSON: "{}",
+	}
+	if dlog != nil {
+		result.Message = dlog.Message
+		if len(dlog.ObjectIDs) > 0 {
+			buf, err := json.Marshal(dlog.ObjectIDs)
+			if err != nil {
+				return err
+			}
+			result.ObjectIDsJSON = string(buf)
+		}
+	}
+	err = tenso.DeliveryResultStore.Insert(ctx, tx, &result)
+	if err != nil {
+		return err
+	}
 	return tx.Commit()
 }
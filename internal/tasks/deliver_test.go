@@ -93,15 +93,102 @@ func TestDeliveryCommon(t *testing.T) {
 	// delivery goes through after waiting period is over
 	s.Clock.StepBy(5 * time.Minute)
 	must.SucceedT(t, deliveryJob.ProcessOne(s.Ctx))
-	tr.DBChanges().AssertEqualf(`DELETE FROM pending_deliveries WHERE event_id = 1 AND payload_type = 'test-bar.v1';`)
+	tr.DBChanges().AssertEqualf(`
+			INSERT INTO delivery_results (event_id, payload_type, delivered_at, message, object_ids_json) VALUES (1, 'test-bar.v1', %[1]d, 'success (routing info was: map[])', '{"value":"42"}');
+			DELETE FROM pending_deliveries WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+		`,
+		s.Clock.Now().Unix(),
+	)
 
 	// also deliver the second payload in the same way
 	_ = must.ReturnT(s.DB.Exec(`UPDATE pending_deliveries SET payload = $1, converted_at = $2 WHERE payload_type = $3`,
 		`{"event":"baz","value":42}`, s.Clock.Now(), "test-baz.v1"))(t)
 	must.SucceedT(t, deliveryJob.ProcessOne(s.Ctx))
-	tr.DBChanges().AssertEqualf(`DELETE FROM pending_deliveries WHERE event_id = 1 AND payload_type = 'test-baz.v1';`)
+	tr.DBChanges().AssertEqualf(`
+			INSERT INTO delivery_results (event_id, payload_type, delivered_at, message, object_ids_json) VALUES (1, 'test-baz.v1', %[1]d, 'success (routing info was: map[])', '{"value":"42"}');
+			DELETE FROM pending_deliveries WHERE event_id = 1 AND payload_type = 'test-baz.v1';
+		`,
+		s.Clock.Now().Unix(),
+	)
 
-	// since all payloads were delivered, GC will clean up the event
+	// since all payloads were delivered, GC will clean up the event (but not the delivery results)
 	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
 	tr.DBChanges().AssertEqualf(`DELETE FROM events WHERE id = 1;`)
+
+	// delivery results are cleaned up once their retention period is over
+	s.Clock.StepBy(s.Config.DeliveryResultRetention)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEmpty()
+	s.Clock.StepBy(1 * time.Minute)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEqualf(`
+			DELETE FROM delivery_results WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+			DELETE FROM delivery_results WHERE event_id = 1 AND payload_type = 'test-baz.v1';
+		`)
+}
+
+func TestDeliveryPermanentFailure(t *testing.T) {
+	ctx := t.Context()
+	s := test.NewSetup(t,
+		test.WithTaskContext,
+		test.WithRoute("test-foo.v1 -> test-bar.v1"),
+	)
+
+	// set up an event with a translated payload that cannot be delivered
+	// (negative values are rejected by the test deliverer)
+	s.Clock.StepBy(1 * time.Hour)
+	user := tenso.User{
+		Name:       "testusername",
+		UUID:       "testuserid",
+		DomainName: "testdomainname",
+	}
+	must.SucceedT(t, tenso.UserStore.Insert(ctx, s.DB, &user))
+	event := tenso.Event{
+		CreatorID:   user.ID,
+		CreatedAt:   s.Clock.Now(),
+		PayloadType: "test-foo.v1",
+		Payload:     `{"event":"foo","value":42}`,
+		Description: "foo event with value 42",
+	}
+	must.SucceedT(t, tenso.EventStore.Insert(ctx, s.DB, &event))
+	payload := `{"event":"bar","value":-1}`
+	now := s.Clock.Now()
+	must.SucceedT(t, tenso.PendingDeliveryStore.Insert(ctx, s.DB, &tenso.PendingDelivery{
+		EventID:          event.ID,
+		PayloadType:      "test-bar.v1",
+		Payload:          &payload,
+		ConvertedAt:      &now,
+		NextConversionAt: now,
+		NextDeliveryAt:   now,
+	}))
+
+	tr, _ := easypg.NewTracker(t, s.DB.DB)
+	deliveryJob := s.TaskContext.DeliveryJob(s.Registry)
+	garbageJob := s.TaskContext.GarbageCollectionJob(s.Registry)
+
+	// permanent failure marks the PendingDelivery as failed instead of scheduling a retry
+	s.Clock.StepBy(5 * time.Minute)
+	assert.ErrEqual(t,
+		deliveryJob.ProcessOne(s.Ctx),
+		`while trying to deliver test-bar.v1 payload for event 1 ("foo event with value 42"): delivery failed permanently: simulating permanently failed delivery because of negative value`,
+	)
+	tr.DBChanges().AssertEqualf(`
+			UPDATE pending_deliveries SET failed_deliveries = 1, failed_at = %[1]d, failure_message = 'simulating permanently failed delivery because of negative value' WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+		`,
+		s.Clock.Now().Unix(),
+	)
+
+	// failed deliveries are not retried, and the event is kept for inspection
+	s.Clock.StepBy(1 * time.Hour)
+	assert.ErrEqual(t, deliveryJob.ProcessOne(s.Ctx), sql.ErrNoRows.Error())
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEmpty()
+
+	// once the retention period has passed, the failed delivery and its event are dropped
+	s.Clock.StepBy(s.TaskContext.Config.FailedDeliveryRetention)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEqual(`
+			DELETE FROM events WHERE id = 1;
+			DELETE FROM pending_deliveries WHERE event_id = 1 AND payload_type = 'test-bar.v1';
+		`)
 }
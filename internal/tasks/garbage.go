@@ -5,6 +5,7 @@ package tasks
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -13,12 +14,52 @@ import (
 	"github.com/sapcc/go-bits/sqlext"
 )
 
+var deliveriesFailedGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tenso_deliveries_failed",
+		Help: "Number of deliveries that failed permanently and are kept for inspection until TENSO_FAILED_DELIVERY_RETENTION has passed.",
+	},
+	[]string{"payload_type"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesFailedGauge)
+}
+
+var gcExpiredFailedDeliveriesQuery = sqlext.SimplifyWhitespace(`
+	DELETE FROM pending_deliveries WHERE failed_at < $1
+`)
+
 var gcDeliveredEventsQuery = sqlext.SimplifyWhitespace(`
 	DELETE FROM events WHERE id NOT IN (SELECT event_id FROM pending_deliveries)
 `)
 
+var gcExpiredDeliveryResultsQuery = sqlext.SimplifyWhitespace(`
+	DELETE FROM delivery_results WHERE delivered_at < $1
+`)
+
+var gcExpiredSkippedDeliveriesQuery = sqlext.SimplifyWhitespace(`
+	DELETE FROM skipped_deliveries WHERE skipped_at < $1
+`)
+
+var gcClosedServiceNowChangesQuery = sqlext.SimplifyWhitespace(`
+	DELETE FROM servicenow_changes WHERE closed_at < $1
+`)
+
+var countFailedDeliveriesQuery = sqlext.SimplifyWhitespace(`
+	SELECT payload_type, COUNT(*) FROM pending_deliveries WHERE failed_at IS NOT NULL GROUP BY payload_type
+`)
+
+// ServiceNowChangeRetention is how long closed changes are remembered in the
+// servicenow_changes table. This only needs to cover start events of a
+// deployment that are delivered after the deployment has finished.
+const ServiceNowChangeRetention = 7 * 24 * time.Hour
+
 // GarbageCollectionJob is a jobloop.Job.
-// Each run clears all events that have no remaining pending deliveries.
+// Each run clears all events that have no remaining pending deliveries, as
+// well as failed deliveries, delivery results, skipped deliveries and closed
+// ServiceNow changes that are older than their retention period. Afterwards,
+// the tenso_deliveries_failed metric is updated.
 func (c *Context) GarbageCollectionJob(registerer prometheus.Registerer) jobloop.Job {
 	return (&jobloop.CronJob{
 		Metadata: jobloop.JobMetadata{
@@ -34,7 +75,59 @@ func (c *Context) GarbageCollectionJob(registerer prometheus.Registerer) jobloop
 }
 
 func (c *Context) collectGarbage(_ context.Context, _ prometheus.Labels) error {
-	result, err := c.DB.Exec(gcDeliveredEventsQuery)
+	now := c.timeNow()
+
+	// NOTE: Expired failed deliveries are removed first, so that their events
+	// can be cleaned up in the same run.
+	err := c.deleteAndLog("expired failed deliveries", gcExpiredFailedDeliveriesQuery, now.Add(-c.Config.FailedDeliveryRetention))
+	if err != nil {
+		return err
+	}
+	err = c.deleteAndLog("fully-delivered events", gcDeliveredEventsQuery)
+	if err != nil {
+		return err
+	}
+	err = c.deleteAndLog("expired delivery results", gcExpiredDeliveryResultsQuery, now.Add(-c.Config.DeliveryResultRetention))
+	if err != nil {
+		return err
+	}
+	err = c.deleteAndLog("expired skipped deliveries", gcExpiredSkippedDeliveriesQuery, now.Add(-c.Config.DeliveryResultRetention))
+	if err != nil {
+		return err
+	}
+	err = c.deleteAndLog("closed ServiceNow changes", gcClosedServiceNowChangesQuery, now.Add(-ServiceNowChangeRetention))
+	if err != nil {
+		return err
+	}
+
+	// report the remaining failed deliveries (with explicit zeroes for all
+	// configured routes, so that alerts can tell "no failures" from "no data")
+	counts := make(map[string]float64)
+	for _, route := range c.Config.EnabledRoutes {
+		counts[route.TargetPayloadType] = 0
+	}
+	err = sqlext.ForeachRow(c.DB, countFailedDeliveriesQuery, nil, func(rows *sql.Rows) error {
+		var (
+			payloadType string
+			count       float64
+		)
+		err := rows.Scan(&payloadType, &count)
+		counts[payloadType] = count
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	deliveriesFailedGauge.Reset()
+	for payloadType, count := range counts {
+		deliveriesFailedGauge.With(prometheus.Labels{"payload_type": payloadType}).Set(count)
+	}
+	return nil
+}
+
+// Executes a DELETE query and logs how many of the described objects were deleted.
+func (c *Context) deleteAndLog(description, query string, args ...any) error {
+	result, err := c.DB.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -43,8 +136,7 @@ func (c *Context) collectGarbage(_ context.Context, _ prometheus.Labels) error {
 		return err
 	}
 	if numDeleted > 0 {
-		logg.Info("cleaned up %d fully-delivered events", numDeleted)
+		logg.Info("cleaned up %d %s", numDeleted, description)
 	}
-
 	return nil
 }
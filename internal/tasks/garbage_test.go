@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package tasks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/easypg"
+	"github.com/sapcc/go-bits/must"
+
+	"github.com/sapcc/tenso/internal/tasks"
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/test"
+)
+
+func TestGarbageCollectionOfServiceNowChanges(t *testing.T) {
+	ctx := t.Context()
+	s := test.NewSetup(t, test.WithTaskContext)
+
+	// one change that is still open, one that was closed, and one marker for a
+	// deployment that finished without a change being created
+	s.Clock.StepBy(1 * time.Hour)
+	now := s.Clock.Now()
+	for _, change := range []tenso.ServiceNowChange{
+		{Target: "default", CorrelationID: "concourse:helm-deployment:services/swift/qa-de-1/81", SysID: "sys1", Number: "CHG0000001", CreatedAt: now},
+		{Target: "default", CorrelationID: "concourse:helm-deployment:services/swift/qa-de-1/82", SysID: "sys2", Number: "CHG0000002", CreatedAt: now, ClosedAt: &now},
+		{Target: "default", CorrelationID: "concourse:helm-deployment:services/swift/qa-de-1/83", CreatedAt: now, ClosedAt: &now},
+	} {
+		must.SucceedT(t, tenso.ServiceNowChangeStore.Insert(ctx, s.DB, &change))
+	}
+
+	tr, _ := easypg.NewTracker(t, s.DB.DB)
+	garbageJob := s.TaskContext.GarbageCollectionJob(s.Registry)
+
+	// closed changes are kept for a while to recognize late start events
+	s.Clock.StepBy(tasks.ServiceNowChangeRetention)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEmpty()
+
+	// afterwards, only the open change remains
+	s.Clock.StepBy(1 * time.Minute)
+	must.SucceedT(t, garbageJob.ProcessOne(s.Ctx))
+	tr.DBChanges().AssertEqualf(`
+		DELETE FROM servicenow_changes WHERE target = 'default' AND correlation_id = 'concourse:helm-deployment:services/swift/qa-de-1/82';
+		DELETE FROM servicenow_changes WHERE target = 'default' AND correlation_id = 'concourse:helm-deployment:services/swift/qa-de-1/83';
+	`)
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package tasks
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sapcc/go-bits/logg"
+	"go.xyrillian.de/gg/gsql"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+var deliveriesSkippedCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tenso_deliveries_skipped",
+		Help: "Counter for deliveries that were skipped because the respective handler decided that no delivery is needed.",
+	},
+	[]string{"payload_type", "reason"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesSkippedCounter)
+}
+
+// Replaces the given PendingDelivery with a SkippedDelivery, and commits the transaction.
+func (c *Context) skipDelivery(ctx context.Context, tx *gsql.Tx, pd tenso.PendingDelivery, event tenso.Event, skip tenso.SkipDeliveryError) error {
+	err := tenso.PendingDeliveryStore.Delete(ctx, tx, pd)
+	if err != nil {
+		return err
+	}
+	err = tenso.SkippedDeliveryStore.Insert(ctx, tx, &tenso.SkippedDelivery{
+		EventID:     pd.EventID,
+		PayloadType: pd.PayloadType,
+		SkippedAt:   c.timeNow(),
+		Reason:      skip.Reason,
+		Message:     skip.Message,
+	})
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	deliveriesSkippedCounter.With(prometheus.Labels{"payload_type": pd.PayloadType, "reason": skip.Reason}).Inc()
+	logg.Info("skipped %s delivery for event %d (%q): %s", pd.PayloadType, pd.EventID, event.Description, skip.Error())
+	return nil
+}
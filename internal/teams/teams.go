@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package teams knows which Concourse teams (and which of their pipelines) may
+// submit deployment events.
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/sapcc/tenso/internal/reloadable"
+)
+
+// Config is the structure of the config file at $TENSO_TEAMS_CONFIG_PATH.
+type Config struct {
+	// Teams maps Concourse team names to the rules for that team. If this is
+	// nil (i.e. if no config file is given), all teams and pipelines are known.
+	Teams map[string]Team `json:"teams"`
+	// OnUnknown decides what happens to events from unknown teams or pipelines.
+	OnUnknown Action `json:"on_unknown"`
+}
+
+// Team appears in type Config.
+type Team struct {
+	// Pipelines lists the names of all known pipelines in this team. If empty,
+	// all pipelines in this team are known.
+	Pipelines []string `json:"pipelines"`
+}
+
+// Action appears in type Config.
+type Action string
+
+const (
+	// ActionReject means that events from unknown teams or pipelines are rejected.
+	ActionReject Action = "reject"
+	// ActionWarn means that events from unknown teams or pipelines are accepted
+	// with a validation warning.
+	ActionWarn Action = "warn"
+)
+
+// IsKnownTeam checks whether the given team is listed in this Config.
+func (c Config) IsKnownTeam(team string) bool {
+	if c.Teams == nil {
+		return true
+	}
+	_, exists := c.Teams[team]
+	return exists
+}
+
+// IsKnownPipeline checks whether the given pipeline is listed for the given
+// team in this Config.
+func (c Config) IsKnownPipeline(team, pipeline string) bool {
+	if c.Teams == nil {
+		return true
+	}
+	t, exists := c.Teams[team]
+	if !exists {
+		return false
+	}
+	return len(t.Pipelines) == 0 || slices.Contains(t.Pipelines, pipeline)
+}
+
+func (c Config) validate() error {
+	if len(c.Teams) == 0 {
+		return errors.New(`missing value for "teams"`)
+	}
+	for teamName, team := range c.Teams {
+		if teamName == "" {
+			return errors.New("team names may not be empty")
+		}
+		if slices.Contains(team.Pipelines, "") {
+			return fmt.Errorf("in team %q: pipeline names may not be empty", teamName)
+		}
+	}
+	switch c.OnUnknown {
+	case ActionReject, ActionWarn:
+		return nil
+	default:
+		return fmt.Errorf(`invalid value for "on_unknown": %q`, c.OnUnknown)
+	}
+}
+
+// Registry provides the current Config. If the Config was loaded from a file,
+// the file is reloaded when it changes on disk, so that new teams and
+// pipelines can be added without restarting Tenso.
+type Registry struct {
+	file *reloadable.File[Config]
+}
+
+var loader = reloadable.Loader[Config]{
+	Description: "teams",
+	Initial:     Config{OnUnknown: ActionReject},
+	Validate:    Config.validate,
+}
+
+// Load loads the registry from the file specified in the given environment
+// variable. If the environment variable is not set, all teams and pipelines
+// are considered known.
+func Load(envVarName string) (*Registry, error) {
+	file, err := loader.Load(envVarName)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{file}, nil
+}
+
+// Current returns the current Config. If the config file has changed, it is
+// reloaded first. If the changed file is invalid, the previous Config is kept.
+func (r *Registry) Current() Config {
+	return r.file.Current()
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package teams_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/reloadable"
+	"github.com/sapcc/tenso/internal/teams"
+)
+
+func TestWithoutConfigFile(t *testing.T) {
+	t.Setenv("TENSO_TEAMS_CONFIG_PATH", "")
+	cfg := must.ReturnT(teams.Load("TENSO_TEAMS_CONFIG_PATH"))(t).Current()
+
+	// without a config file, everything is known
+	assert.Equal(t, cfg.IsKnownTeam("services"), true)
+	assert.Equal(t, cfg.IsKnownPipeline("services", "swift"), true)
+}
+
+func TestConfigFile(t *testing.T) {
+	t.Setenv("TENSO_TEAMS_CONFIG_PATH", "fixtures/teams.json")
+	cfg := must.ReturnT(teams.Load("TENSO_TEAMS_CONFIG_PATH"))(t).Current()
+	assert.Equal(t, cfg.OnUnknown, teams.ActionWarn)
+
+	// teams without a list of pipelines allow all pipelines
+	assert.Equal(t, cfg.IsKnownTeam("services"), true)
+	assert.Equal(t, cfg.IsKnownPipeline("services", "swift"), true)
+
+	// teams with a list of pipelines allow only those pipelines
+	assert.Equal(t, cfg.IsKnownTeam("core"), true)
+	assert.Equal(t, cfg.IsKnownPipeline("core", "active-directory"), true)
+	assert.Equal(t, cfg.IsKnownPipeline("core", "swift"), false)
+
+	// teams that are not listed are not known
+	assert.Equal(t, cfg.IsKnownTeam("monsoon"), false)
+	assert.Equal(t, cfg.IsKnownPipeline("monsoon", "swift"), false)
+}
+
+func TestConfigReload(t *testing.T) {
+	// check for changes on every call, so that reloads are observed immediately
+	defer func(interval time.Duration) { reloadable.CheckInterval = interval }(reloadable.CheckInterval)
+	reloadable.CheckInterval = 0
+
+	path := filepath.Join(t.TempDir(), "teams.json")
+	writeConfig := func(content string, modTime time.Time) {
+		must.SucceedT(t, os.WriteFile(path, []byte(content), 0o600))
+		must.SucceedT(t, os.Chtimes(path, modTime, modTime))
+	}
+	now := time.Now().Truncate(time.Second)
+	writeConfig(`{"teams":{"services":{}}}`, now.Add(-time.Hour))
+
+	t.Setenv("TENSO_TEAMS_CONFIG_PATH", path)
+	registry := must.ReturnT(teams.Load("TENSO_TEAMS_CONFIG_PATH"))(t)
+	assert.Equal(t, registry.Current().IsKnownTeam("services"), true)
+	assert.Equal(t, registry.Current().OnUnknown, teams.ActionReject)
+
+	// when the file changes, the new config is used without loading it again
+	writeConfig(`{"teams":{"services":{},"core":{}}}`, now)
+	assert.Equal(t, registry.Current().IsKnownTeam("core"), true)
+
+	// when the changed file is invalid, the previous config is kept
+	writeConfig(`{"teams":{}}`, now.Add(time.Hour))
+	assert.Equal(t, registry.Current().IsKnownTeam("core"), true)
+}
+
+func TestConfigValidation(t *testing.T) {
+	testCases := map[string]string{
+		`{}`:                                    `missing value for "teams"`,
+		`{"teams":{"":{}}}`:                     `team names may not be empty`,
+		`{"teams":{"core":{"pipelines":[""]}}}`: `in team "core": pipeline names may not be empty`,
+		`{"teams":{"core":{}},"on_unknown":"ignore"}`: `invalid value for "on_unknown": "ignore"`,
+		`{"unknown":true}`: `json: unknown field "unknown"`,
+	}
+	for configJSON, expectedError := range testCases {
+		path := filepath.Join(t.TempDir(), "teams.json")
+		must.SucceedT(t, os.WriteFile(path, []byte(configJSON), 0o600))
+		t.Setenv("TENSO_TEAMS_CONFIG_PATH", path)
+		_, err := teams.Load("TENSO_TEAMS_CONFIG_PATH")
+		assert.ErrEqual(t, err, "while parsing "+path+": "+expectedError)
+	}
+}
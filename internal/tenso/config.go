@@ -8,11 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/sapcc/go-bits/gophercloudext"
+	"github.com/sapcc/go-bits/logg"
 	"github.com/sapcc/go-bits/must"
 	"github.com/sapcc/go-bits/osext"
 )
@@ -21,6 +24,12 @@ import (
 type Configuration struct {
 	DatabaseURL   url.URL
 	EnabledRoutes []Route
+	// The OpenStack region that this Tenso instance belongs to.
+	Region string
+	// How long delivery results are kept after the delivery.
+	DeliveryResultRetention time.Duration
+	// How long failed deliveries are kept for inspection before they are dropped.
+	FailedDeliveryRetention time.Duration
 }
 
 var (
@@ -39,6 +48,9 @@ func ParseConfiguration(ctx context.Context) (Configuration, *gophercloud.Provid
 	must.Succeed(err)
 
 	cfg.EnabledRoutes = must.Return(BuildRoutes(ctx, strings.Split(osext.MustGetenv("TENSO_ROUTES"), ","), provider, eo))
+	cfg.Region = eo.Region
+	cfg.DeliveryResultRetention = must.Return(time.ParseDuration(osext.GetenvOrDefault("TENSO_DELIVERY_RESULT_RETENTION", "720h")))
+	cfg.FailedDeliveryRetention = must.Return(time.ParseDuration(osext.GetenvOrDefault("TENSO_FAILED_DELIVERY_RETENTION", "720h")))
 	return cfg, provider, eo
 }
 
@@ -48,10 +60,10 @@ func ParseConfiguration(ctx context.Context) (Configuration, *gophercloud.Provid
 // The `pc` and `eo` args are passed to the handlers' Init() methods verbatim.
 func BuildRoutes(ctx context.Context, routeSpecs []string, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) ([]Route, error) {
 	var (
-		result              []Route
-		validationHandlers  = make(map[string]ValidationHandler)
-		translationHandlers = make(map[string]TranslationHandler)
-		deliveryHandlers    = make(map[string]DeliveryHandler)
+		result             []Route
+		validationHandlers = make(map[string]ValidationHandler)
+		deliveryHandlers   = make(map[string]DeliveryHandler)
+		planner            = NewTranslationPlanner(ctx, pc, eo)
 	)
 
 	// parse routes
@@ -73,12 +85,16 @@ func BuildRoutes(ctx context.Context, routeSpecs []string, pc *gophercloud.Provi
 
 		// instantiate validation handler if not done yet
 		if validationHandlers[route.SourcePayloadType] == nil {
-			vh := ValidationHandlerRegistry.Instantiate(route.SourcePayloadType)
+			vh, err := InstantiateValidationHandler(route.SourcePayloadType)
+			if err != nil {
+				return nil, fmt.Errorf("while parsing route specification %q: cannot instantiate validation for %s: %s",
+					routeSpec, route.SourcePayloadType, err.Error())
+			}
 			if vh == nil {
 				return nil, fmt.Errorf("route specification %q is invalid: cannot validate %s",
 					routeSpec, route.SourcePayloadType)
 			}
-			err := vh.Init(ctx, pc, eo)
+			err = vh.Init(ctx, pc, eo)
 			if err != nil {
 				return nil, fmt.Errorf("while parsing route specification %q: cannot initialize validation for %s: %s",
 					routeSpec, route.SourcePayloadType, err.Error())
@@ -87,22 +103,19 @@ func BuildRoutes(ctx context.Context, routeSpecs []string, pc *gophercloud.Provi
 		}
 		route.ValidationHandler = validationHandlers[route.SourcePayloadType]
 
-		// initiate translation handler if not done yet
-		typeID := fmt.Sprintf("%s->%s", route.SourcePayloadType, route.TargetPayloadType)
-		if translationHandlers[typeID] == nil {
-			th := TranslationHandlerRegistry.Instantiate(typeID)
-			if th == nil {
-				return nil, fmt.Errorf("route specification %q is invalid: do not know how to translate from %s to %s",
-					routeSpec, route.SourcePayloadType, route.TargetPayloadType)
-			}
-			err := th.Init(ctx, pc, eo)
-			if err != nil {
-				return nil, fmt.Errorf("while parsing route specification %q: cannot initialize translation from %s to %s: %s",
-					routeSpec, route.SourcePayloadType, route.TargetPayloadType, err.Error())
-			}
-			translationHandlers[typeID] = th
+		// find the shortest translation chain (through intermediate payload types if necessary)
+		chain, err := planner.FindChain(route.SourcePayloadType, route.TargetPayloadType)
+		if err != nil {
+			return nil, fmt.Errorf("while parsing route specification %q: %w", routeSpec, err)
 		}
-		route.TranslationHandler = translationHandlers[typeID]
+		if chain == nil {
+			return nil, fmt.Errorf("route specification %q is invalid: do not know how to translate from %s to %s",
+				routeSpec, route.SourcePayloadType, route.TargetPayloadType)
+		}
+		if len(chain) > 1 {
+			logg.Debug("route %q translates through %s", routeSpec, chain.String())
+		}
+		route.TranslationChain = chain
 
 		// instantiate delivery handler if not done yet
 		if deliveryHandlers[route.TargetPayloadType] == nil {
@@ -134,3 +147,17 @@ func BuildRoutes(ctx context.Context, routeSpecs []string, pc *gophercloud.Provi
 func IsWellFormedPayloadType(val string) bool {
 	return payloadTypeRx.MatchString(val)
 }
+
+// ReadSecretFile reads a secret (e.g. a password or API key) from the file at
+// the given path. Surrounding whitespace is removed. An empty secret is an error.
+func ReadSecretFile(path string) (string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot load secret: %w", err)
+	}
+	secret := strings.TrimSpace(string(buf))
+	if secret == "" {
+		return "", fmt.Errorf("secret in %s is empty", path)
+	}
+	return secret, nil
+}
@@ -54,6 +54,47 @@ var sqlMigrations = map[int64]string{
 	3: `
 		ALTER TABLE events ADD COLUMN routing_info_json TEXT NOT NULL DEFAULT '';
 	`,
+	4: `
+		ALTER TABLE pending_deliveries ADD COLUMN failed_at TIMESTAMPTZ DEFAULT NULL;
+		ALTER TABLE pending_deliveries ADD COLUMN failure_message TEXT NOT NULL DEFAULT '';
+	`,
+	5: `
+		CREATE TABLE skipped_deliveries (
+			event_id     BIGINT      NOT NULL REFERENCES events ON DELETE CASCADE,
+			payload_type TEXT        NOT NULL,
+			skipped_at   TIMESTAMPTZ NOT NULL,
+			reason       TEXT        NOT NULL,
+			message      TEXT        NOT NULL DEFAULT '',
+			PRIMARY KEY (event_id, payload_type)
+		);
+	`,
+	6: `
+		CREATE TABLE servicenow_changes (
+			target         TEXT        NOT NULL,
+			correlation_id TEXT        NOT NULL,
+			sys_id         TEXT        NOT NULL,
+			number         TEXT        NOT NULL,
+			created_at     TIMESTAMPTZ NOT NULL,
+			closed_at      TIMESTAMPTZ DEFAULT NULL,
+			PRIMARY KEY (target, correlation_id)
+		);
+	`,
+	7: `
+		CREATE TABLE delivery_results (
+			event_id        BIGINT      NOT NULL,
+			payload_type    TEXT        NOT NULL,
+			delivered_at    TIMESTAMPTZ NOT NULL,
+			message         TEXT        NOT NULL DEFAULT '',
+			object_ids_json TEXT        NOT NULL DEFAULT '{}',
+			PRIMARY KEY (event_id, payload_type)
+		);
+	`,
+	8: `
+		ALTER TABLE events ADD COLUMN warnings_json TEXT NOT NULL DEFAULT '[]';
+	`,
+	9: `
+		ALTER TABLE skipped_deliveries DROP CONSTRAINT skipped_deliveries_event_id_fkey;
+	`,
 }
 
 // DBConfiguration returns the [pgruntime.ConnectionBehavior] object that func main() needs to initialize the DB connection.
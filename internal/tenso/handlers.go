@@ -5,12 +5,16 @@ package tenso
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/sapcc/go-bits/pluggable"
+	"go.xyrillian.de/gg/gsql"
 )
 
 // ValidationHandler is an object that validates incoming payloads of a specific
@@ -24,6 +28,11 @@ type ValidationHandler interface {
 	// talk to OpenStack. During unit tests, (nil, nil) will be provided instead.
 	Init(ctx context.Context, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) error
 
+	// ValidatePayload checks the payload and returns structured information
+	// about it. If the payload is invalid, the error should be of type
+	// ValidationErrors and list all problems that were found. Other errors are
+	// reported to the client as affecting the payload as a whole. Problems that
+	// do not warrant rejecting the payload are reported in PayloadInfo.Warnings.
 	ValidatePayload(payload []byte, regionRx *regexp.Regexp) (*PayloadInfo, error)
 }
 
@@ -33,6 +42,17 @@ type PayloadInfo struct {
 	// Description is a short summary of the event with this payload. It is used
 	// to identify the event in log messages.
 	Description string
+	// Warnings lists problems with the payload that do not prevent it from
+	// being accepted, e.g. missing optional fields that should be set.
+	Warnings ValidationErrors
+	// PolicyAttributes are made available as object attributes when checking
+	// whether the user may submit this event. For example, the value for key
+	// "team" can be referred to as "%(target.team)s" in the policy.
+	//
+	// Validators should use the following keys where applicable: "region",
+	// "availability_zone", "team", "pipeline" and "landscape". Keys that do
+	// not apply to a payload type are left out.
+	PolicyAttributes map[string]string
 }
 
 // TranslationHandler is an object that can translate payloads from one specific
@@ -47,6 +67,9 @@ type TranslationHandler interface {
 	// talk to OpenStack. During unit tests, (nil, {}) will be provided instead.
 	Init(ctx context.Context, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) error
 
+	// If the returned error is (or wraps) a PermanentError, the translation will
+	// not be retried. If the returned error is a SkipDeliveryError, the event
+	// will not be delivered to the target payload type at all.
 	TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error)
 }
 
@@ -82,14 +105,121 @@ type DeliveryHandler interface {
 
 	// The `routingInfo` argument contains the metadata that was supplied in the
 	// `X-Tenso-Routing-Info` header when posting the original event.
+	//
+	// If the returned error is (or wraps) a PermanentError, the delivery will
+	// not be retried. If the returned error is a SkipDeliveryError, the delivery
+	// is considered complete without anything having been delivered.
 	DeliverPayload(ctx context.Context, payload []byte, routingInfo map[string]string) (*DeliveryLog, error)
 }
 
+// StatefulDeliveryHandler is an optional extension of DeliveryHandler for
+// handlers that need to keep track of state across deliveries, e.g. to update
+// an object in the target system that was created by an earlier delivery. If a
+// DeliveryHandler implements this interface, DeliverPayloadWithState() is
+// called instead of DeliverPayload().
+type StatefulDeliveryHandler interface {
+	DeliveryHandler
+	// Same as DeliverPayload(), but with access to the Tenso database.
+	DeliverPayloadWithState(ctx context.Context, state DeliveryState, payload []byte, routingInfo map[string]string) (*DeliveryLog, error)
+}
+
+// DeliveryState is given to StatefulDeliveryHandler.DeliverPayloadWithState().
+type DeliveryState struct {
+	// The transaction in which the delivery is recorded. This transaction will
+	// be committed even if the delivery fails, so handlers should only write
+	// into it when returning success or a SkipDeliveryError.
+	Tx *gsql.Tx
+	// The current time (can be overridden in unit tests).
+	Now time.Time
+	// The event and payload type that are being delivered. Together, these
+	// identify the delivery across retries.
+	EventID     int64
+	PayloadType string
+	// The region of this Tenso instance. Together with the above, this
+	// identifies the delivery across all Tenso instances that deliver into the
+	// same target system.
+	Region string
+}
+
 // DeliveryLog can be returned by DeliverPayload() to produce additional log
 // messages, e.g. to report the ID of an object that was created in the target
 // system.
 type DeliveryLog struct {
 	Message string
+	// ObjectIDs identifies the objects that were created or updated in the
+	// target system, e.g. {"number": "CHG0012345", "sys_id": "..."} for
+	// ServiceNow. The keys are specific to each target system. These are
+	// recorded in the `delivery_results` table.
+	ObjectIDs map[string]string
+}
+
+// PermanentError can be returned by TranslatePayload() or DeliverPayload() to
+// signal that the operation failed in a way that will not resolve itself by
+// retrying, e.g. because the payload is malformed or because the target system
+// rejected it as invalid. Errors not wrapped in this type are considered
+// transient and will be retried after a delay.
+type PermanentError struct {
+	Inner error
+}
+
+// Permanent wraps the given error into a PermanentError.
+// If nil is given, nil is returned.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return PermanentError{err}
+}
+
+// Error implements the builtin/error interface.
+func (e PermanentError) Error() string {
+	return e.Inner.Error()
+}
+
+// Unwrap implements the interface implied by the errors.Unwrap() function.
+func (e PermanentError) Unwrap() error {
+	return e.Inner
+}
+
+// IsPermanent returns whether the given error is or wraps a PermanentError.
+func IsPermanent(err error) bool {
+	_, ok := errors.AsType[PermanentError](err)
+	return ok
+}
+
+// IsPermanentFailureStatus returns whether an HTTP request that failed with
+// this status code would fail in the same way when retried. This is the case
+// for most 4xx errors, since they indicate that the target system considers
+// our payload to be invalid.
+func IsPermanentFailureStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		// these can be resolved by fixing credentials or by waiting
+		return false
+	default:
+		return statusCode >= 400 && statusCode < 500
+	}
+}
+
+// SkipDeliveryError can be returned by TranslatePayload() or DeliverPayload()
+// to signal that the event does not need to be delivered to this target, e.g.
+// because it describes a deployment that did not change anything. This is not
+// treated as a failure: The skip is recorded and the delivery is considered
+// complete.
+type SkipDeliveryError struct {
+	// Reason is a short machine-readable identifier like "not-deployed". It is
+	// used as a metric label, so the set of possible values must be small.
+	Reason string
+	// Message is an optional human-readable explanation.
+	Message string
+}
+
+// Error implements the builtin/error interface.
+func (e SkipDeliveryError) Error() string {
+	if e.Message == "" {
+		return "delivery skipped: " + e.Reason
+	}
+	return fmt.Sprintf("delivery skipped: %s (%s)", e.Reason, e.Message)
 }
 
 var (
@@ -99,15 +229,53 @@ var (
 	TranslationHandlerRegistry pluggable.Registry[TranslationHandler]
 	// DeliveryHandlerRegistry is a pluggable.Registry for DeliveryHandler implementations.
 	DeliveryHandlerRegistry pluggable.Registry[DeliveryHandler]
+
+	// ConfiguredValidationHandler and ConfiguredTranslationHandlers can be set
+	// by handler implementations to provide handlers whose plugin type IDs are
+	// determined by configuration instead of by code, e.g. for payload types
+	// that are declared in configuration. They are consulted for plugin type
+	// IDs that are not known to the respective registry, and return nil if the
+	// plugin type ID is not known to them either.
+	ConfiguredValidationHandler   func(payloadType string) (ValidationHandler, error)
+	ConfiguredTranslationHandlers []func(typeID string) (TranslationHandler, error)
 )
 
+// InstantiateValidationHandler returns a new ValidationHandler for the given
+// payload type, or nil if the payload type is unknown. Handlers in
+// ValidationHandlerRegistry take precedence over ConfiguredValidationHandler.
+func InstantiateValidationHandler(payloadType string) (ValidationHandler, error) {
+	vh := ValidationHandlerRegistry.Instantiate(payloadType)
+	if vh == nil && ConfiguredValidationHandler != nil {
+		return ConfiguredValidationHandler(payloadType)
+	}
+	return vh, nil
+}
+
+// InstantiateTranslationHandler returns a new TranslationHandler for the given
+// plugin type ID, or nil if the plugin type ID is unknown. Handlers in
+// TranslationHandlerRegistry take precedence over ConfiguredTranslationHandlers.
+func InstantiateTranslationHandler(typeID string) (TranslationHandler, error) {
+	th := TranslationHandlerRegistry.Instantiate(typeID)
+	if th != nil {
+		return th, nil
+	}
+	for _, instantiate := range ConfiguredTranslationHandlers {
+		th, err := instantiate(typeID)
+		if th != nil || err != nil {
+			return th, err
+		}
+	}
+	return nil, nil
+}
+
 // Route describes a complete delivery path for events: An event gets submitted
 // to us with an initial payload type, gets translated into a different payload
-// type, and then the translated payload gets delivered.
+// type (possibly through intermediate payload types), and then the translated
+// payload gets delivered.
 type Route struct {
-	SourcePayloadType  string
-	TargetPayloadType  string
-	ValidationHandler  ValidationHandler
-	TranslationHandler TranslationHandler
-	DeliveryHandler    DeliveryHandler
+	SourcePayloadType string
+	TargetPayloadType string
+	ValidationHandler ValidationHandler
+	TranslationChain  TranslationChain
+	DeliveryHandler   DeliveryHandler
 }
@@ -18,6 +18,7 @@ type Event struct {
 	Payload         string    `db:"payload"`
 	Description     string    `db:"description"`       // a short summary that appears in log messages
 	RoutingInfoJSON string    `db:"routing_info_json"` // from the X-Tenso-Routing-Info header
+	WarningsJSON    string    `db:"warnings_json"`     // from PayloadInfo.Warnings
 }
 
 // EventStore provides loading and storing of [Event] objects from the DB.
@@ -53,6 +54,10 @@ type PendingDelivery struct {
 	NextConversionAt      time.Time  `db:"next_conversion_at"`
 	FailedDeliveryCount   int64      `db:"failed_deliveries"`
 	NextDeliveryAt        time.Time  `db:"next_delivery_at"`
+	// FailedAt and FailureMessage are set when conversion or delivery failed
+	// with a tenso.PermanentError. Such deliveries will not be retried.
+	FailedAt       *time.Time `db:"failed_at"`
+	FailureMessage string     `db:"failure_message"`
 }
 
 // PendingDeliveryStore provides loading and storing of [PendingDelivery] objects from the DB.
@@ -61,3 +66,61 @@ var PendingDeliveryStore = oblast.MustNewStore[PendingDelivery](
 	oblast.TableNameIs("pending_deliveries"),
 	oblast.PrimaryKeyIs("event_id", "payload_type"),
 )
+
+// SkippedDelivery contains a record from the `skipped_deliveries` table.
+// Like [DeliveryResult], these records are kept after their event is deleted.
+type SkippedDelivery struct {
+	EventID     int64     `db:"event_id"`
+	PayloadType string    `db:"payload_type"`
+	SkippedAt   time.Time `db:"skipped_at"`
+	Reason      string    `db:"reason"`
+	Message     string    `db:"message"`
+}
+
+// SkippedDeliveryStore provides loading and storing of [SkippedDelivery] objects from the DB.
+var SkippedDeliveryStore = oblast.MustNewStore[SkippedDelivery](
+	oblast.PostgresDialect(),
+	oblast.TableNameIs("skipped_deliveries"),
+	oblast.PrimaryKeyIs("event_id", "payload_type"),
+)
+
+// DeliveryResult contains a record from the `delivery_results` table.
+// These records are kept after their event is deleted, so that objects created
+// in the target systems can be traced back to the event that caused them.
+type DeliveryResult struct {
+	EventID       int64     `db:"event_id"`
+	PayloadType   string    `db:"payload_type"`
+	DeliveredAt   time.Time `db:"delivered_at"`
+	Message       string    `db:"message"`         // from tenso.DeliveryLog
+	ObjectIDsJSON string    `db:"object_ids_json"` // from tenso.DeliveryLog
+}
+
+// DeliveryResultStore provides loading and storing of [DeliveryResult] objects from the DB.
+var DeliveryResultStore = oblast.MustNewStore[DeliveryResult](
+	oblast.PostgresDialect(),
+	oblast.TableNameIs("delivery_results"),
+	oblast.PrimaryKeyIs("event_id", "payload_type"),
+)
+
+// ServiceNowChange contains a record from the `servicenow_changes` table.
+// These records track changes in ServiceNow that were opened by Tenso, so that
+// they can be closed by a later delivery. Deployments that finished without a
+// change being created are also recorded (with empty SysID and Number), so
+// that no change is opened for them afterwards. Changes that were opened
+// without ServiceNow reporting their sys_id are recorded with empty SysID,
+// so that no second change is created for them when the deployment finishes.
+type ServiceNowChange struct {
+	Target        string     `db:"target"`         // from the "servicenow-target" routing info
+	CorrelationID string     `db:"correlation_id"` // identifies the deployment described by this change
+	SysID         string     `db:"sys_id"`         // empty if no change was created or its sys_id is unknown
+	Number        string     `db:"number"`         // empty if no change was created
+	CreatedAt     time.Time  `db:"created_at"`
+	ClosedAt      *time.Time `db:"closed_at"`
+}
+
+// ServiceNowChangeStore provides loading and storing of [ServiceNowChange] objects from the DB.
+var ServiceNowChangeStore = oblast.MustNewStore[ServiceNowChange](
+	oblast.PostgresDialect(),
+	oblast.TableNameIs("servicenow_changes"),
+	oblast.PrimaryKeyIs("target", "correlation_id"),
+)
@@ -4,7 +4,9 @@
 package tenso
 
 import (
+	"bytes"
 	"context"
+	"errors"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/gophercloud/gophercloud/v2/openstack"
@@ -31,3 +33,21 @@ func InitializeSwiftDelivery(ctx context.Context, pc *gophercloud.ProviderClient
 	}
 	return swiftAccount.Container(containerName).EnsureExists(ctx)
 }
+
+// DeliverToSwift provides the shared DeliverPayload() behavior for
+// DeliveryHandler implementations that deliver to Swift.
+func DeliverToSwift(ctx context.Context, container *schwift.Container, objectName string, payload []byte) (*DeliveryLog, error) {
+	err := container.Object(objectName).Upload(ctx, bytes.NewReader(payload), nil, nil)
+	if err != nil {
+		if e, ok := errors.AsType[schwift.UnexpectedStatusCodeError](err); ok && e.ActualResponse != nil && IsPermanentFailureStatus(e.ActualResponse.StatusCode) {
+			return nil, Permanent(err)
+		}
+		return nil, err
+	}
+	return &DeliveryLog{
+		ObjectIDs: map[string]string{
+			"container": container.Name(),
+			"object":    objectName,
+		},
+	}, nil
+}
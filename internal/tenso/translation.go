@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package tenso
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/v2"
+)
+
+// IntermediatePayloadTypes lists payload types that translation chains may
+// pass through on their way from a route's source payload type to its target
+// payload type. Handler implementations can add entries to this map during
+// init() if they provide TranslationHandlers into and out of that payload type.
+//
+// For example, if "foo.v2" is an intermediate payload type, the route
+// "foo.v1 -> bar.v1" can use the TranslationHandlers for "foo.v1->foo.v2" and
+// "foo.v2->bar.v1" if there is no TranslationHandler for "foo.v1->bar.v1".
+var IntermediatePayloadTypes = make(map[string]bool)
+
+// TranslationChain is a sequence of TranslationHandlers that translates
+// payloads from one payload type into another, possibly through intermediate
+// payload types. The target payload type of each handler is equal to the
+// source payload type of the next handler.
+type TranslationChain []TranslationHandler
+
+// String returns a representation like "foo.v1 -> foo.v2 -> bar.v1".
+func (c TranslationChain) String() string {
+	if len(c) == 0 {
+		return ""
+	}
+	payloadTypes := []string{SourcePayloadTypeOf(c[0])}
+	for _, th := range c {
+		payloadTypes = append(payloadTypes, TargetPayloadTypeOf(th))
+	}
+	return strings.Join(payloadTypes, " -> ")
+}
+
+// TranslatePayload runs the payload through each TranslationHandler in order.
+// The error types from TranslationHandler.TranslatePayload() (PermanentError
+// and SkipDeliveryError) are retained.
+func (c TranslationChain) TranslatePayload(payload []byte, routingInfo map[string]string) ([]byte, error) {
+	for _, th := range c {
+		var err error
+		payload, err = th.TranslatePayload(payload, routingInfo)
+		if err != nil {
+			if len(c) > 1 {
+				err = fmt.Errorf("while translating from %s to %s: %w", SourcePayloadTypeOf(th), TargetPayloadTypeOf(th), err)
+			}
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
+// TranslationPlanner finds TranslationChains between payload types. Each
+// TranslationHandler is instantiated and initialized at most once per planner,
+// so that routes sharing a TranslationHandler share the same instance.
+type TranslationPlanner struct {
+	ctx      context.Context
+	pc       *gophercloud.ProviderClient
+	eo       gophercloud.EndpointOpts
+	handlers map[string]TranslationHandler // key = type ID, value = nil if there is no such handler
+	isInit   map[string]bool               // key = type ID
+}
+
+// NewTranslationPlanner builds a TranslationPlanner. The `pc` and `eo` args are
+// passed to the handlers' Init() methods verbatim.
+func NewTranslationPlanner(ctx context.Context, pc *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) *TranslationPlanner {
+	return &TranslationPlanner{
+		ctx:      ctx,
+		pc:       pc,
+		eo:       eo,
+		handlers: make(map[string]TranslationHandler),
+		isInit:   make(map[string]bool),
+	}
+}
+
+// FindChain returns the shortest TranslationChain from the source payload type
+// into the target payload type, or nil if there is none. If there are several
+// shortest chains, the one going through the alphabetically first
+// intermediate payload types is chosen.
+func (p *TranslationPlanner) FindChain(sourcePayloadType, targetPayloadType string) (TranslationChain, error) {
+	// the target is checked first, so that direct translations are preferred
+	nextPayloadTypes := []string{targetPayloadType}
+	for _, payloadType := range slices.Sorted(maps.Keys(IntermediatePayloadTypes)) {
+		if payloadType != targetPayloadType {
+			nextPayloadTypes = append(nextPayloadTypes, payloadType)
+		}
+	}
+
+	// breadth-first search, so that the first chain found is one of the shortest
+	reachedBy := map[string]TranslationHandler{sourcePayloadType: nil}
+	queue := []string{sourcePayloadType}
+	for len(queue) > 0 && reachedBy[targetPayloadType] == nil {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range nextPayloadTypes {
+			if _, exists := reachedBy[next]; exists {
+				continue
+			}
+			th, err := p.instantiate(current, next)
+			if err != nil {
+				return nil, err
+			}
+			if th != nil {
+				reachedBy[next] = th
+				queue = append(queue, next)
+				if next == targetPayloadType {
+					break
+				}
+			}
+		}
+	}
+	if reachedBy[targetPayloadType] == nil {
+		return nil, nil
+	}
+
+	// walk back from the target to reconstruct the chain
+	var chain TranslationChain
+	for payloadType := targetPayloadType; payloadType != sourcePayloadType; {
+		th := reachedBy[payloadType]
+		chain = append(TranslationChain{th}, chain...)
+		payloadType = SourcePayloadTypeOf(th)
+	}
+
+	// only the handlers on the chosen chain need to be initialized
+	for _, th := range chain {
+		err := p.initialize(th)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+func (p *TranslationPlanner) instantiate(sourcePayloadType, targetPayloadType string) (TranslationHandler, error) {
+	typeID := fmt.Sprintf("%s->%s", sourcePayloadType, targetPayloadType)
+	if th, exists := p.handlers[typeID]; exists {
+		return th, nil
+	}
+	th, err := InstantiateTranslationHandler(typeID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot instantiate translation from %s to %s: %s",
+			sourcePayloadType, targetPayloadType, err.Error())
+	}
+	p.handlers[typeID] = th
+	return th, nil
+}
+
+func (p *TranslationPlanner) initialize(th TranslationHandler) error {
+	typeID := th.PluginTypeID()
+	if p.isInit[typeID] {
+		return nil
+	}
+	err := th.Init(p.ctx, p.pc, p.eo)
+	if err != nil {
+		return fmt.Errorf("cannot initialize translation from %s to %s: %s",
+			SourcePayloadTypeOf(th), TargetPayloadTypeOf(th), err.Error())
+	}
+	p.isInit[typeID] = true
+	return nil
+}
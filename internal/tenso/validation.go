@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package tenso
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationErrorCode is a machine-readable classification of a ValidationError.
+type ValidationErrorCode string
+
+const (
+	// ValidationErrorMalformed means that the payload could not be parsed at all.
+	ValidationErrorMalformed ValidationErrorCode = "malformed"
+	// ValidationErrorMissing means that a required value is missing or empty.
+	ValidationErrorMissing ValidationErrorCode = "missing"
+	// ValidationErrorInvalid means that a value has the wrong type or format,
+	// or is not one of the accepted values.
+	ValidationErrorInvalid ValidationErrorCode = "invalid"
+	// ValidationErrorNotAllowed means that a value may not be set (at least
+	// not in combination with the other values in the payload).
+	ValidationErrorNotAllowed ValidationErrorCode = "not-allowed"
+	// ValidationErrorUnknown means that a value is well-formed, but does not
+	// refer to anything that Tenso knows about (e.g. an unknown team name).
+	ValidationErrorUnknown ValidationErrorCode = "unknown"
+)
+
+// ValidationError describes a single problem with an incoming payload.
+type ValidationError struct {
+	// Pointer is a JSON pointer (RFC 6901) to the offending value, e.g.
+	// "/helm-release/0/cluster". It is empty if the problem concerns the
+	// payload as a whole.
+	Pointer string              `json:"pointer"`
+	Code    ValidationErrorCode `json:"code"`
+	Message string              `json:"message"`
+}
+
+// ValidationErrors is the error type returned by ValidatePayload() when the
+// payload is invalid. Validators should collect all problems with a payload
+// instead of stopping at the first one, so that the producer of the payload
+// can fix all problems at once.
+type ValidationErrors []ValidationError
+
+// Add appends a ValidationError to this list. The message is formatted like
+// by fmt.Sprintf().
+func (errs *ValidationErrors) Add(pointer string, code ValidationErrorCode, msg string, args ...any) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	*errs = append(*errs, ValidationError{Pointer: pointer, Code: code, Message: msg})
+}
+
+// OrNil returns nil if the list is empty, or the list itself otherwise. This
+// avoids returning a non-nil error interface containing an empty list.
+func (errs ValidationErrors) OrNil() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Error implements the builtin/error interface.
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for idx, err := range errs {
+		msgs[idx] = err.Message
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// ValidationErrorsOf converts an error returned by ValidatePayload() into
+// ValidationErrors. Errors of other types are reported as a single
+// ValidationError concerning the payload as a whole.
+func ValidationErrorsOf(err error) ValidationErrors {
+	if err == nil {
+		return nil
+	}
+	if errs, ok := errors.AsType[ValidationErrors](err); ok {
+		return errs
+	}
+	return ValidationErrors{{Code: ValidationErrorMalformed, Message: err.Error()}}
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/gophercloud/gophercloud/v2"
 
@@ -20,7 +21,9 @@ import (
 // foo type can be ingested only, and the bar type can be delivered only.
 // Payloads for "test-foo.v1" must be JSON documents like {"foo":<integer>}, and
 // analogously for "test-bar.v1". Conversion from foo to bar payloads just
-// renames the field, the value remains the same.
+// renames the field, the value remains the same. Negative values are accepted
+// on ingress, but make conversion and delivery fail permanently. The value 0 is
+// accepted on ingress, but causes the conversion to skip the delivery.
 
 func init() {
 	tenso.ValidationHandlerRegistry.Add(func() tenso.ValidationHandler { return &testValidationHandler{"foo"} })
@@ -44,7 +47,9 @@ func parseTestPayload(data []byte, expectedType string) (p testPayload, err erro
 		return testPayload{}, err
 	}
 	if p.Event != expectedType {
-		return testPayload{}, fmt.Errorf("expected event = %q, but got %q", expectedType, p.Event)
+		var errs tenso.ValidationErrors
+		errs.Add("/event", tenso.ValidationErrorInvalid, "expected event = %q, but got %q", expectedType, p.Event)
+		return testPayload{}, errs
 	}
 	return p, nil
 }
@@ -69,8 +74,13 @@ func (h *testValidationHandler) ValidatePayload(data []byte, _ *regexp.Regexp) (
 	if err != nil {
 		return nil, err
 	}
+	var warnings tenso.ValidationErrors
+	if p.RoutingInfo != nil {
+		warnings.Add("/routing_info", tenso.ValidationErrorNotAllowed, "field routing_info is ignored in incoming events")
+	}
 	return &tenso.PayloadInfo{
 		Description: fmt.Sprintf("%s event with value %d", p.Event, p.Value),
+		Warnings:    warnings,
 	}, nil
 }
 
@@ -95,6 +105,12 @@ func (h *testTranslationHandler) TranslatePayload(data []byte, routingInfo map[s
 	if err != nil {
 		return nil, err
 	}
+	if p.Value < 0 {
+		return nil, tenso.Permanent(errors.New("simulating permanently failed translation because of negative value"))
+	}
+	if p.Value == 0 {
+		return nil, tenso.SkipDeliveryError{Reason: "zero-value", Message: "nothing to deliver"}
+	}
 	p.Event = h.TargetType
 	p.RoutingInfo = routingInfo
 	return json.Marshal(p)
@@ -117,10 +133,16 @@ func (h *testDeliveryHandler) PluginTypeID() string {
 // DeliverPayload implements the tenso.DeliveryHandler interface.
 func (h *testDeliveryHandler) DeliverPayload(_ context.Context, data []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
 	// We don't actually deliver anywhere, but by giving us an invalid payload, tests can "simulate" a delivery failure.
-	_, err := parseTestPayload(data, h.Type)
+	p, err := parseTestPayload(data, h.Type)
 	if err != nil {
 		return nil, errors.New("simulating failed delivery because of invalid payload")
 	}
+	if p.Value < 0 {
+		return nil, tenso.Permanent(errors.New("simulating permanently failed delivery because of negative value"))
+	}
 	msg := fmt.Sprintf("success (routing info was: %v)", routingInfo)
-	return &tenso.DeliveryLog{Message: msg}, nil
+	return &tenso.DeliveryLog{
+		Message:   msg,
+		ObjectIDs: map[string]string{"value": strconv.Itoa(p.Value)},
+	}, nil
 }
@@ -6,6 +6,7 @@ package test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/gophercloud/gophercloud/v2"
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,14 +20,17 @@ import (
 	"go.xyrillian.de/gg/pgruntime"
 
 	"github.com/sapcc/tenso/internal/api"
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/servicenowmock"
 	"github.com/sapcc/tenso/internal/tasks"
 	"github.com/sapcc/tenso/internal/tenso"
 )
 
 type setupParams struct {
-	RouteSpecs      []string
-	WithAPI         bool
-	WithTaskContext bool
+	RouteSpecs         []string
+	WithAPI            bool
+	WithTaskContext    bool
+	WithServiceNowMock bool
 }
 
 // WithAPI is a SetupOption that provides a http.Handler with the Tenso API.
@@ -39,6 +43,13 @@ func WithTaskContext(params *setupParams) {
 	params.WithTaskContext = true
 }
 
+// WithServiceNowMock is a SetupOption that provides a mock ServiceNow. All
+// ServiceNow endpoints in the mapping configuration at
+// $TENSO_SERVICENOW_MAPPING_CONFIG_PATH will deliver into this mock.
+func WithServiceNowMock(params *setupParams) {
+	params.WithServiceNowMock = true
+}
+
 // WithRoute is a SetupOption that adds a route to the configuration.
 func WithRoute(route string) SetupOption {
 	return func(params *setupParams) {
@@ -63,6 +74,8 @@ type Setup struct {
 	API       *api.API
 	// fields that are set if WithTaskContext is included
 	TaskContext *tasks.Context
+	// fields that are set if WithServiceNowMock is included
+	ServiceNow *servicenowmock.Server
 }
 
 // NewSetup prepares most or all pieces of Tenso for a test.
@@ -81,7 +94,10 @@ func NewSetup(t *testing.T, opts ...SetupOption) Setup {
 	s := Setup{
 		Clock: mock.NewClock(),
 		Config: tenso.Configuration{
-			EnabledRoutes: must.ReturnT(tenso.BuildRoutes(t.Context(), params.RouteSpecs, nil, gophercloud.EndpointOpts{}))(t),
+			EnabledRoutes:           must.ReturnT(tenso.BuildRoutes(t.Context(), params.RouteSpecs, nil, gophercloud.EndpointOpts{Region: "qa-de-1"}))(t),
+			Region:                  "qa-de-1",
+			DeliveryResultRetention: 30 * 24 * time.Hour,
+			FailedDeliveryRetention: 30 * 24 * time.Hour,
 		},
 		Ctx:      t.Context(),
 		DB:       db,
@@ -104,6 +120,15 @@ func NewSetup(t *testing.T, opts ...SetupOption) Setup {
 	if params.WithTaskContext {
 		s.TaskContext = tasks.NewContext(s.Config, s.DB).OverrideTimeNow(s.Clock.Now)
 	}
+	if params.WithServiceNowMock {
+		s.ServiceNow = servicenowmock.NewServer()
+		// NOTE: The mapping configuration is cached, so this affects the clients
+		// in all handlers that use it.
+		mappingConfig := must.ReturnT(servicenow.LoadMappingConfiguration("TENSO_SERVICENOW_MAPPING_CONFIG_PATH"))(t)
+		for _, client := range mappingConfig.Endpoints {
+			client.OverrideHTTPClient(s.ServiceNow.HTTPClient())
+		}
+	}
 
 	return s
 }
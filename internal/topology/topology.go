@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+// Package topology knows where Kubernetes clusters and availability zones are
+// located. This knowledge is shared between the validation of incoming events
+// and the mapping to ServiceNow changes.
+package topology
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sapcc/go-bits/regexpext"
+
+	"github.com/sapcc/tenso/internal/reloadable"
+)
+
+// Config is the structure of the config file at $TENSO_TOPOLOGY_CONFIG_PATH.
+type Config struct {
+	// Regions maps region names to the names of their availability zones.
+	Regions map[string][]string `json:"regions"`
+	// Clusters are evaluated in order; the first matching rule determines the
+	// region of a cluster. If no rule matches, a cluster is assumed to be
+	// located in a region if its name ends with the region name (e.g. cluster
+	// "s-qa-de-1" is in region "qa-de-1").
+	Clusters []ClusterRule `json:"clusters"`
+}
+
+// ClusterRule places matching clusters into a region. Exactly one of Name,
+// Suffix and Regex must be set.
+type ClusterRule struct {
+	Name   string                  `json:"name"`
+	Suffix string                  `json:"suffix"`
+	Regex  regexpext.BoundedRegexp `json:"regex"`
+	Region string                  `json:"region"`
+}
+
+func (r ClusterRule) matches(cluster string) bool {
+	switch {
+	case r.Name != "":
+		return cluster == r.Name
+	case r.Suffix != "":
+		return strings.HasSuffix(cluster, r.Suffix)
+	default:
+		return r.Regex.MatchString(cluster)
+	}
+}
+
+// DefaultConfig is used if no config file is given. It contains the cluster
+// layouts that were known before the config file was introduced.
+var DefaultConfig = Config{
+	Clusters: []ClusterRule{
+		{Name: "k-master", Region: "eu-nl-1"},
+		{Suffix: "-ora-1", Region: "ora"},
+		{Regex: `[agm]-qa-de-[12]00`, Region: "qa-de-1"},
+	},
+}
+
+// IsClusterLocatedInRegion checks whether the given Kubernetes cluster is located in the given region.
+func (c Config) IsClusterLocatedInRegion(cluster, region string) bool {
+	for _, rule := range c.Clusters {
+		if rule.matches(cluster) {
+			return rule.Region == region
+		}
+	}
+	return strings.HasSuffix(cluster, region)
+}
+
+// AvailabilityZonesOf returns the names of the availability zones in the given
+// region, or false if the region is not known.
+func (c Config) AvailabilityZonesOf(region string) ([]string, bool) {
+	azNames, ok := c.Regions[region]
+	return azNames, ok
+}
+
+// RegionOfAvailabilityZone returns the name of the region containing the
+// given availability zone, or false if the availability zone is not known.
+func (c Config) RegionOfAvailabilityZone(az string) (string, bool) {
+	for regionName, azNames := range c.Regions {
+		if slices.Contains(azNames, az) {
+			return regionName, true
+		}
+	}
+	return "", false
+}
+
+func (c Config) validate() error {
+	regionOfAZ := make(map[string]string)
+	for regionName, azNames := range c.Regions {
+		if len(azNames) == 0 {
+			return fmt.Errorf("region %q has no availability zones", regionName)
+		}
+		for _, azName := range azNames {
+			if otherRegion, exists := regionOfAZ[azName]; exists {
+				return fmt.Errorf("availability zone %q is listed in multiple regions (%q and %q)", azName, otherRegion, regionName)
+			}
+			regionOfAZ[azName] = regionName
+		}
+	}
+
+	for idx, rule := range c.Clusters {
+		matcherCount := 0
+		for _, matcher := range []string{rule.Name, rule.Suffix, string(rule.Regex)} {
+			if matcher != "" {
+				matcherCount++
+			}
+		}
+		if matcherCount != 1 {
+			return fmt.Errorf(`cluster rule %d: exactly one of "name", "suffix" and "regex" must be set`, idx)
+		}
+		if rule.Region == "" {
+			return fmt.Errorf(`cluster rule %d: missing "region" attribute`, idx)
+		}
+	}
+	return nil
+}
+
+// Topology provides the current Config. If the Config was loaded from a file,
+// the file is reloaded when it changes on disk, so that new clusters and
+// regions can be added without restarting Tenso.
+type Topology struct {
+	file *reloadable.File[Config]
+}
+
+var loader = reloadable.Loader[Config]{
+	Description: "topology",
+	Default:     DefaultConfig,
+	Validate:    Config.validate,
+}
+
+// Load loads the topology from the file specified in the given environment
+// variable. If the environment variable is not set, DefaultConfig is used.
+func Load(envVarName string) (*Topology, error) {
+	file, err := loader.Load(envVarName)
+	if err != nil {
+		return nil, err
+	}
+	return &Topology{file}, nil
+}
+
+// Current returns the current Config. If the config file has changed, it is
+// reloaded first. If the changed file is invalid, the previous Config is kept.
+func (t *Topology) Current() Config {
+	return t.file.Current()
+}
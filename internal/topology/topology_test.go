@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package topology_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/reloadable"
+	"github.com/sapcc/tenso/internal/topology"
+)
+
+// These are the special cases that were hardcoded before the topology became configurable.
+var clusterTestCases = []struct {
+	Cluster  string
+	Region   string
+	Expected bool
+}{
+	// the control plane cluster is located in eu-nl-1
+	{"k-master", "eu-nl-1", true},
+	{"k-master", "eu-de-1", false},
+	// clusters with this suffix are all in the same region
+	{"s-ora-1", "ora", true},
+	{"k-ora-1", "ora", true},
+	{"s-ora-1", "ora-1", false},
+	// QA clusters with numeric suffixes
+	{"a-qa-de-100", "qa-de-1", true},
+	{"g-qa-de-200", "qa-de-1", true},
+	{"m-qa-de-100", "qa-de-1", true},
+	{"a-qa-de-100", "qa-de-100", false},
+	{"b-qa-de-100", "qa-de-100", true},
+	{"a-qa-de-300", "qa-de-300", true},
+	// all other clusters are located in the region at the end of their name
+	{"s-qa-de-1", "qa-de-1", true},
+	{"v-eu-de-2", "eu-de-2", true},
+	{"s-qa-de-1", "eu-de-1", false},
+}
+
+func TestDefaultConfig(t *testing.T) {
+	for _, tc := range clusterTestCases {
+		actual := topology.DefaultConfig.IsClusterLocatedInRegion(tc.Cluster, tc.Region)
+		if actual != tc.Expected {
+			t.Errorf("expected IsClusterLocatedInRegion(%q, %q) = %t, but got %t", tc.Cluster, tc.Region, tc.Expected, actual)
+		}
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	t.Setenv("TENSO_TOPOLOGY_CONFIG_PATH", "fixtures/topology.json")
+	cfg := must.ReturnT(topology.Load("TENSO_TOPOLOGY_CONFIG_PATH"))(t).Current()
+
+	// the config file reproduces the default cluster rules
+	for _, tc := range clusterTestCases {
+		actual := cfg.IsClusterLocatedInRegion(tc.Cluster, tc.Region)
+		if actual != tc.Expected {
+			t.Errorf("expected IsClusterLocatedInRegion(%q, %q) = %t, but got %t", tc.Cluster, tc.Region, tc.Expected, actual)
+		}
+	}
+
+	azNames, ok := cfg.AvailabilityZonesOf("qa-de-1")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, azNames, []string{"qa-de-1a", "qa-de-1b", "qa-de-1d"})
+	_, ok = cfg.AvailabilityZonesOf("eu-de-1")
+	assert.Equal(t, ok, false)
+
+	region, ok := cfg.RegionOfAvailabilityZone("eu-nl-1b")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, region, "eu-nl-1")
+	_, ok = cfg.RegionOfAvailabilityZone("eu-de-1a")
+	assert.Equal(t, ok, false)
+}
+
+func TestConfigReload(t *testing.T) {
+	// check for changes on every call, so that reloads are observed immediately
+	defer func(interval time.Duration) { reloadable.CheckInterval = interval }(reloadable.CheckInterval)
+	reloadable.CheckInterval = 0
+
+	path := filepath.Join(t.TempDir(), "topology.json")
+	writeConfig := func(content string, modTime time.Time) {
+		must.SucceedT(t, os.WriteFile(path, []byte(content), 0o600))
+		must.SucceedT(t, os.Chtimes(path, modTime, modTime))
+	}
+	now := time.Now().Truncate(time.Second)
+	writeConfig(`{"clusters":[{"name":"k-master","region":"eu-nl-1"}]}`, now.Add(-time.Hour))
+
+	t.Setenv("TENSO_TOPOLOGY_CONFIG_PATH", path)
+	topo := must.ReturnT(topology.Load("TENSO_TOPOLOGY_CONFIG_PATH"))(t)
+	assert.Equal(t, topo.Current().IsClusterLocatedInRegion("k-master", "eu-nl-1"), true)
+
+	// when the file changes, the new config is used without loading it again
+	writeConfig(`{"clusters":[{"name":"k-master","region":"eu-de-1"}]}`, now)
+	assert.Equal(t, topo.Current().IsClusterLocatedInRegion("k-master", "eu-nl-1"), false)
+	assert.Equal(t, topo.Current().IsClusterLocatedInRegion("k-master", "eu-de-1"), true)
+
+	// when the changed file is invalid, the previous config is kept
+	writeConfig(`{"clusters":[{"region":"eu-nl-1"}]}`, now.Add(time.Hour))
+	assert.Equal(t, topo.Current().IsClusterLocatedInRegion("k-master", "eu-de-1"), true)
+}
+
+func TestConfigValidation(t *testing.T) {
+	testCases := map[string]string{
+		`{"clusters":[{"region":"eu-nl-1"}]}`:                                `cluster rule 0: exactly one of "name", "suffix" and "regex" must be set`,
+		`{"clusters":[{"name":"k-master","suffix":"-master","region":"x"}]}`: `cluster rule 0: exactly one of "name", "suffix" and "regex" must be set`,
+		`{"clusters":[{"suffix":"-ora-1"}]}`:                                 `cluster rule 0: missing "region" attribute`,
+		`{"regions":{"qa-de-1":[]}}`:                                         `region "qa-de-1" has no availability zones`,
+		`{"unknown":true}`:                                                   `json: unknown field "unknown"`,
+	}
+	for configJSON, expectedError := range testCases {
+		path := filepath.Join(t.TempDir(), "topology.json")
+		must.SucceedT(t, os.WriteFile(path, []byte(configJSON), 0o600))
+		t.Setenv("TENSO_TOPOLOGY_CONFIG_PATH", path)
+		_, err := topology.Load("TENSO_TOPOLOGY_CONFIG_PATH")
+		assert.ErrEqual(t, err, "while parsing "+path+": "+expectedError)
+	}
+}
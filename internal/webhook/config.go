@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sapcc/go-bits/osext"
+)
+
+// Configuration is the structure of the config file at
+// $TENSO_WEBHOOK_CONFIG_PATH.
+type Configuration struct {
+	Endpoints EndpointSet `json:"endpoints"`
+}
+
+var configAtPath = map[string]Configuration{}
+
+// LoadConfiguration loads the webhook configuration from the file specified in the given environment variable.
+func LoadConfiguration(envVarName string) (Configuration, error) {
+	filePath, err := osext.NeedGetenv(envVarName)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	// reuse cached result if possible
+	if _, ok := configAtPath[filePath]; ok {
+		return configAtPath[filePath], nil
+	}
+
+	buf, err := os.ReadFile(filePath)
+	if err != nil {
+		return Configuration{}, err
+	}
+	result, err := ParseConfiguration(buf)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("while parsing %s: %w", filePath, err)
+	}
+
+	configAtPath[filePath] = result
+	return result, nil
+}
+
+// ParseConfiguration parses and initializes a webhook configuration from its
+// serialized form. This is usually called through LoadConfiguration().
+func ParseConfiguration(buf []byte) (Configuration, error) {
+	var result Configuration
+	err := json.Unmarshal(buf, &result)
+	if err != nil {
+		return Configuration{}, err
+	}
+	if len(result.Endpoints) == 0 {
+		return Configuration{}, errors.New("no endpoints declared")
+	}
+	err = result.Endpoints.Init()
+	if err != nil {
+		return Configuration{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,196 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sapcc/tenso/internal/tenso"
+)
+
+const (
+	// SignatureHeader is the request header containing the HMAC-SHA256 signature of the request body.
+	SignatureHeader = "X-Tenso-Signature-256"
+	// PayloadTypeHeader is the request header containing the payload type of the request body.
+	PayloadTypeHeader = "X-Tenso-Payload-Type"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// EndpointSet is a set of Endpoint objects.
+//
+// This type appears in type Configuration.
+type EndpointSet map[string]*Endpoint
+
+// Init validates the provided endpoint set and recurses into Endpoint.Init().
+func (es EndpointSet) Init() error {
+	if _, exists := es["default"]; !exists {
+		return errors.New(`no "default" endpoint declared`)
+	}
+
+	for name, endpoint := range es {
+		err := endpoint.Init()
+		if err != nil {
+			return fmt.Errorf("in initialization of endpoint %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Deliver delivers a payload to the endpoint selected by the "webhook-target"
+// routing info, or to the "default" endpoint if no target is selected. The
+// return values are the same as for DeliverPayload() in the
+// tenso.DeliveryHandler interface.
+func (es EndpointSet) Deliver(ctx context.Context, payloadType, contentType string, payload []byte, routingInfo map[string]string) (*tenso.DeliveryLog, error) {
+	name, exists := routingInfo["webhook-target"]
+	if !exists {
+		name = "default"
+	}
+	endpoint, exists := es[name]
+	if !exists {
+		return nil, tenso.Permanent(fmt.Errorf("unknown routing info: webhook-target=%q", name))
+	}
+	return endpoint.Deliver(ctx, payloadType, contentType, payload)
+}
+
+// Endpoint is an HTTP endpoint that receives payloads via POST.
+//
+// This type appears in type Configuration through type EndpointSet.
+type Endpoint struct {
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	HMACSecretPath string            `json:"hmac_secret_path"`
+	Timeout        string            `json:"timeout"`
+	CACertPath     string            `json:"ca_cert"`
+	ClientCertPath string            `json:"client_cert"`
+	PrivateKeyPath string            `json:"private_key"`
+
+	hmacSecret []byte
+	httpClient *http.Client
+}
+
+// Init validates the provided Endpoint config and initializes the internal HTTP client.
+func (e *Endpoint) Init() error {
+	if e.URL == "" {
+		return errors.New(`missing "url" attribute`)
+	}
+	if !strings.HasPrefix(e.URL, "http://") && !strings.HasPrefix(e.URL, "https://") {
+		return fmt.Errorf(`invalid value for "url" attribute: %q`, e.URL)
+	}
+	if (e.ClientCertPath == "") != (e.PrivateKeyPath == "") {
+		return errors.New(`"client_cert" and "private_key" must be given together`)
+	}
+
+	timeout := defaultTimeout
+	if e.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(e.Timeout)
+		if err != nil {
+			return fmt.Errorf(`invalid value for "timeout" attribute: %w`, err)
+		}
+	}
+
+	if e.HMACSecretPath != "" {
+		buf, err := os.ReadFile(e.HMACSecretPath)
+		if err != nil {
+			return fmt.Errorf("cannot load HMAC secret: %w", err)
+		}
+		e.hmacSecret = bytes.TrimSpace(buf)
+		if len(e.hmacSecret) == 0 {
+			return fmt.Errorf("HMAC secret in %s is empty", e.HMACSecretPath)
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if e.CACertPath != "" {
+		buf, err := os.ReadFile(e.CACertPath)
+		if err != nil {
+			return fmt.Errorf("cannot load CA certificate: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(buf) {
+			return fmt.Errorf("no valid certificates found in %s", e.CACertPath)
+		}
+	}
+	if e.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(e.ClientCertPath, e.PrivateKeyPath)
+		if err != nil {
+			return fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	e.httpClient = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			Proxy:           http.ProxyFromEnvironment,
+		},
+	}
+	return nil
+}
+
+// Sign computes the value of the SignatureHeader for the given request body.
+// If no HMAC secret is configured, the empty string is returned.
+func (e *Endpoint) Sign(body []byte) string {
+	if len(e.hmacSecret) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, e.hmacSecret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver POSTs a payload to this endpoint.
+// It is usually called through EndpointSet.Deliver().
+func (e *Endpoint) Deliver(ctx context.Context, payloadType, contentType string, payload []byte) (*tenso.DeliveryLog, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("while preparing request for POST %s: %w", e.URL, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set(PayloadTypeHeader, payloadType)
+	if signature := e.Sign(payload); signature != "" {
+		req.Header.Set(SignatureHeader, signature)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("during POST %s: %w", e.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		// drain the body to allow connection reuse
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, nil
+	}
+
+	// unexpected error -> log response body
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("while reading response body for failed POST %s: %w", e.URL, err)
+	}
+	err = fmt.Errorf("POST %s failed with status %d and response: %q", e.URL, resp.StatusCode, string(bodyBytes))
+	if tenso.IsPermanentFailureStatus(resp.StatusCode) {
+		return nil, tenso.Permanent(err)
+	}
+	return nil, err
+}
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2026 SAP SE or an SAP affiliate company
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sapcc/go-bits/must"
+	"go.xyrillian.de/gg/assert"
+
+	"github.com/sapcc/tenso/internal/tenso"
+	"github.com/sapcc/tenso/internal/webhook"
+)
+
+type receivedRequest struct {
+	Header http.Header
+	Body   string
+}
+
+func newReceiver(t *testing.T, useTLS bool) (*httptest.Server, *[]receivedRequest, *int) {
+	t.Helper()
+	var (
+		received   []receivedRequest
+		statusCode = http.StatusNoContent
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := must.ReturnT(io.ReadAll(r.Body))(t)
+		received = append(received, receivedRequest{r.Header.Clone(), string(body)})
+		w.WriteHeader(statusCode)
+	})
+	var srv *httptest.Server
+	if useTLS {
+		srv = httptest.NewTLSServer(handler)
+	} else {
+		srv = httptest.NewServer(handler)
+	}
+	t.Cleanup(srv.Close)
+	return srv, &received, &statusCode
+}
+
+func writeFile(t *testing.T, name string, contents []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	must.SucceedT(t, os.WriteFile(path, contents, 0o600))
+	return path
+}
+
+func TestDeliverySignedAndRouted(t *testing.T) {
+	srvDefault, receivedDefault, _ := newReceiver(t, false)
+	srvOther, receivedOther, statusOther := newReceiver(t, false)
+	secretPath := writeFile(t, "secret", []byte("swordfish\n"))
+
+	cfg := must.ReturnT(webhook.ParseConfiguration(fmt.Appendf(nil, `{
+		"endpoints": {
+			"default": { "url": %q, "headers": { "X-Team": "services" }, "hmac_secret_path": %q },
+			"other": { "url": %q, "timeout": "5s" }
+		}
+	}`, srvDefault.URL, secretPath, srvOther.URL)))(t)
+
+	// delivery to the default endpoint is signed and carries the configured headers
+	payload := []byte(`{"foo":42}`)
+	_ = must.ReturnT(cfg.Endpoints.Deliver(t.Context(), "test-to-webhook.v1", "application/json", payload, nil))(t)
+	assert.Equal(t, len(*receivedDefault), 1)
+	req := (*receivedDefault)[0]
+	assert.Equal(t, req.Body, string(payload))
+	assert.Equal(t, req.Header.Get("X-Team"), "services")
+	assert.Equal(t, req.Header.Get(webhook.PayloadTypeHeader), "test-to-webhook.v1")
+	// computed with `printf '{"foo":42}' | openssl dgst -sha256 -hmac swordfish`
+	assert.Equal(t, req.Header.Get(webhook.SignatureHeader), "sha256=1a8a918c70d21c73e62c128c3f5f7445a0816f16f02052bfd226f2143e488fe1")
+
+	// routing info selects a different endpoint; this one is not signed
+	routingInfo := map[string]string{"webhook-target": "other"}
+	_ = must.ReturnT(cfg.Endpoints.Deliver(t.Context(), "test-to-webhook.v1", "application/json", payload, routingInfo))(t)
+	assert.Equal(t, len(*receivedOther), 1)
+	assert.Equal(t, (*receivedOther)[0].Header.Get(webhook.SignatureHeader), "")
+
+	// errors from the endpoint are classified by status code
+	*statusOther = http.StatusServiceUnavailable
+	_, err := cfg.Endpoints.Deliver(t.Context(), "test-to-webhook.v1", "application/json", payload, routingInfo)
+	assert.Equal(t, err != nil && !tenso.IsPermanent(err), true)
+	*statusOther = http.StatusBadRequest
+	_, err = cfg.Endpoints.Deliver(t.Context(), "test-to-webhook.v1", "application/json", payload, routingInfo)
+	assert.Equal(t, tenso.IsPermanent(err), true)
+
+	// unknown targets cannot be delivered to
+	_, err = cfg.Endpoints.Deliver(t.Context(), "test-to-webhook.v1", "application/json", payload, map[string]string{"webhook-target": "unknown"})
+	assert.ErrEqual(t, err, `unknown routing info: webhook-target="unknown"`)
+	assert.Equal(t, tenso.IsPermanent(err), true)
+}
+
+func TestDeliveryWithCustomCA(t *testing.T) {
+	srv, received, _ := newReceiver(t, true)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	caPath := writeFile(t, "ca.pem", caPEM)
+
+	cfg := must.ReturnT(webhook.ParseConfiguration(fmt.Appendf(nil,
+		`{"endpoints":{"default":{"url":%q,"ca_cert":%q}}}`, srv.URL, caPath,
+	)))(t)
+	_ = must.ReturnT(cfg.Endpoints.Deliver(t.Context(), "test-to-webhook.v1", "application/json", []byte(`{}`), nil))(t)
+	assert.Equal(t, len(*received), 1)
+}
+
+func TestConfigurationErrors(t *testing.T) {
+	testCases := map[string]string{
+		`{"endpoints":{}}`: `no endpoints declared`,
+		`{"endpoints":{"other":{"url":"http://example.com"}}}`:                         `no "default" endpoint declared`,
+		`{"endpoints":{"default":{}}}`:                                                 `in initialization of endpoint "default": missing "url" attribute`,
+		`{"endpoints":{"default":{"url":"ftp://example.com"}}}`:                        `in initialization of endpoint "default": invalid value for "url" attribute: "ftp://example.com"`,
+		`{"endpoints":{"default":{"url":"http://example.com","timeout":"soon"}}}`:      `in initialization of endpoint "default": invalid value for "timeout" attribute: time: invalid duration "soon"`,
+		`{"endpoints":{"default":{"url":"http://example.com","client_cert":"a.pem"}}}`: `in initialization of endpoint "default": "client_cert" and "private_key" must be given together`,
+	}
+	for input, expectedError := range testCases {
+		_, err := webhook.ParseConfiguration([]byte(input))
+		assert.ErrEqual(t, err, expectedError)
+	}
+}
@@ -5,6 +5,9 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net/http"
 	"os"
 	"time"
@@ -26,6 +29,8 @@ import (
 
 	"github.com/sapcc/tenso/internal/api"
 	_ "github.com/sapcc/tenso/internal/handlers" // must be imported to register the handler implementations
+	"github.com/sapcc/tenso/internal/servicenow"
+	"github.com/sapcc/tenso/internal/servicenowmock"
 	"github.com/sapcc/tenso/internal/tasks"
 	"github.com/sapcc/tenso/internal/tenso"
 )
@@ -46,6 +51,11 @@ func main() {
 	wrap.SetOverrideUserAgent(bininfo.Component(), bininfo.VersionOr("rolling"))
 
 	ctx := httpext.ContextWithSIGINT(context.Background(), 10*time.Second)
+	if commandWord == "dev-servicenow" {
+		// this mode does not need the regular configuration or a database
+		runDevServiceNow(ctx)
+		return
+	}
 	cfg, provider, eo := tenso.ParseConfiguration(ctx)
 	db := tenso.InitDB(ctx)
 
@@ -55,7 +65,7 @@ func main() {
 	case "worker":
 		runWorker(ctx, cfg, db)
 	default:
-		logg.Fatal("usage: %s [api|worker]", os.Args[0])
+		logg.Fatal("usage: %s [api|worker|dev-servicenow]", os.Args[0])
 	}
 }
 
@@ -66,9 +76,9 @@ func runAPI(ctx context.Context, cfg tenso.Configuration, db *gsql.DB, provider
 	}
 	tv := gopherpolicy.TokenValidator{
 		IdentityV3: identityV3,
+		Enforcer:   must.Return(api.LoadPolicyFile(osext.MustGetenv("TENSO_OSLO_POLICY_PATH"))),
 		Cacher:     gopherpolicy.InMemoryCacher(),
 	}
-	must.Succeed(tv.LoadPolicyFile(osext.MustGetenv("TENSO_OSLO_POLICY_PATH"), nil))
 
 	// wire up HTTP handlers
 	corsMiddleware := cors.New(cors.Options{
@@ -109,7 +119,12 @@ func runWorker(ctx context.Context, cfg tenso.Configuration, db *gsql.DB) {
 		httpapi.HealthCheckAPI{
 			SkipRequestLog: true,
 			Check: func() error {
-				return db.PingContext(ctx)
+				err := db.PingContext(ctx)
+				if err != nil {
+					return err
+				}
+				// the worker cannot deliver to ServiceNow with an expired client certificate
+				return servicenow.CheckClientCertificates(time.Now())
 			},
 		},
 		pprofapi.API{IsAuthorized: pprofapi.IsRequestFromLocalhost},
@@ -122,3 +137,51 @@ func runWorker(ctx context.Context, cfg tenso.Configuration, db *gsql.DB) {
 	listenAddress := osext.GetenvOrDefault("TENSO_WORKER_LISTEN_ADDRESS", ":8080")
 	must.Succeed(httpext.ListenAndServeContext(ctx, listenAddress, mux))
 }
+
+func runDevServiceNow(ctx context.Context) {
+	s := servicenowmock.NewServer()
+	s.Username = os.Getenv("TENSO_DEV_SERVICENOW_USERNAME")
+	s.Password = os.Getenv("TENSO_DEV_SERVICENOW_PASSWORD")
+	s.OAuthClientID = os.Getenv("TENSO_DEV_SERVICENOW_OAUTH_CLIENT_ID")
+	s.OAuthClientSecret = os.Getenv("TENSO_DEV_SERVICENOW_OAUTH_CLIENT_SECRET")
+	if latencyStr := os.Getenv("TENSO_DEV_SERVICENOW_LATENCY"); latencyStr != "" {
+		s.SetLatency(must.Return(time.ParseDuration(latencyStr)))
+	}
+
+	server := &http.Server{
+		Addr:              osext.GetenvOrDefault("TENSO_DEV_SERVICENOW_LISTEN_ADDRESS", ":8081"),
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	certPath := os.Getenv("TENSO_DEV_SERVICENOW_TLS_CERT_PATH")
+	if certPath != "" {
+		serverCert := must.Return(tls.LoadX509KeyPair(certPath, osext.MustGetenv("TENSO_DEV_SERVICENOW_TLS_KEY_PATH")))
+		var clientCAs *x509.CertPool
+		if caPath := os.Getenv("TENSO_DEV_SERVICENOW_CLIENT_CA_PATH"); caPath != "" {
+			clientCAs = x509.NewCertPool()
+			if !clientCAs.AppendCertsFromPEM(must.Return(os.ReadFile(caPath))) {
+				logg.Fatal("no certificates found in %s", caPath)
+			}
+			s.RequireClientCert = true
+		}
+		server.TLSConfig = s.TLSConfig(serverCert, clientCAs)
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpext.ShutdownTimeout)
+		defer cancel()
+		must.Succeed(server.Shutdown(shutdownCtx)) //nolint:contextcheck // ctx is already expired at this point
+	}()
+
+	logg.Info("Serving mock ServiceNow on %s...", server.Addr)
+	var err error
+	if server.TLSConfig == nil {
+		err = server.ListenAndServe()
+	} else {
+		err = server.ListenAndServeTLS("", "")
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		logg.Fatal(err.Error())
+	}
+}